@@ -4,21 +4,111 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"runtime"
+	"runtime/debug"
+	"runtime/trace"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
 	nonPositiveParticipants = "participants is NOT positive"
+	participantsOutOfRange  = "barrier participants is out of range"
 	tooMuchWaiting          = "calling b.Wait() is more than b.participants. Make sure they are equal."
+	negativeBuffer          = "barrier events buffer is negative"
+	senseUnsupported        = "barrier option is not supported by SenseReversing: "
+	senseLagged             = "barrier goroutine lags two generations behind, whose result is overwritten. Make sure the same goroutines arrive every generation."
 )
 
 var (
 	// ErrBroken will be returned by all goroutines called Barrier.Wait() if a
 	// goroutine called Barrier.Break()
 	// The goroutine wait lately, will return this error at once.
+	// The error is wrapped in a *BrokenError with why the round is broken,
+	// so check it by errors.Is.
 	ErrBroken = errors.New("barrier is broken by other goroutine")
+
+	// ErrClosed will be returned by Wait after Barrier.Close() is called.
+	// Goroutines waiting in the round broken by Close also return it.
+	ErrClosed = errors.New("barrier is closed")
+
+	// ErrTimeout will be wrapped in the error returned by WaitTimeout,
+	// if the other participants do not arrive in time.
+	ErrTimeout = errors.New("barrier wait timeout")
+
+	// ErrTooManyParties will be returned by the goroutine arriving more than
+	// participants in a round, if the barrier does not panic for it.
+	ErrTooManyParties = errors.New(tooMuchWaiting)
+
+	// ErrTooManyWaiters is ErrTooManyParties.
+	ErrTooManyWaiters = ErrTooManyParties
+
+	// ErrInvalidToken will be returned by AwaitRelease with an unknown token.
+	ErrInvalidToken = errors.New("barrier token is invalid")
+
+	// ErrActionPanic will be wrapped in the error returned by the goroutine
+	// which runs the action, if the action panics.
+	// The other goroutines of the round return ErrBroken.
+	ErrActionPanic = errors.New("barrier action panics")
+
+	// ErrNonPositiveParticipants will be returned by Resize with participants <= 0.
+	ErrNonPositiveParticipants = errors.New(nonPositiveParticipants)
+
+	// ErrParticipantsOutOfRange will be returned by Resize and Register,
+	// if the barrier would have more than 1<<22 participants, which the
+	// state of a round can count.
+	ErrParticipantsOutOfRange = errors.New(participantsOutOfRange)
+
+	// ErrRoundInProgress will be returned by Resize, if some goroutines
+	// have arrived in the current round.
+	ErrRoundInProgress = errors.New("barrier round is in progress")
+
+	// ErrReentrantWait will be returned by Wait, BreakCtx, and their
+	// variants like WaitN and WaitWeighted, if they are called with the
+	// context passed to the action set by SetActionCtx, or one derived
+	// from it, instead of deadlock. TryWait returns false then.
+	ErrReentrantWait = errors.New("barrier is waited by its own action, which would deadlock")
+
+	// ErrInvalidWeight will be returned by WaitWeighted with n <= 0.
+	ErrInvalidWeight = errors.New("barrier weight is not positive")
+
+	// ErrQuorumOutOfRange will be returned by WaitN with k out of [1, participants].
+	ErrQuorumOutOfRange = errors.New("barrier quorum is out of range")
+
+	// ErrDuplicateArrival will be returned by WaitAs, if the participant of
+	// the name is waiting in the round already. It is wrapped with the name.
+	ErrDuplicateArrival = errors.New("barrier participant has arrived in the round")
+
+	// ErrNotSupported will be returned by the methods, which the Barrier
+	// can not do, like Resize of a SenseReversing barrier.
+	ErrNotSupported = errors.New("barrier operation is not supported")
 )
 
+// BrokenError is returned by the participants of a broken round.
+// It is ErrBroken for errors.Is, and wraps why the round is broken.
+type BrokenError struct {
+	Cause    error  // ErrBroken for Break, ErrTimeout, the error of the context, BreakWith or the action
+	Round    uint64 // number of the completed rounds before the broken one
+	Canceled bool   // broken by the cancellation or deadline of a context
+	By       string // name of the participant breaking the round, set by WaitAs
+}
+
+func (e *BrokenError) Error() string {
+	if e.By != "" {
+		return "barrier is broken by " + e.By + ": " + e.Cause.Error()
+	}
+	return "barrier is broken: " + e.Cause.Error()
+}
+
+func (e *BrokenError) Unwrap() error {
+	return e.Cause
+}
+
+func (e *BrokenError) Is(target error) bool {
+	return target == ErrBroken
+}
+
 // Barrier is a synchronizer that allows a set of goroutines
 // to wait for each other to reach a common execution point,
 // also called a barrier.
@@ -36,6 +126,53 @@ type Barrier interface {
 	// else return nil.
 	Wait(ctx context.Context) error
 
+	// WaitAs is Wait for the participant named name. The name is reported by
+	// BrokenError.By if the participant breaks the round, and by DebugDump,
+	// WithWatchdog and WithStallWarning while it is waiting.
+	// It returns ErrDuplicateArrival, if the participant of name is waiting
+	// in the round already.
+	WaitAs(ctx context.Context, name string) error
+
+	// WaitExchange is Wait, which contributes mine to the round, and returns
+	// the contributions of all the participants of the round in arrival order,
+	// once the round completes successfully.
+	// All the participants receive the same slice, which should be read only.
+	WaitExchange(ctx context.Context, mine interface{}) ([]interface{}, error)
+
+	// WaitAction is Wait with an action for the current round only.
+	// Only the action passed by the last arrived goroutine runs, instead of
+	// the actions of the barrier, and the actions passed by the others are
+	// ignored. If the last one passes nil, or it arrives by Wait, the actions
+	// of the barrier run as usual.
+	// The action works like the one of SetActionE, its error breaks the round,
+	// and is wrapped in the errors returned by all the participants of the round.
+	WaitAction(ctx context.Context, action func() error) error
+
+	// WaitIndexed is Wait, and returns the 1-based arrival index of the
+	// caller among the waiting goroutines of the round. The index equals
+	// participants only for the last arrived goroutine, which runs the action.
+	WaitIndexed(ctx context.Context) (index int, err error)
+
+	// WaitIndex is WaitIndexed, but returns the arrival index like Java's
+	// CyclicBarrier.await, participants-1 for the first arrived goroutine,
+	// and 0 for the last one, which can act as the leader of the round.
+	WaitIndex(ctx context.Context) (index int, err error)
+
+	// WaitN is Wait, but the round completes once k goroutines are waiting,
+	// including the caller. The goroutines arriving later join the next round.
+	// The smallest k of the goroutines waiting in a round wins.
+	// It returns ErrQuorumOutOfRange if k is not in [1, participants].
+	// Arrive should not be mixed with WaitN, because the parties arrived by
+	// Arrive count for the round, even if it completes before AwaitRelease.
+	WaitN(ctx context.Context, k int) error
+
+	// WaitWeighted is Wait, but the caller arrives as n parties, for the
+	// goroutine doing the jobs of several parties.
+	// It returns ErrInvalidWeight if n <= 0, and ErrTooManyParties if the
+	// round has less than n parties to arrive, without arriving.
+	// If the round is completing, the caller arrives the next round.
+	WaitWeighted(ctx context.Context, n int) error
+
 	// Break is `Wait` with unfinished job.
 	// The code of use `Break` is like
 	// if ok := doJob(); ok {
@@ -45,150 +182,1970 @@ type Barrier interface {
 	// }
 	Break()
 
+	// BreakCtx is Break, but it gives up and returns ctx.Err(),
+	// if ctx is done before it arrives. It returns ErrClosed after Close.
+	// The context is passed to the action, if it is the last arrived one.
+	BreakCtx(ctx context.Context) error
+
+	// Arrive arrives the barrier without waiting, and returns a token
+	// identifying the arrival at once.
+	// Every Arrive must be paired with exactly one AwaitRelease with the token,
+	// because the round only trips after all of its participants are waiting.
+	// So the last arrived goroutine of Arrive does not run the action,
+	// the one completing the round by AwaitRelease or Wait does.
+	Arrive() (token int, err error)
+
+	// AwaitRelease waits the release of the round, which the token arrived.
+	// It returns ErrInvalidToken if the token is not returned by Arrive,
+	// or has been awaited.
+	AwaitRelease(ctx context.Context, token int) error
+
+	// TryWait arrives the barrier only if the caller is the last one,
+	// which completes the round and returns true with the result of the round.
+	// Otherwise it returns false at once without arriving.
+	TryWait() (completed bool, err error)
+
 	// IsBroken returns true if this round barrier is broken.
+	// A closed barrier is always broken.
 	IsBroken() bool
 
 	// SetAction set an action will be execute after all participants
 	// arrived the barrier.
 	// Even the barrier is broken, the action will also be executed.
 	SetAction(func()) Barrier
+
+	// SetActionE is SetAction with an action returning error.
+	// If the action returns an error, the round is broken, and all the
+	// participants of the round return an error wrapping it, which is
+	// ErrBroken as well. Check them by errors.Is.
+	// The most recently set action replaces the one set by SetAction.
+	SetActionE(func() error) Barrier
+
+	// SetActionCtx is SetActionE with an action receiving the context
+	// passed to the Wait of the last arrived goroutine.
+	// The action is called even if the context is done, but the round has
+	// been broken by the cancellation then.
+	// Waiting on the barrier with the context, or one derived from it,
+	// returns ErrReentrantWait, because the round waits for the action.
+	// SetAction, SetActionE and SetActionCtx replace each other,
+	// the most recently set action wins.
+	// The action of a round is fixed once its first goroutine arrives,
+	// so setting actions in the middle of a round affects the next round.
+	SetActionCtx(func(context.Context) error) Barrier
+
+	// AddAction appends an action to the actions of the barrier.
+	// The actions are executed in registration order by the last arrived
+	// goroutine, and the first error stops the rest, and breaks the round
+	// like SetActionE. SetAction, SetActionE and SetActionCtx replace all
+	// the actions with the new one. nil action is ignored.
+	AddAction(func() error) Barrier
+
+	// Reset breaks the current round, so that goroutines waiting in it
+	// return ErrBroken, and starts a new round at once.
+	// Unlike Break, Reset is not an arrival, and never runs the action.
+	// If all participants of the round have arrived, Reset does nothing,
+	// because the round is completing.
+	// Reset also clears the broken state kept by WithStickyBroken.
+	Reset()
+
+	// NumberWaiting returns the number of parties arrived in the current round.
+	NumberWaiting() int
+
+	// IsDrained returns true if the current round is a fresh one,
+	// nobody has arrived, and it is not broken.
+	IsDrained() bool
+
+	// WaitTimeout is Wait with a timeout instead of a context.
+	// If the other participants do not arrive within d, it breaks the round
+	// and returns an error wrapping ErrTimeout.
+	WaitTimeout(d time.Duration) error
+
+	// Close breaks the round in flight with ErrClosed, and disables the
+	// barrier permanently. Every Wait called after Close returns ErrClosed
+	// at once. If the round has tripped before Close, it completes as usual.
+	// IsBroken always returns true after Close.
+	// The channels returned by Events are closed as well, so that none of
+	// the goroutines using the barrier are blocked forever.
+	Close() error
+
+	// Round returns the number of completed rounds, no matter
+	// they are completed successfully or broken.
+	Round() uint64
+
+	// RoundNumber is Round.
+	RoundNumber() uint64
+
+	// Stats returns a snapshot of the statistics across rounds.
+	Stats() Stats
+
+	// String describes the live state of the barrier, like
+	// "Barrier{name:stage1 participants:8 waiting:3 broken:false round:42}".
+	// The name is omitted, unless it is set by WithName.
+	String() string
+
+	// Participants returns the number of parties, which is set by New or Resize.
+	Participants() int
+
+	// Parties is Participants, it is named like cyclicbarrier.GetParties.
+	Parties() int
+
+	// Resize changes the number of parties to participants.
+	// It only succeeds when no goroutine has arrived in the current round,
+	// otherwise it returns ErrRoundInProgress.
+	Resize(participants int) error
+
+	// SetRoundTimeout makes every round break with an error wrapping
+	// ErrTimeout, if not all participants arrive within d after the first
+	// arrival of the round. d <= 0 disables it, which is the default.
+	// It takes effect from the next round which nobody has arrived.
+	SetRoundTimeout(d time.Duration) Barrier
+
+	// Register adds a party to the barrier, and returns its handle.
+	// Like Deregister, the change takes effect on the current round, if
+	// nobody has arrived in it, otherwise on the next round, so that
+	// the rounds in flight keep their parties.
+	// After Close, no party is added, and all the calls of the returned
+	// Participant return ErrClosed.
+	Register() Participant
+
+	// RegisterAs is Register, but the Wait of the participant is WaitAs name.
+	RegisterAs(name string) Participant
+
+	// Deregister removes a party from the barrier like Register.
+	// It returns ErrNonPositiveParticipants if it is the only party,
+	// and ErrClosed after Close.
+	Deregister() error
+
+	// OnBroken sets a hook, which is called with the cause once a round is
+	// broken, before the waiting goroutines are notified.
+	// It is called by the goroutine breaking the round, and at most once
+	// per round. The cause is ErrBroken for Break, or the error returned
+	// by Wait for the context cancellation.
+	OnBroken(func(cause error)) Barrier
+
+	// OnRelease adds a hook, which is called with the info of every
+	// successful round, after its waiting goroutines are released.
+	// The hooks are called in registration order by a new goroutine, so
+	// they never delay the release. nil hook is ignored.
+	OnRelease(func(RoundInfo)) Barrier
+
+	// SetObserver sets the observer, which is notified of every arrival,
+	// successful trip and break of the rounds. nil means no observer.
+	// A DurationObserver is notified of the durations as well.
+	SetObserver(Observer) Barrier
+
+	// Events returns a channel, which receives a RoundEvent every time a
+	// round completes, successfully or broken.
+	// Sending never blocks the barrier. The channel buffers 16 events, and
+	// the events are dropped while the buffer is full, so keep receiving.
+	// Every call returns a new subscription, which is stopped by
+	// EventSubscriber.Unsubscribe, if the Barrier implements it.
+	// The channels are closed by Close, and Events returns a closed channel
+	// after Close.
+	Events() <-chan RoundEvent
+}
+
+// FanoutReporter is implemented by the Barrier returned by New, which
+// measures the release of its rounds. Check it by a type assertion, like
+//
+//	if r, ok := b.(FanoutReporter); ok {
+//		log.Println(r.LastReleaseFanoutDuration())
+//	}
+type FanoutReporter interface {
+	// LastReleaseFanoutDuration returns how long it took, in the latest
+	// successful round, from the release of the waiting goroutines
+	// until all of them have returned from Wait.
+	LastReleaseFanoutDuration() time.Duration
+}
+
+// CauseBreaker is implemented by the Barrier returned by New, which can
+// be broken with a cause. Check it by a type assertion, like FanoutReporter.
+type CauseBreaker interface {
+	// BreakWith is Break with why the round is broken. The other
+	// participants of the round return a *BrokenError with the cause.
+	BreakWith(cause error)
+}
+
+// TryArriver is implemented by the Barrier returned by New, which can be
+// arrived without waiting. Check it by a type assertion, like FanoutReporter.
+type TryArriver interface {
+	// TryArrive arrives the current round without waiting for the others,
+	// like the arrive of java Phaser, so the round does not wait for it.
+	// If it is the last arrived one, it trips the round, and returns true
+	// with the result of the round, otherwise it returns false at once.
+	// Unlike TryWait, it always arrives.
+	TryArrive() (tripped bool, err error)
+}
+
+// ChanWaiter is implemented by the Barrier returned by New, whose release
+// can be selected with other channels. Check it by a type assertion, like
+// FanoutReporter.
+type ChanWaiter interface {
+	// WaitChan is Wait, which arrives at once, but returns a channel
+	// receiving the result of the round, instead of blocking, so that the
+	// release can be selected with other channels.
+	// The channel receives exactly one RoundResult.
+	WaitChan(ctx context.Context) <-chan RoundResult
 }
 
 // New initializes a new instance of the Barrier, specifying the number of parties.
-func New(participants int) Barrier {
+// It panics if participants is not positive, or more than 1<<22, or opts
+// has an option not supported by the Implementation.
+func New(participants int, opts ...Option) Barrier {
 	if participants <= 0 {
 		panic(nonPositiveParticipants)
 	}
-	return &barrier{
-		participants: participants,
-		lock:         sync.RWMutex{},
-		round:        newRound(),
+	if participants > maxParticipants {
+		panic(participantsOutOfRange)
+	}
+	b := &barrier{
+		participants:    participants,
+		lock:            sync.RWMutex{},
+		tickets:         make(map[int]*round),
+		panicOnOverflow: true,
+		createdAt:       time.Now(),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.implementation == SenseReversing {
+		return newSenseBarrier(b)
+	}
+	b.setRound(b.newRound())
+	b.thaw()
+	return b
+}
+
+// NewQuorum initializes a new instance of the Barrier, whose rounds trip
+// once quorum of the participants have arrived, and release them.
+// The participants arriving after that, called stragglers, join the
+// tripped round, and return its result at once without waiting. The next
+// round begins after all the participants have arrived, so a goroutine
+// waiting again before that arrives the same round as a straggler.
+// The stragglers of a broken round return its error.
+// It panics if quorum is not in [1, participants].
+func NewQuorum(participants, quorum int, opts ...Option) Barrier {
+	if quorum <= 0 || quorum > participants {
+		panic(ErrQuorumOutOfRange.Error())
+	}
+	opts = append(opts, func(b *barrier) {
+		b.quorum = quorum
+	})
+	return New(participants, opts...)
+}
+
+// NewStrict is New with the policy for the goroutines arriving more than
+// participants in a round. If panicOnOverflow is false, the extra arrival
+// does not count, and returns ErrTooManyParties instead of panic.
+func NewStrict(participants int, panicOnOverflow bool) Barrier {
+	if panicOnOverflow {
+		return New(participants)
 	}
+	return New(participants, WithoutOverflowPanic())
+}
+
+// RoundResult is the result of a round received from WaitChan.
+type RoundResult struct {
+	Err error // nil if the round completes successfully, like Wait
+}
+
+// Stats is the statistics of a Barrier across rounds.
+type Stats struct {
+	CompletedRounds uint64    // rounds completed successfully
+	BrokenRounds    uint64    // rounds broken
+	PartiesServed   uint64    // arrivals of the completed and broken rounds
+	LastCompletion  time.Time // when the latest round completed successfully
 }
 
 // barrier implements Barrier interface
 type barrier struct {
-	participants int
-	lock         sync.RWMutex
-	action       func()
-	round        *round // every round has a new round
+	participants    int
+	lock            sync.RWMutex
+	actions         []func(context.Context) error
+	round           *round       // every round has a new round
+	shards          []shard      // of the arrival counter, with WithSharding
+	stripes         sync.Pool    // of the shards, GOMAXPROCS stripes of them
+	stripe          uint32       // the latest stripe, atomic
+	current         atomic.Value // round, for the lock-free arrivals
+	generation      uint64       // of the latest round
+	batch           int          // release waiting goroutines batch by batch if batch > 0
+	fanout          int64        // nanoseconds of the latest release fan-out, atomic
+	closed          bool
+	rounds          uint64 // count of completed rounds
+	stats           Stats
+	collector       func([]interface{}) (interface{}, error) // runs before action with contributions of the round
+	onBroken        func(cause error)
+	onRelease       []func(RoundInfo)
+	observer        Observer
+	timed           int32 // the observer is a DurationObserver, atomic
+	createdAt       time.Time
+	name            string         // set by WithName, for diagnostics
+	ticket          int            // the latest token returned by Arrive
+	tickets         map[int]*round // rounds of tokens not passed to AwaitRelease yet
+	panicOnOverflow bool           // panic if more than participants arrive in a round
+	queueOverflow   bool           // the arrivals more than participants join the next round
+	subscribers     []subscriber
+	recoverAfter    time.Duration // reset the broken round after it, if > 0
+	sticky          bool          // keep broken until Reset
+	roundTimeout    time.Duration // break the round if it does not trip in time after the first arrival
+	watchdog        time.Duration // like roundTimeout, and reports the diagnostics
+	report          func(Diagnostics)
+	debug           bool          // record the arrivals and their stacks for DebugDump
+	stallAfter      time.Duration // warn if the round does not trip in time after the first arrival
+	warn            func(Stall)
+	cancelPolicy    CancelPolicy
+	parking         sync.Mutex     // guards done and woken of the rounds with CondBased
+	spins           int            // times to spin before parking, set by WithSpin
+	parked          *sync.Cond     // the goroutines park on it with CondBased, or nil
+	quorum          int            // trip the rounds with quorum parties, if > 0
+	stuck           error          // why the barrier is broken, if sticky
+	implementation  Implementation // set by WithImplementation
+	broken          int32          // 1 if the current round is broken, or stuck is not nil, atomic
 }
 
 // round is a cycle of using barrier
 // if any goroutine call Barrier.Break, this round is Broken
 type round struct {
-	isBroken bool
-	count    int           // count of goroutines has arrived barrier
-	success  chan struct{} // broadcast success result using close(success)
-	broken   chan struct{} // broadcast broken status using close(borken)
+	state uint64 // generation, frozen, awaited and count of the round, packed by the layout below, atomic
+	size  int32  // parties for the lock-free arrivals, atomic
+	roundData
+}
+
+// roundData is the round but its atomic words, which are kept across the
+// reuse of the round, so that a stale arrival can not mistake a reused
+// round for the one it has loaded.
+type roundData struct {
+	isBroken    bool
+	isTripped   bool                        // all participants have arrived, waiting for release
+	isCompleted bool                        // counted by completeRound
+	parties     int                         // b.participants when the round begins
+	quorum      int                         // parties by default, lowered by WaitN
+	action      func(context.Context) error // actions of the barrier when the round begins
+	done        chan struct{}               // closed once the round is released or broken, allocated on demand with CondBased
+	woken       int32                       // 1 once released or broken, atomic, guarded by b.parking as well with CondBased
+	batches     []batch                     // staggered release, nil if releasing at once
+	releasedAt  time.Time                   // when the release began
+	pending     int32                       // count of released goroutines not returned yet, including the last arrived one
+	detached    int32                       // count of the arrived goroutines not waiting for release
+	values      []interface{}               // contributions of participants in arrival order, nil if nobody contributes
+	result      interface{}                 // computed by b.collector
+	err         error                       // why the round is broken
+	recovery    *time.Timer                 // resets the broken round with WithAutoRecover
+	expiry      *time.Timer                 // breaks the round with SetRoundTimeout
+	watchdog    *time.Timer                 // breaks the round with WithWatchdog
+	stall       *time.Timer                 // warns about the round with WithStallWarning
+	sharded     bool                        // the slots of the round are laid out in shards
+	shards      []shard                     // b.shards, with WithSharding
+	stolen      int                         // shard of the latest slot stolen by an arrival with the lock
+	arrivals    []time.Time                 // when the participants arrived, only with WithWatchdog or WithDebug
+	stacks      [][]byte                    // stacks of the participants at arrival, only with WithDebug
+	names       []string                    // names of the participants, only with WaitAs
+	task        *trace.Task                 // of the round, only when tracing is enabled at its beginning
+	taskCtx     context.Context             // carries task
 }
 
-func newRound() *round {
-	return &round{
-		success: make(chan struct{}),
-		broken:  make(chan struct{}),
+// The layout of round.state. count is the number of goroutines arrived r,
+// awaited is the number of them waiting for release, and r trips once it
+// reaches quorum. The arrivals beyond parties are counted until they are
+// undone, so the fields have a bit more than maxParticipants needs.
+// frozen stops the lock-free arrivals, and generation tells the reuses of
+// the round apart.
+const (
+	countBits       = 24
+	countMask       = 1<<countBits - 1
+	frozen          = 1 << (2 * countBits)
+	generationShift = 2*countBits + 1
+	maxParticipants = 1 << (countBits - 2)
+)
+
+// counts returns the count and awaited of r.
+func (r *round) counts() (count, awaited int) {
+	state := atomic.LoadUint64(&r.state)
+	count, awaited = int(state&countMask), int(state>>countBits&countMask)
+	// the sharded arrivals have not been combined into r yet.
+	for i := range r.shards {
+		pending := r.shards[i].pending(state >> generationShift)
+		count += pending
+		awaited += pending
 	}
+	return count, awaited
 }
 
-func (r *round) newComer() (count int, success, broken chan struct{}) {
-	r.count++
-	count = r.count
-	success = r.success
-	broken = r.broken
-	return
+// count returns the number of the goroutines arrived r.
+func (r *round) count() int {
+	count, _ := r.counts()
+	return count
 }
 
-func (b *barrier) Wait(ctx context.Context) (err error) {
-	count, success, broken := b.newComer()
-	if count < b.participants {
-		// wait other participants
-		select {
-		case <-success:
-			return nil
-		case <-broken:
-			return ErrBroken
-		case <-ctx.Done():
-			b.breakRound()
-			return fmt.Errorf("barrier is broken: %w", ctx.Err())
+// awaited returns the number of the goroutines waiting for the release of r.
+func (r *round) awaited() int {
+	_, awaited := r.counts()
+	return awaited
+}
+
+// add adds count and awaited, which may be negative to undo an arrival,
+// to r, and returns the new ones.
+func (r *round) add(count, awaited int) (int, int) {
+	state := atomic.AddUint64(&r.state, uint64(int64(count)+int64(awaited)<<countBits))
+	return int(state & countMask), int(state >> countBits & countMask)
+}
+
+// freeze stops the lock-free arrivals of r.
+func (r *round) freeze() {
+	for {
+		state := atomic.LoadUint64(&r.state)
+		if state&frozen != 0 || atomic.CompareAndSwapUint64(&r.state, state, state|frozen) {
+			return
 		}
 	}
-	if count == b.participants {
-		if b.IsBroken() {
-			err = ErrBroken
+}
+
+// thaw allows the lock-free arrivals of r.
+func (r *round) thaw() {
+	for {
+		state := atomic.LoadUint64(&r.state)
+		if state&frozen == 0 || atomic.CompareAndSwapUint64(&r.state, state, state&^frozen) {
+			return
 		}
-		b.lastArrived()
 	}
+}
+
+// cause returns why r is broken.
+// It should be called after r.done is closed, and r is broken.
+func (r *round) cause() error {
+	if r.err == nil {
+		return ErrBroken
+	}
+	return r.err
+}
+
+// hasArrived reports whether the participant of name is one of the
+// first count-1 arrivals of r.
+func (r *round) hasArrived(name string, count int) bool {
+	for i := 0; i < count-1 && i < len(r.names); i++ {
+		if r.names[i] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// stopTimers stops the timers of r, because r is completing.
+func (r *round) stopTimers() {
+	for _, t := range []*time.Timer{r.recovery, r.expiry, r.watchdog, r.stall} {
+		if t != nil {
+			t.Stop()
+		}
+	}
+}
+
+// roundPool recycles the rounds completed successfully.
+var roundPool = sync.Pool{
+	New: func() interface{} {
+		return new(round)
+	},
+}
+
+func (b *barrier) newRound() *round {
+	r := roundPool.Get().(*round)
+	r.roundData = roundData{
+		parties: b.participants,
+		quorum:  b.participants,
+		action:  chain(b.actions),
+		shards:  b.shards,
+	}
+	if b.quorum > 0 {
+		r.quorum = b.quorum
+	}
+	if b.parked == nil {
+		r.done = make(chan struct{})
+	}
+	if b.watchdog > 0 || b.debug {
+		r.arrivals = make([]time.Time, b.participants)
+	}
+	if b.debug {
+		r.stacks = make([][]byte, b.participants)
+	}
+	if trace.IsEnabled() {
+		r.taskCtx, r.task = trace.NewTask(context.Background(), "barrier round")
+	}
+	if waiters := b.participants - 1; b.batch > 0 && waiters > b.batch {
+		r.batches = make([]batch, (waiters+b.batch-1)/b.batch)
+		for i := range r.batches {
+			r.batches[i] = batch{
+				released:  make(chan struct{}),
+				scheduled: make(chan struct{}),
+			}
+		}
+	}
+	// the state is stored the last, so the lock-free arrivals loading it
+	// see the rest of r. r is frozen until b.unlockArrivals thaws it.
+	b.generation++
+	atomic.StoreInt32(&r.size, int32(b.participants))
+	atomic.StoreUint64(&r.state, b.generation<<generationShift|frozen)
+	return r
+}
+
+// setRound makes r the current round of b.
+// It should be called with b.lock held.
+func (b *barrier) setRound(r *round) {
+	b.round = r
+	b.current.Store(r)
+}
+
+// lockArrivals locks b, and freezes the current round, so that the
+// lock-free arrivals wait for the lock as well.
+// The options may lock b before its first round.
+func (b *barrier) lockArrivals() {
+	b.lock.Lock()
+	if b.round != nil {
+		b.round.freeze()
+		if b.round.sharded {
+			b.freezeShards(b.round)
+		}
+	}
+}
+
+// unlockArrivals thaws the current round, if its arrivals can be lock-free,
+// and unlocks b.
+func (b *barrier) unlockArrivals() {
+	b.thaw()
+	b.lock.Unlock()
+}
+
+// thaw thaws the current round and the shards, if its arrivals can be
+// lock-free.
+// It should be called with b.lock held.
+func (b *barrier) thaw() {
+	if b.round == nil || !b.isLockFree() {
+		return
+	}
+	if b.shards != nil {
+		b.thawShards(b.round)
+	}
+	b.round.thaw()
+}
+
+// isLockFree reports whether the plain arrivals of the current round only
+// need to count, so that they can arrive without the lock.
+// It should be called with b.lock held.
+func (b *barrier) isLockFree() bool {
+	return !b.closed && b.stuck == nil && b.observer == nil && len(b.subscribers) == 0 &&
+		b.roundTimeout <= 0 && b.watchdog <= 0 && b.stallAfter <= 0 && !b.debug &&
+		b.quorum == 0 && !b.queueOverflow && b.round.quorum == b.round.parties
+}
+
+// arriveLockFree arrives the current round by a compare-and-swap of its
+// state, and returns the round, the count and awaited after the arrival.
+// It is not ok, if the round is frozen or full, and the arrival should
+// take the lock instead.
+func (b *barrier) arriveLockFree() (r *round, count, awaited int, ok bool) {
+	for {
+		r = b.current.Load().(*round)
+		state := atomic.LoadUint64(&r.state)
+		if state&frozen != 0 {
+			return nil, 0, 0, false
+		}
+		if b.shards != nil {
+			// the slots of the shards are never the last one of r.
+			slot, ok := b.arriveShard(state >> generationShift)
+			return r, slot, slot, ok
+		}
+		size := int(atomic.LoadInt32(&r.size))
+		count, awaited = int(state&countMask), int(state>>countBits&countMask)
+		if count >= size {
+			return nil, 0, 0, false
+		}
+		if atomic.CompareAndSwapUint64(&r.state, state, state+1+1<<countBits) {
+			return r, count + 1, awaited + 1, true
+		}
+	}
+}
+
+// batchReleased is added to batch.state once the batch is released.
+const batchReleased = 1 << 30
+
+// batch is the goroutines of a round released together by
+// WithStaggeredWakeup.
+type batch struct {
+	released  chan struct{} // closed to release the batch
+	scheduled chan struct{} // closed once the blocked goroutines have been resumed
+	state     int32         // count of the blocked goroutines, plus batchReleased once released, atomic
+}
+
+// block records that a goroutine is going to block on bt.released.
+// It reports false if bt has been released, then the goroutine is not
+// waited by release.
+func (bt *batch) block() bool {
+	for {
+		state := atomic.LoadInt32(&bt.state)
+		if state >= batchReleased {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&bt.state, state, state+1) {
+			return true
+		}
+	}
+}
+
+// resume records that a blocked goroutine has stopped waiting for bt.
+func (bt *batch) resume() {
+	if atomic.AddInt32(&bt.state, -1) == batchReleased {
+		close(bt.scheduled)
+	}
+}
+
+// batchOf returns the batch of the count-th arrived goroutine of r,
+// nil if r is released at once.
+func (b *barrier) batchOf(r *round, count int) *batch {
+	if r.batches == nil {
+		return nil
+	}
+	i := (count - 1) / b.batch
+	if i >= len(r.batches) {
+		// the last arrived goroutine waits, if the round trips by AwaitRelease.
+		i = len(r.batches) - 1
+	}
+	return &r.batches[i]
+}
+
+// release wakes up the waiting goroutines of a tripped round.
+// A batch is released after the goroutines of the previous one have
+// been scheduled.
+func (b *barrier) release(r *round) {
+	r.releasedAt = time.Now()
+	for i := range r.batches {
+		bt := &r.batches[i]
+		blocked := atomic.AddInt32(&bt.state, batchReleased) - batchReleased
+		close(bt.released)
+		if blocked > 0 {
+			<-bt.scheduled
+		}
+	}
+	b.wake(r)
+}
+
+// wake broadcasts that r is released or broken.
+func (b *barrier) wake(r *round) {
+	if b.parked == nil {
+		atomic.StoreInt32(&r.woken, 1)
+		close(r.done)
+		return
+	}
+	b.parking.Lock()
+	atomic.StoreInt32(&r.woken, 1)
+	if r.done != nil {
+		close(r.done)
+	}
+	b.parking.Unlock()
+	b.parked.Broadcast()
+}
+
+// doneOf returns the done channel of r, which is allocated on demand
+// with CondBased.
+func (b *barrier) doneOf(r *round) <-chan struct{} {
+	if b.parked == nil {
+		return r.done
+	}
+	b.parking.Lock()
+	defer b.parking.Unlock()
+	if r.done == nil {
+		r.done = make(chan struct{})
+		if atomic.LoadInt32(&r.woken) != 0 {
+			close(r.done)
+		}
+	}
+	return r.done
+}
+
+// awake waits until one of released and done is closed.
+func awake(released, done <-chan struct{}) {
+	select {
+	case <-released:
+	case <-done:
+	}
+}
+
+// spin waits until r is woken, by yielding the processor at most b.spins
+// times, and reports whether r is woken.
+func (b *barrier) spin(r *round) bool {
+	for i := 0; i < b.spins; i++ {
+		if atomic.LoadInt32(&r.woken) != 0 {
+			return true
+		}
+		runtime.Gosched()
+	}
+	return atomic.LoadInt32(&r.woken) != 0
+}
+
+// park waits on b.parked until r is released or broken.
+func (b *barrier) park(r *round) {
+	b.parking.Lock()
+	for atomic.LoadInt32(&r.woken) == 0 {
+		b.parked.Wait()
+	}
+	b.parking.Unlock()
+}
+
+// returned records a released goroutine has returned from Wait.
+// Once all of them have returned, nobody refers to r, so it is recycled.
+func (b *barrier) returned(r *round) {
+	if atomic.AddInt32(&r.pending, -1) == 0 {
+		atomic.StoreInt64(&b.fanout, int64(time.Since(r.releasedAt)))
+		roundPool.Put(r)
+	}
+}
+
+func (b *barrier) Wait(ctx context.Context) error {
+	_, _, err := b.wait(ctx, nil, 0, 0)
+	return err
+}
+
+func (b *barrier) WaitAs(ctx context.Context, name string) error {
+	return b.Wait(context.WithValue(ctx, participantName{}, name))
+}
+
+func (b *barrier) WaitN(ctx context.Context, k int) error {
+	if k <= 0 {
+		return ErrQuorumOutOfRange
+	}
+	_, _, err := b.wait(ctx, nil, 0, k)
+	return err
+}
+
+func (b *barrier) WaitWeighted(ctx context.Context, n int) error {
+	if n <= 0 {
+		return ErrInvalidWeight
+	}
+	if atomic.LoadInt32(&b.timed) != 0 {
+		defer b.waited(time.Now())
+	}
+	r, count, last, err := b.arriveWeighted(ctx, n)
+	if err != nil {
+		return err
+	}
+	if last {
+		return b.lastArrivedCtx(ctx, r)
+	}
+	_, err = b.await(ctx, r, count, 0)
+	return err
+}
+
+// arriveWeighted arrives the current round as n parties.
+// It is newComer for the weighted arrival, which is not a hot path,
+// so it always holds the write lock.
+func (b *barrier) arriveWeighted(ctx context.Context, n int) (r *round, count int, last bool, err error) {
+	if b.isActing(ctx) {
+		return nil, 0, false, ErrReentrantWait
+	}
+	for {
+		b.lockArrivals()
+		switch {
+		case b.closed:
+			err = ErrClosed
+		case b.stuck != nil:
+			err = b.stuck
+		}
+		if err != nil {
+			b.unlockArrivals()
+			return nil, 0, false, err
+		}
+		r = b.round
+		if r.awaited() >= r.quorum {
+			// r is completing, arrive the next round.
+			done := b.doneOf(r)
+			b.unlockArrivals()
+			<-done
+			runtime.Gosched() // r is going to be reset, if it is broken
+			continue
+		}
+		if r.count()+n > r.parties {
+			b.unlockArrivals()
+			return nil, 0, false, ErrTooManyParties
+		}
+		if r.count() == 0 {
+			b.startTimers(r)
+		}
+		for i := r.count(); i < r.count()+n && r.arrivals != nil; i++ {
+			r.arrivals[i] = time.Now()
+		}
+		var awaited int
+		count, awaited = r.add(n, n)
+		for i := count - n + 1; r.sharded && i <= count && i < r.parties; i++ {
+			b.steal(r)
+		}
+		last = awaited >= r.quorum
+		// only one goroutine returns for the n parties.
+		r.detached += int32(n - 1)
+		observer := b.observer
+		b.emit(EventArrived, b.rounds+1, count, nil)
+		b.unlockArrivals()
+		if observer != nil {
+			observer.OnArrive(count)
+		}
+		return r, count, last, nil
+	}
+}
+
+func (b *barrier) WaitChan(ctx context.Context) <-chan RoundResult {
+	ch := make(chan RoundResult, 1)
+	timed, start := atomic.LoadInt32(&b.timed) != 0, time.Now()
+	send := func(err error) {
+		if timed {
+			b.waited(start)
+		}
+		ch <- RoundResult{Err: err}
+	}
+	r, count, _, last, err := b.newComer(ctx, nil, "", true, 0)
+	switch {
+	case err != nil:
+		send(err)
+	case last:
+		send(b.lastArrivedCtx(ctx, r))
+	default:
+		go func() {
+			_, err := b.await(ctx, r, count, 0)
+			send(err)
+		}()
+	}
+	return ch
+}
+
+func (b *barrier) WaitExchange(ctx context.Context, mine interface{}) ([]interface{}, error) {
+	out, _, err := b.wait(ctx, mine, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	values := out.values
+	if values == nil {
+		// nobody contributes a non-nil value.
+		values = make([]interface{}, b.Participants())
+	}
+	return values, nil
+}
+
+func (b *barrier) WaitAction(ctx context.Context, action func() error) error {
+	if atomic.LoadInt32(&b.timed) != 0 {
+		defer b.waited(time.Now())
+	}
+	r, count, _, last, err := b.newComer(ctx, nil, "", true, 0)
+	if err != nil {
+		return err
+	}
+	if !last {
+		_, err = b.await(ctx, r, count, 0)
+		return err
+	}
+	if action != nil {
+		b.lockArrivals()
+		r.action = func(context.Context) error {
+			return action()
+		}
+		b.unlockArrivals()
+	}
+	return b.lastArrivedCtx(ctx, r)
+}
+
+func (b *barrier) WaitIndexed(ctx context.Context) (int, error) {
+	index, _, err := b.waitIndexed(ctx)
+	return index, err
+}
+
+func (b *barrier) WaitIndex(ctx context.Context) (int, error) {
+	index, parties, err := b.waitIndexed(ctx)
+	return parties - index, err
+}
+
+// waitIndexed waits, and returns the 1-based arrival index of the caller,
+// and the parties of the round.
+func (b *barrier) waitIndexed(ctx context.Context) (index, parties int, err error) {
+	if atomic.LoadInt32(&b.timed) != 0 {
+		defer b.waited(time.Now())
+	}
+	r, count, index, last, err := b.newComer(ctx, nil, "", true, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	parties = r.parties
+	if last {
+		return index, parties, b.lastArrivedCtx(ctx, r)
+	}
+	_, err = b.await(ctx, r, count, 0)
+	return index, parties, err
+}
+
+func (b *barrier) WaitTimeout(d time.Duration) error {
+	_, _, err := b.wait(context.Background(), nil, d, 0)
+	return err
+}
+
+// wait contributes v to the round, and waits other participants
+// no more than timeout, if timeout > 0.
+// nil v means no contribution.
+// The round trips once quorum goroutines are waiting, if quorum > 0.
+// It returns the outcome of the round and the arrival count of the caller.
+func (b *barrier) wait(ctx context.Context, v interface{}, timeout time.Duration, quorum int) (out outcome, count int, err error) {
+	if atomic.LoadInt32(&b.timed) != 0 {
+		defer b.waited(time.Now())
+	}
+	r, count, _, last, err := b.newComer(ctx, v, nameOf(ctx), true, quorum)
+	if err != nil {
+		return
+	}
+	if r.task != nil {
+		defer trace.StartRegion(r.taskCtx, "waiting on barrier").End()
+	}
+	if last {
+		out.values = r.values
+		out.result, err = b.tripCtx(ctx, r)
+		return
+	}
+	out, err = b.await(ctx, r, count, timeout)
 	return
 }
 
+// waited notifies the DurationObserver of the wait since start.
+func (b *barrier) waited(start time.Time) {
+	b.lock.RLock()
+	o, ok := b.observer.(DurationObserver)
+	b.lock.RUnlock()
+	if ok {
+		o.OnWaited(time.Since(start))
+	}
+}
+
+// outcome is what the participants take away from a released round,
+// because the round is recycled after all of them have returned.
+type outcome struct {
+	values []interface{} // contributions of the round
+	result interface{}   // computed by the collector of the round
+}
+
+// await waits the release of r for the count-th arrived goroutine.
+// It returns the outcome of r if r is released.
+func (b *barrier) await(ctx context.Context, r *round, count int, timeout time.Duration) (outcome, error) {
+	woken := b.spins > 0 && b.spin(r)
+	if !woken && b.parked != nil && r.batches == nil && timeout <= 0 && ctx.Done() == nil {
+		b.park(r)
+		woken = true
+	}
+	if woken {
+		if r.isBroken {
+			return outcome{}, r.cause()
+		}
+		out := outcome{values: r.values, result: r.result}
+		b.returned(r)
+		return out, nil
+	}
+	// released is done, unless the round is released batch by batch.
+	done := b.doneOf(r)
+	released, bt, blocked := done, b.batchOf(r, count), false
+	if bt != nil {
+		released, blocked = bt.released, bt.block()
+	}
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	err := b.waitRelease(ctx, r, released, done, expired)
+	if blocked {
+		// the batch of the goroutine has been scheduled.
+		bt.resume()
+	}
+	if err != nil {
+		return outcome{}, err
+	}
+	// the goroutine is pending, so r is not recycled yet.
+	if r.isBroken {
+		return outcome{}, r.cause()
+	}
+	out := outcome{values: r.values, result: r.result}
+	b.returned(r)
+	return out, nil
+}
+
+// waitRelease waits until r is released, or broken, for a goroutine of r.
+// It returns the error of the goroutine, if it breaks r, or leaves r,
+// before r trips.
+func (b *barrier) waitRelease(ctx context.Context, r *round, released, done <-chan struct{}, expired <-chan time.Time) error {
+	select {
+	case <-released:
+	case <-done:
+	case <-ctx.Done():
+		if b.leavesQuietly(ctx) && b.leave(r, false, nameOf(ctx)) {
+			return ctx.Err()
+		}
+		if b.breakRound(r, ctx.Err(), nil, nameOf(ctx)) {
+			return r.cause()
+		}
+		// the round has tripped already, it is released, or broken by the action.
+		awake(released, done)
+	case <-expired:
+		if b.breakRound(r, ErrTimeout, nil, nameOf(ctx)) {
+			return r.cause()
+		}
+		awake(released, done)
+	}
+	return nil
+}
+
 func (b *barrier) Break() {
-	count, _, _ := b.newComer()
-	b.breakRound()
-	if count == b.participants {
-		b.lastArrived()
+	b.BreakCtx(context.Background())
+}
+
+func (b *barrier) BreakWith(cause error) {
+	b.breakCtx(context.Background(), cause)
+}
+
+func (b *barrier) BreakCtx(ctx context.Context) error {
+	return b.breakCtx(ctx, nil)
+}
+
+// breakCtx arrives, and breaks the round with cause.
+// nil cause means ErrBroken.
+func (b *barrier) breakCtx(ctx context.Context, cause error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r, _, _, last, err := b.newComer(ctx, nil, "", true, 0)
+	if err != nil {
+		return err
+	}
+	if cause == nil {
+		cause = ErrBroken
+	}
+	b.breakRound(r, cause, cause, nameOf(ctx))
+	if last {
+		b.lastArrived(ctx, r)
 	}
+	return nil
 }
 
-// lastArrived to do action and reset
-func (b *barrier) lastArrived() {
+func (b *barrier) Arrive() (int, error) {
+	r, _, _, _, err := b.newComer(context.Background(), nil, "", false, 0)
+	if err != nil {
+		return 0, err
+	}
+	b.lockArrivals()
+	b.ticket++
+	token := b.ticket
+	b.tickets[token] = r
+	b.unlockArrivals()
+	return token, nil
+}
+
+func (b *barrier) AwaitRelease(ctx context.Context, token int) error {
+	if atomic.LoadInt32(&b.timed) != 0 {
+		defer b.waited(time.Now())
+	}
+	b.lockArrivals()
+	r, ok := b.tickets[token]
+	if !ok {
+		b.unlockArrivals()
+		return ErrInvalidToken
+	}
+	delete(b.tickets, token)
+	_, count := r.add(0, 1)
+	last := count == r.quorum
+	b.unlockArrivals()
+	if last {
+		return b.lastArrivedCtx(ctx, r)
+	}
+	_, err := b.await(ctx, r, count, 0)
+	return err
+}
+
+func (b *barrier) TryWait() (bool, error) {
+	b.lockArrivals()
+	if b.closed {
+		b.unlockArrivals()
+		return false, ErrClosed
+	}
+	if b.stuck != nil {
+		b.unlockArrivals()
+		return false, b.stuck
+	}
+	r := b.round
+	// the round may have tripped, but not been reset yet.
+	if count, awaited := r.counts(); count >= r.parties || awaited+1 != r.quorum {
+		b.unlockArrivals()
+		return false, nil
+	}
+	if count, _ := r.add(1, 1); r.sharded && count < r.parties {
+		b.steal(r)
+	}
+	b.unlockArrivals()
+	return true, b.lastArrived(context.Background(), r)
+}
+
+func (b *barrier) TryArrive() (bool, error) {
+	r, _, _, last, err := b.newComer(context.Background(), nil, "", true, 0)
+	if err != nil {
+		return false, err
+	}
+	if last {
+		return true, b.lastArrived(context.Background(), r)
+	}
+	b.lockArrivals()
+	if !r.isTripped {
+		r.detached++
+		b.unlockArrivals()
+		return false, nil
+	}
+	b.unlockArrivals()
+	// r has counted the arrival to return, when it tripped.
+	b.returned(r)
+	return false, nil
+}
+
+// leavingQuietly is the key of the context value, which makes the
+// goroutines waiting with the context leave quietly, like LeaveQuietly.
+type leavingQuietly struct{}
+
+// participantName is the key of the context value, which is the name of
+// the participant waiting with the context, set by WaitAs.
+type participantName struct{}
+
+// nameOf returns the name of the participant waiting with ctx.
+func nameOf(ctx context.Context) string {
+	name, _ := ctx.Value(participantName{}).(string)
+	return name
+}
+
+// leavesQuietly reports whether the goroutine waiting with ctx leaves the
+// round quietly, when ctx is done.
+func (b *barrier) leavesQuietly(ctx context.Context) bool {
+	return b.cancelPolicy == LeaveQuietly || ctx.Value(leavingQuietly{}) != nil
+}
+
+// lastArrivedCtx is lastArrived, but breaks r at first if ctx is done.
+func (b *barrier) lastArrivedCtx(ctx context.Context, r *round) error {
+	_, err := b.tripCtx(ctx, r)
+	return err
+}
+
+// tripCtx is trip, but breaks r at first if ctx is done.
+func (b *barrier) tripCtx(ctx context.Context, r *round) (interface{}, error) {
+	if ctx.Err() != nil {
+		if b.leavesQuietly(ctx) && b.leave(r, true, nameOf(ctx)) {
+			return nil, ctx.Err()
+		}
+		b.breakRound(r, ctx.Err(), nil, nameOf(ctx))
+	}
+	return b.trip(ctx, r)
+}
+
+// leave withdraws an arrival waiting for r, so that r does not wait for it.
+// last means the arrival is the last arrived one, which should trip r.
+// It fails if r has broken, or is tripping by another, or the arrival
+// has contributed. name is the name of the arrival, if any.
+// It reports whether the arrival has left.
+func (b *barrier) leave(r *round, last bool, name string) bool {
+	b.lockArrivals()
+	defer b.unlockArrivals()
+	// the last arrived goroutine may not have tripped r yet.
+	isTripping := r.awaited() >= r.quorum && !last
+	if r.isBroken || r.isTripped || isTripping || r.values != nil {
+		return false
+	}
+	for i := range r.names {
+		if r.names[i] == name && name != "" {
+			// so that it can arrive again.
+			r.names[i] = ""
+		}
+	}
+	b.undo(r, true, false)
+	return true
+}
+
+// lastArrived trips the round, does action and reset.
+// After r is tripped, only the action can break it.
+// It returns nil if r completes successfully, else why r is broken.
+func (b *barrier) lastArrived(ctx context.Context, r *round) error {
+	_, err := b.trip(ctx, r)
+	return err
+}
+
+// trip is lastArrived, and returns the result of r as well.
+func (b *barrier) trip(ctx context.Context, r *round) (interface{}, error) {
 	// b.resetRound()
-	if b.action != nil {
-		b.action()
+	b.lockArrivals()
+	r.isTripped = true
+	r.stopTimers()
+	// the later arrivals go to the next round, all the arrived will return.
+	r.pending = int32(r.count()) - r.detached
+	if b.quorum > 0 {
+		// the stragglers will return as well.
+		r.pending = int32(r.parties) - r.detached
+	}
+	action := r.action
+	timer, _ := b.observer.(DurationObserver)
+	b.unlockArrivals()
+	if b.collector != nil {
+		action = b.collectBefore(r, action)
+	}
+	var panicErr error
+	if action != nil {
+		start := time.Now()
+		var region *trace.Region
+		if r.task != nil {
+			region = trace.StartRegion(r.taskCtx, "barrier action")
+		}
+		isPanic, err := doAction(ctx, action)
+		if region != nil {
+			region.End()
+		}
+		if timer != nil {
+			timer.OnAction(time.Since(start))
+		}
+		if err != nil {
+			others := err // wrapped in the errors returned by the goroutines of the round
+			if isPanic {
+				panicErr, others = err, nil
+			}
+			b.lockArrivals()
+			broadcast := b.breakWith(r, others, err)
+			b.unlockArrivals()
+			broadcast()
+		}
+	}
+	var err error
+	switch {
+	case panicErr != nil:
+		err = panicErr
+	case r.isBroken:
+		err = r.cause()
+	}
+	isReleased := !r.isBroken
+	result := r.result
+	b.resetRound(r) // TODO: 为什么把这一行移到上面去，程序就错误了。
+	if isReleased {
+		b.returned(r)
+	}
+	return result, err
+}
+
+// acting is the key of the context value, which marks the context
+// passed to the action of b by SetActionCtx.
+type acting struct {
+	b *barrier
+}
+
+// isActing reports whether ctx is passed to the action of b, or derived
+// from it, so that waiting on b with it would deadlock.
+func (b *barrier) isActing(ctx context.Context) bool {
+	return ctx.Value(acting{b}) != nil
+}
+
+// chain returns an action running actions in registration order.
+// It stops at the first error.
+func chain(actions []func(context.Context) error) func(context.Context) error {
+	switch len(actions) {
+	case 0:
+		return nil
+	case 1:
+		return actions[0]
+	}
+	return func(ctx context.Context) error {
+		for _, action := range actions {
+			if err := action(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// collectBefore returns an action, which runs b.collector with the
+// contributions of r before action, and keeps its result in r.
+func (b *barrier) collectBefore(r *round, action func(context.Context) error) func(context.Context) error {
+	return func(ctx context.Context) error {
+		values := r.values
+		if values == nil {
+			values = make([]interface{}, r.parties)
+		}
+		result, err := b.collector(values)
+		if err != nil {
+			return err
+		}
+		r.result = result
+		if action == nil {
+			return nil
+		}
+		return action(ctx)
+	}
+}
+
+// doAction calls action, and converts its panic to an error wrapping ErrActionPanic.
+func doAction(ctx context.Context, action func(context.Context) error) (isPanic bool, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			isPanic, err = true, fmt.Errorf("%w: %v", ErrActionPanic, p)
+		}
+	}()
+	return false, action(ctx)
+}
+
+func (b *barrier) Reset() {
+	b.lockArrivals()
+	if b.closed {
+		b.unlockArrivals()
+		return
+	}
+	r := b.round
+	// the tripped round will be reset by its last arrived goroutine soon.
+	if r.isTripped {
+		b.stuck = nil
+		b.syncBroken()
+		b.unlockArrivals()
+		return
 	}
-	b.resetRound() // TODO: 为什么把这一行移到上面去，程序就错误了。
+	broadcast := b.breakWith(r, nil, ErrBroken)
+	b.stuck = nil
+	b.completeRound(r)
+	b.emit(EventReset, b.rounds, r.count(), nil)
+	b.setRound(b.newRound())
+	b.syncBroken()
+	b.unlockArrivals()
+	broadcast()
+}
+
+func (b *barrier) NumberWaiting() (res int) {
+	b.lock.RLock()
+	res = b.round.count()
+	b.lock.RUnlock()
+	return
+}
+
+func (b *barrier) IsDrained() (res bool) {
+	b.lock.RLock()
+	res = b.round.count() == 0 && !b.round.isBroken
+	b.lock.RUnlock()
+	return
 }
 
-func (b *barrier) IsBroken() (res bool) {
+func (b *barrier) Round() (res uint64) {
 	b.lock.RLock()
-	res = b.round.isBroken
+	res = b.rounds
 	b.lock.RUnlock()
 	return
 }
 
+func (b *barrier) RoundNumber() uint64 {
+	return b.Round()
+}
+
+// String implements fmt.Stringer for debugging
+func (b *barrier) String() string {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	var name string
+	if b.name != "" {
+		name = "name:" + b.name + " "
+	}
+	return fmt.Sprintf("Barrier{%sparticipants:%d waiting:%d broken:%t round:%d}",
+		name, b.participants, b.round.count(), b.round.isBroken, b.rounds)
+}
+
+func (b *barrier) Stats() (res Stats) {
+	b.lock.RLock()
+	res = b.stats
+	b.lock.RUnlock()
+	return
+}
+
+func (b *barrier) Close() error {
+	b.lockArrivals()
+	if b.closed {
+		b.unlockArrivals()
+		return nil
+	}
+	b.closed = true
+	b.emit(EventClosed, b.rounds+1, b.round.count(), nil)
+	// the consumers of the events would block forever.
+	for _, s := range b.subscribers {
+		close(s.ch)
+	}
+	b.subscribers = nil
+	broadcast := noop
+	// the last arrived goroutine of the tripped round will reset it,
+	// the new round needs to be broken as well.
+	if !b.round.isTripped {
+		broadcast = b.breakWith(b.round, ErrClosed, ErrClosed)
+	}
+	b.unlockArrivals()
+	broadcast()
+	return nil
+}
+
+func (b *barrier) IsBroken() bool {
+	return atomic.LoadInt32(&b.broken) != 0
+}
+
+// syncBroken updates b.broken, after the current round or b.stuck changes.
+// It should be called with b.lock held.
+func (b *barrier) syncBroken() {
+	var broken int32
+	if b.round.isBroken || b.stuck != nil {
+		broken = 1
+	}
+	atomic.StoreInt32(&b.broken, broken)
+}
+
 // SetAction if you need
 // action will be execute by
 // the last **arrived** goroutine
 func (b *barrier) SetAction(action func()) Barrier {
-	b.lock.Lock()
-	b.action = action
-	b.lock.Unlock()
+	if action == nil {
+		return b.SetActionE(nil)
+	}
+	return b.SetActionE(func() error {
+		action()
+		return nil
+	})
+}
+
+func (b *barrier) SetActionE(action func() error) Barrier {
+	if action == nil {
+		return b.setAction()
+	}
+	return b.setAction(func(context.Context) error {
+		return action()
+	})
+}
+
+func (b *barrier) SetActionCtx(action func(context.Context) error) Barrier {
+	return b.setAction(b.withActing(action))
+}
+
+// withActing returns action, which receives its context marked as passed
+// to the action of b, or nil if action is nil.
+func (b *barrier) withActing(action func(context.Context) error) func(context.Context) error {
+	if action == nil {
+		return nil
+	}
+	return func(ctx context.Context) error {
+		return action(context.WithValue(ctx, acting{b}, true))
+	}
+}
+
+// setAction replaces the actions of b with actions, nil ones are ignored.
+func (b *barrier) setAction(actions ...func(context.Context) error) Barrier {
+	b.lockArrivals()
+	b.actions = nil
+	for _, action := range actions {
+		if action != nil {
+			b.actions = append(b.actions, action)
+		}
+	}
+	b.refreshAction()
+	b.unlockArrivals()
 	return b
 }
 
-// meetNewComer save returns in local variables to prevent race
-func (b *barrier) newComer() (count int, success, broken chan struct{}) {
-	b.lock.Lock()
-	b.round.count++
-	count = b.round.count
-	success = b.round.success
-	broken = b.round.broken
-	b.lock.Unlock()
+func (b *barrier) AddAction(action func() error) Barrier {
+	if action == nil {
+		return b
+	}
+	b.lockArrivals()
+	b.actions = append(b.actions, func(context.Context) error {
+		return action()
+	})
+	b.refreshAction()
+	b.unlockArrivals()
+	return b
+}
+
+// refreshAction applies the actions to the current round, if nobody has
+// arrived in it. Once a round begins, its action is fixed, the changes
+// of the actions take effect from the next round.
+// It should be called with b.lock held.
+func (b *barrier) refreshAction() {
+	// b.round is nil while New applies the options.
+	if b.round != nil && b.round.count() == 0 {
+		b.round.action = chain(b.actions)
+	}
+}
+
+func (b *barrier) SetRoundTimeout(d time.Duration) Barrier {
+	b.lockArrivals()
+	b.roundTimeout = d
+	b.unlockArrivals()
+	return b
+}
+
+func (b *barrier) SetObserver(o Observer) Barrier {
+	b.lockArrivals()
+	b.setObserver(o)
+	b.unlockArrivals()
+	return b
+}
+
+// setObserver sets the observer of b.
+// It should be called with b.lock held.
+func (b *barrier) setObserver(o Observer) {
+	b.observer = o
+	var timed int32
+	if _, ok := o.(DurationObserver); ok {
+		timed = 1
+	}
+	atomic.StoreInt32(&b.timed, timed)
+}
+
+func (b *barrier) OnRelease(hook func(RoundInfo)) Barrier {
+	if hook == nil {
+		return b
+	}
+	b.lockArrivals()
+	b.onRelease = append(b.onRelease, hook)
+	b.unlockArrivals()
+	return b
+}
+
+func (b *barrier) OnBroken(hook func(cause error)) Barrier {
+	b.lockArrivals()
+	b.onBroken = hook
+	b.unlockArrivals()
+	return b
+}
+
+func (b *barrier) Participants() (res int) {
+	b.lock.RLock()
+	res = b.participants
+	b.lock.RUnlock()
+	return
+}
+
+func (b *barrier) Parties() int {
+	return b.Participants()
+}
+
+func (b *barrier) Resize(participants int) error {
+	if participants <= 0 {
+		return ErrNonPositiveParticipants
+	}
+	if participants > maxParticipants {
+		return ErrParticipantsOutOfRange
+	}
+	b.lockArrivals()
+	defer b.unlockArrivals()
+	if b.closed {
+		return ErrClosed
+	}
+	if b.round.count() > 0 {
+		return ErrRoundInProgress
+	}
+	b.participants = participants
+	// nobody is in the current round, replace it with a resized one.
+	b.setRound(b.newRound())
+	b.syncBroken()
+	return nil
+}
+
+func (b *barrier) LastReleaseFanoutDuration() time.Duration {
+	return time.Duration(atomic.LoadInt64(&b.fanout))
+}
+
+// newComer save returns in local variables to prevent race
+// If await, the new comer waits for release at once,
+// index is its 1-based order among the waiting goroutines of the round,
+// and last reports whether it should trip the round.
+// If quorum > 0, the round trips once quorum goroutines are waiting.
+// name is the name of the new comer set by WaitAs, if any.
+// The plain arrivals of a round, which only need to count, arrive by a
+// compare-and-swap of its state without any lock, unless the round is
+// frozen by the write lock, or by the options needing more than counting.
+// With WithSharding, they take the slots of the shards instead, and the
+// count returned is the slot.
+// The other arrivals only hold the read lock, which prevents the round from
+// being replaced, and count by atomic operations, so that they do not block
+// each other. The write lock is needed for contribution, name and quorum only,
+// and for all of them with WithSharding, which combines the shards.
+func (b *barrier) newComer(ctx context.Context, v interface{}, name string, await bool, quorum int) (r *round, count, index int, last bool, err error) {
+	if b.isActing(ctx) {
+		// the participants of the round are waiting for the action,
+		// it would never return.
+		return nil, 0, 0, false, ErrReentrantWait
+	}
+	if await && v == nil && name == "" && quorum == 0 {
+		if r, count, awaited, ok := b.arriveLockFree(); ok {
+			return r, count, awaited, awaited == r.quorum, nil
+		}
+	}
+	var straggling bool
+	var observer Observer
+	var stack []byte
+	if b.debug {
+		stack = debug.Stack()
+	}
+	lock, unlock := b.lock.RLock, b.lock.RUnlock
+	if v != nil || name != "" || quorum > 0 || b.quorum > 0 || b.shards != nil {
+		// the stragglers are told by count and awaited together,
+		// and the sharded arrivals are combined into the round.
+		lock, unlock = b.lockArrivals, b.unlockArrivals
+	}
+	// the slot of the arrival, which is count, unless it is stolen from
+	// the shards.
+	var slot int
+	var stolen bool
+	for {
+		lock()
+		if b.closed {
+			unlock()
+			return nil, 0, 0, false, ErrClosed
+		}
+		if b.stuck != nil {
+			unlock()
+			return nil, 0, 0, false, b.stuck
+		}
+		r = b.round
+		if quorum > r.parties {
+			unlock()
+			return nil, 0, 0, false, ErrQuorumOutOfRange
+		}
+		// the write lock is held with quorum > 0.
+		if quorum > 0 && r.awaited() < r.quorum && quorum < r.quorum {
+			r.quorum = quorum
+		}
+		var awaited int
+		if await {
+			count, awaited = r.add(1, 1)
+			index, last = awaited, awaited == r.quorum
+		} else {
+			count, awaited = r.add(1, 0)
+		}
+		slot, stolen = count, false
+		if r.sharded && count < r.parties {
+			if slot = b.steal(r); slot == 0 {
+				// some arrivals have left, their slots are not free.
+				slot = count
+			} else {
+				stolen = true
+			}
+		}
+		if r.arrivals != nil && count <= r.parties {
+			r.arrivals[count-1] = time.Now()
+		}
+		if r.stacks != nil && count <= r.parties {
+			r.stacks[count-1] = stack
+		}
+		if count == 1 {
+			// only the first arrival starts the timers.
+			b.startTimers(r)
+		}
+		if count > r.parties && !b.panicOnOverflow {
+			b.undo(r, await, stolen)
+			unlock()
+			return nil, 0, 0, false, ErrTooManyParties
+		}
+		straggling = count <= r.parties && !last && awaited >= r.quorum && b.quorum > 0
+		if count <= r.parties && !last && awaited >= r.quorum && !straggling ||
+			count > r.parties && b.queueOverflow {
+			// r has enough goroutines to trip before the arrival,
+			// so it arrives the next round.
+			b.undo(r, await, stolen)
+			last = false
+			done := b.doneOf(r)
+			unlock()
+			<-done
+			runtime.Gosched() // r is going to be reset, if it is broken
+			continue
+		}
+		if name != "" && !straggling {
+			// the write lock is held with name.
+			// it is checked before the overflow panics, which is far from the cause.
+			arrived := count
+			if r.sharded {
+				// the names are in the slots.
+				arrived = r.parties + 1
+			}
+			if r.hasArrived(name, arrived) {
+				b.undo(r, await, stolen)
+				unlock()
+				return nil, 0, 0, false, fmt.Errorf("%w: %s", ErrDuplicateArrival, name)
+			}
+			if r.names == nil {
+				r.names = make([]string, r.parties)
+			}
+			if count <= r.parties {
+				r.names[slot-1] = name
+			}
+		}
+		if v != nil && count <= r.parties {
+			if r.values == nil {
+				r.values = make([]interface{}, r.parties)
+			}
+			r.values[slot-1] = v
+		}
+		observer = b.observer
+		if count <= r.parties {
+			b.emit(EventArrived, b.rounds+1, count, nil)
+		}
+		unlock()
+		break
+	}
+	if observer != nil && count <= r.parties {
+		observer.OnArrive(count)
+	}
+	if straggling && count == r.parties {
+		b.retire(r)
+	}
 	// 如果并发的 b.Wait() 的 goroutines 的数量
 	// 大于 b.participants 的话，
 	// 虽然 count++ 是在临界区内，但是 if 分支语句不在呀。
 	// count = participants 刚刚 unlock 后，还没有到达 if 前。
 	// 另一个 goroutine 进行了 count++ 运算
 	// 就会导致 count > participants 成立
-	if count > b.participants {
+	if count > r.parties {
 		panic(tooMuchWaiting)
 	}
+	if slot != count {
+		// the slot of a sharded round is the index as well, but the last one.
+		if await && !last {
+			index = slot
+		}
+		count = slot
+	}
 	return
 }
 
-func (b *barrier) breakRound() {
-	b.lock.Lock()
-	if !b.round.isBroken {
-		b.round.isBroken = true
-		close(b.round.broken) // broadcast to waiting goroutines
+// undo cancels an arrival of r. stolen means the arrival has stolen a
+// slot from the shards, which is given back.
+func (b *barrier) undo(r *round, await, stolen bool) {
+	if await {
+		r.add(-1, -1)
+	} else {
+		r.add(-1, 0)
+	}
+	if stolen {
+		b.unsteal(r)
 	}
-	b.lock.Unlock()
 }
 
-func (b *barrier) resetRound() {
-	b.lock.Lock()
-	if !b.round.isBroken {
-		close(b.round.success) // broadcast to waiting goroutines
+// breakRound breaks r with err, unless r has tripped already.
+// nil err means ErrBroken. cause is passed to the OnBroken hook,
+// nil cause means the error of r. by is the name of the participant
+// breaking r, if any.
+// It reports whether r is broken.
+func (b *barrier) breakRound(r *round, err, cause error, by string) bool {
+	b.lockArrivals()
+	if r.isTripped {
+		isBroken := r.isBroken
+		b.unlockArrivals()
+		return isBroken
 	}
-	b.round = newRound()
-	b.lock.Unlock()
+	broadcast := b.breakWith(r, err, cause)
+	if e, ok := r.err.(*BrokenError); ok && by != "" && e.By == "" {
+		e.By = by
+	}
+	b.unlockArrivals()
+	broadcast()
+	return true
+}
+
+// breakWith marks r broken with err, if r is not broken yet.
+// The goroutines of r return a *BrokenError wrapping err, but ErrClosed.
+// It should be called with b.lock held, and the returned broadcast should
+// be called after b.lock is released, which calls the OnBroken hook with
+// cause, then broadcasts to the waiting goroutines.
+// nil cause means the error of r.
+func (b *barrier) breakWith(r *round, err, cause error) (broadcast func()) {
+	if r.isBroken {
+		return noop
+	}
+	r.isBroken = true
+	r.err = b.brokenError(err)
+	if cause == nil {
+		cause = r.err
+	}
+	b.emit(EventBroken, b.rounds+1, r.count(), cause)
+	if b.sticky && !b.closed {
+		b.stuck = r.cause()
+	}
+	b.syncBroken()
+	if b.recoverAfter > 0 && !r.isTripped && !b.closed {
+		r.recovery = time.AfterFunc(b.recoverAfter, func() {
+			b.recoverRound(r)
+		})
+	}
+	onBroken, observer := b.onBroken, b.observer
+	return func() {
+		if onBroken != nil {
+			onBroken(cause)
+		}
+		if observer != nil {
+			observer.OnBreak(cause)
+		}
+		b.wake(r) // broadcast to waiting goroutines
+	}
+}
+
+func noop() {}
+
+// breakCurrent breaks the current round with err, unless it has tripped.
+// cause is passed to the OnBroken hook like breakWith.
+func (b *barrier) breakCurrent(err, cause error) {
+	b.lockArrivals()
+	broadcast := noop
+	if r := b.round; !r.isTripped {
+		broadcast = b.breakWith(r, err, cause)
+	}
+	b.unlockArrivals()
+	broadcast()
+}
+
+// brokenError returns the error of the round broken by err.
+// It should be called with b.lock held.
+func (b *barrier) brokenError(err error) error {
+	if err == ErrClosed {
+		return err
+	}
+	if err == nil {
+		err = ErrBroken
+	}
+	return &BrokenError{
+		Cause:    err,
+		Round:    b.rounds,
+		Canceled: errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded),
+	}
+}
+
+// startTimers starts the timers of r at its first arrival.
+// It should be called with b.lock held, at least the read lock.
+func (b *barrier) startTimers(r *round) {
+	id := b.rounds
+	if b.roundTimeout > 0 {
+		r.expiry = time.AfterFunc(b.roundTimeout, func() {
+			b.expire(r, id)
+		})
+	}
+	if b.watchdog > 0 {
+		r.watchdog = time.AfterFunc(b.watchdog, func() {
+			b.watch(r, id)
+		})
+	}
+	if b.stallAfter > 0 {
+		start := time.Now()
+		r.stall = time.AfterFunc(b.stallAfter, func() {
+			b.checkStall(r, id, start)
+		})
+	}
+}
+
+// isStuck reports whether r is still the id-th round, and has not tripped.
+// It should be called with b.lock held.
+func (b *barrier) isStuck(r *round, id uint64) bool {
+	// r may have been recycled as a new round.
+	return b.round == r && b.rounds == id && !r.isTripped
+}
+
+// expire breaks r with ErrTimeout, if it is stuck.
+func (b *barrier) expire(r *round, id uint64) {
+	b.lockArrivals()
+	if !b.isStuck(r, id) {
+		b.unlockArrivals()
+		return
+	}
+	broadcast := b.breakWith(r, ErrTimeout, nil)
+	b.unlockArrivals()
+	broadcast()
+}
+
+// recoverRound starts a new round, if the broken round r is still the
+// current one, because not all of its parties have arrived.
+func (b *barrier) recoverRound(r *round) {
+	b.lockArrivals()
+	defer b.unlockArrivals()
+	// the last arrived goroutine of r is resetting it.
+	if b.round != r || r.isTripped || b.closed {
+		return
+	}
+	b.completeRound(r)
+	b.setRound(b.newRound())
+	b.syncBroken()
+}
+
+// completeRound counts the completed round r.
+// It should be called with b.lock held.
+func (b *barrier) completeRound(r *round) {
+	r.stopTimers()
+	if r.task != nil {
+		r.task.End()
+	}
+	now := time.Now()
+	b.rounds++
+	b.stats.PartiesServed += uint64(r.count())
+	b.publish(r, now)
+	if r.isBroken {
+		b.stats.BrokenRounds++
+		return
+	}
+	b.stats.CompletedRounds++
+	b.stats.LastCompletion = now
+}
+
+// resetRound releases the tripped round r,
+// and starts a new round, unless Reset has done it.
+func (b *barrier) resetRound(r *round) {
+	b.lockArrivals()
+	broadcast := noop
+	hooks, observer := b.onRelease, b.observer
+	info := RoundInfo{
+		Round:   b.rounds,
+		Parties: r.count(),
+	}
+	if b.round == r {
+		b.completeRound(r)
+		info.Round = b.rounds
+		r.isCompleted = true
+		// the stragglers of a quorum round join it, the last of them
+		// starts a new round.
+		if b.quorum == 0 || r.count() == r.parties {
+			broadcast = b.nextRound()
+		}
+	}
+	b.unlockArrivals()
+	broadcast()
+	if r.isBroken {
+		return
+	}
+	if observer != nil {
+		observer.OnTrip(info.Round)
+	}
+	info.Released = time.Now()
+	b.release(r) // broadcast to waiting goroutines
+	if len(hooks) > 0 {
+		go func() {
+			for _, hook := range hooks {
+				hook(info)
+			}
+		}()
+	}
+}
+
+// nextRound starts a new round.
+// It should be called with b.lock held, and the returned broadcast should
+// be called after b.lock is released.
+func (b *barrier) nextRound() (broadcast func()) {
+	b.setRound(b.newRound())
+	b.syncBroken()
+	if b.closed {
+		return b.breakWith(b.round, ErrClosed, ErrClosed)
+	}
+	return noop
+}
+
+// retire starts a new round, after the last straggler arrives the
+// completed quorum round r.
+func (b *barrier) retire(r *round) {
+	b.lockArrivals()
+	broadcast := noop
+	// r may be completing, then it will start the new round.
+	if b.round == r && r.isCompleted {
+		broadcast = b.nextRound()
+	}
+	b.unlockArrivals()
+	broadcast()
 }
@@ -1,16 +1,32 @@
 package barrier
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"runtime"
+	"runtime/trace"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/marusama/cyclicbarrier"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
 // goWait make sure b.Wait is waiting
+// shouldBeBrokenBy asserts the error is ErrBroken, and wraps the expected error.
+func shouldBeBrokenBy(actual interface{}, expected ...interface{}) string {
+	err, _ := actual.(error)
+	cause, _ := expected[0].(error)
+	if errors.Is(err, ErrBroken) && errors.Is(err, cause) {
+		return ""
+	}
+	return fmt.Sprintf("Expected: ErrBroken wrapping '%v'\nActual:   '%v'", cause, err)
+}
+
 func goWait(b Barrier) {
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -24,12 +40,7 @@ func goWait(b Barrier) {
 
 // count arriver
 func count(b Barrier) int {
-	// NOTICE: 访问 Barrier 的原始数据结构，不是一个好行为
-	bp := b.(*barrier)
-	bp.lock.RLock()
-	res := bp.round.count
-	bp.lock.RUnlock()
-	return res
+	return b.NumberWaiting()
 }
 
 func TestNew(t *testing.T) {
@@ -51,6 +62,14 @@ func TestNew(t *testing.T) {
 			})
 		})
 
+		Convey("当 participants 超过一轮能计数的范围的时候", func() {
+			Convey("也会 panic", func() {
+				So(func() {
+					New(maxParticipants + 1)
+				}, ShouldPanicWith, participantsOutOfRange)
+			})
+		})
+
 		Convey("当 participants 是 2 的时候", func() {
 			Convey("就不会 panic", func() {
 				So(func() {
@@ -69,6 +88,98 @@ func TestNew(t *testing.T) {
 	})
 }
 
+func TestParticipants(t *testing.T) {
+	Convey("用 7 个 participants 新建的 Barrier", t, func() {
+		b := New(7)
+		Convey("Participants 会返回 7", func() {
+			So(b.Participants(), ShouldEqual, 7)
+		})
+
+		Convey("Parties 和 Participants 一样，RoundNumber 和 Round 一样", func() {
+			So(b.Parties(), ShouldEqual, 7)
+			So(b.RoundNumber(), ShouldEqual, 0)
+			b.Reset()
+			So(b.RoundNumber(), ShouldEqual, b.Round())
+			So(b.RoundNumber(), ShouldEqual, 1)
+		})
+	})
+}
+
+func TestResize(t *testing.T) {
+	Convey("假设 Barrier 有 3 个参与者", t, func() {
+		b := New(3)
+
+		Convey("扩大到 5 个参与者以后，需要 5 个参与者才能完成一轮", func() {
+			So(b.Resize(5), ShouldBeNil)
+			So(b.Participants(), ShouldEqual, 5)
+			for i := 1; i < 5; i++ {
+				goWait(b)
+			}
+			for b.NumberWaiting() < 4 {
+				runtime.Gosched()
+			}
+			So(b.Round(), ShouldEqual, 0)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(b.Round(), ShouldEqual, 1)
+		})
+
+		Convey("缩小到 2 个参与者以后，只需要 2 个参与者就能完成一轮", func() {
+			So(b.Resize(2), ShouldBeNil)
+			So(b.Participants(), ShouldEqual, 2)
+			goWait(b)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(b.Round(), ShouldEqual, 1)
+		})
+
+		Convey("已经有参与者 Wait 的时候，不能修改", func() {
+			goWait(b)
+			for b.NumberWaiting() < 1 {
+				runtime.Gosched()
+			}
+			So(b.Resize(2), ShouldEqual, ErrRoundInProgress)
+			So(b.Participants(), ShouldEqual, 3)
+		})
+
+		Convey("参与者的数量不是正数的时候，不能修改", func() {
+			So(b.Resize(0), ShouldEqual, ErrNonPositiveParticipants)
+			So(b.Resize(-1), ShouldEqual, ErrNonPositiveParticipants)
+			So(b.Participants(), ShouldEqual, 3)
+		})
+
+		Convey("参与者的数量超过一轮能计数的范围时，不能修改", func() {
+			So(b.Resize(maxParticipants+1), ShouldEqual, ErrParticipantsOutOfRange)
+			So(b.Participants(), ShouldEqual, 3)
+		})
+	})
+}
+
+func TestString(t *testing.T) {
+	Convey("假设 Barrier 有 3 个参与者，完成了 1 轮", t, func() {
+		b := New(3)
+		goWait(b)
+		goWait(b)
+		b.Wait(context.TODO())
+
+		Convey("新的一轮有 2 个参与者在 Wait", func() {
+			goWait(b)
+			goWait(b)
+			for b.NumberWaiting() < 2 {
+				runtime.Gosched()
+			}
+			So(fmt.Sprint(b), ShouldEqual, "Barrier{participants:3 waiting:2 broken:false round:1}")
+		})
+
+		Convey("新的一轮被 Break 了", func() {
+			b.Break()
+			So(fmt.Sprint(b), ShouldEqual, "Barrier{participants:3 waiting:1 broken:true round:1}")
+		})
+	})
+	Convey("有名字的 Barrier，%v 会打印它的名字", t, func() {
+		b := New(8, WithName("stage1"))
+		So(fmt.Sprintf("%v", b), ShouldEqual, "Barrier{name:stage1 participants:8 waiting:0 broken:false round:0}")
+	})
+}
+
 func TestAction(t *testing.T) {
 	participants := 5
 	Convey("如果 Barrier 设置了 Action", t, func() {
@@ -104,6 +215,272 @@ func TestAction(t *testing.T) {
 	})
 }
 
+func TestActionE(t *testing.T) {
+	Convey("如果 Barrier 的 Action 会返回 error", t, func() {
+		errAction := errors.New("action failed")
+		participants := 3
+		b := New(participants).SetActionE(func() error {
+			return errAction
+		})
+
+		Convey("所有的参与者都会返回 Action 的 error", func() {
+			errs := make(chan error, participants-1)
+			for i := 1; i < participants; i++ {
+				go func() {
+					errs <- b.Wait(context.TODO())
+				}()
+			}
+			for count(b) < participants-1 {
+				runtime.Gosched()
+			}
+			So(b.Wait(context.TODO()), shouldBeBrokenBy, errAction)
+			for i := 1; i < participants; i++ {
+				So(<-errs, shouldBeBrokenBy, errAction)
+			}
+
+			Convey("Barrier 依然可以继续使用", func() {
+				So(b.IsBroken(), ShouldBeFalse)
+				So(count(b), ShouldEqual, 0)
+				b.SetActionE(func() error { return nil })
+				goWait(b)
+				goWait(b)
+				So(b.Wait(context.TODO()), ShouldBeNil)
+			})
+		})
+	})
+	Convey("如果参与者在 Action 执行的时候被取消了，然后 Action 返回了 error", t, func() {
+		errAction := errors.New("action failed")
+		entered, leave := make(chan struct{}), make(chan struct{})
+		b := New(2).SetActionE(func() error {
+			close(entered)
+			<-leave
+			return errAction
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- b.Wait(ctx)
+		}()
+		for count(b) < 1 {
+			runtime.Gosched()
+		}
+		lastCh := make(chan error, 1)
+		go func() {
+			lastCh <- b.Wait(context.TODO())
+		}()
+		<-entered
+		cancel()
+		time.Sleep(time.Millisecond)
+		close(leave)
+
+		Convey("它会返回 Action 的 error，而不会一直等待", func() {
+			So(<-errCh, shouldBeBrokenBy, errAction)
+			So(<-lastCh, shouldBeBrokenBy, errAction)
+		})
+	})
+}
+
+func TestSetActionMidRound(t *testing.T) {
+	Convey("假设 Barrier 有 3 个参与者，并设置了 Action", t, func() {
+		var ran []string
+		b := New(3)
+		b.SetAction(func() {
+			ran = append(ran, "original")
+		})
+
+		Convey("2 个参与者到达后更换 Action，这一轮依然执行原来的 Action", func() {
+			goWait(b)
+			goWait(b)
+			for b.NumberWaiting() < 2 {
+				runtime.Gosched()
+			}
+			b.SetAction(func() {
+				ran = append(ran, "replaced")
+			})
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(ran, ShouldResemble, []string{"original"})
+
+			Convey("新的 Action 从下一轮开始执行", func() {
+				goWait(b)
+				goWait(b)
+				So(b.Wait(context.TODO()), ShouldBeNil)
+				So(ran, ShouldResemble, []string{"original", "replaced"})
+			})
+		})
+	})
+}
+
+func TestAddAction(t *testing.T) {
+	Convey("如果 Barrier 按顺序添加了 3 个 Action", t, func() {
+		var order []int
+		errAction := errors.New("action failed")
+		fail := false
+		b := New(2)
+		b.AddAction(func() error {
+			order = append(order, 1)
+			return nil
+		}).AddAction(func() error {
+			order = append(order, 2)
+			if fail {
+				return errAction
+			}
+			return nil
+		}).AddAction(func() error {
+			order = append(order, 3)
+			return nil
+		})
+
+		Convey("这些 Action 会按照添加的顺序执行", func() {
+			goWait(b)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(order, ShouldResemble, []int{1, 2, 3})
+		})
+
+		Convey("第 2 个 Action 返回 error 的时候，第 3 个不会执行，这一轮被 break 了", func() {
+			fail = true
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- b.Wait(context.TODO())
+			}()
+			So(b.Wait(context.TODO()), shouldBeBrokenBy, errAction)
+			So(<-errCh, shouldBeBrokenBy, errAction)
+			So(order, ShouldResemble, []int{1, 2})
+		})
+
+		Convey("添加 nil Action 会被忽略", func() {
+			b.AddAction(nil)
+			goWait(b)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(order, ShouldResemble, []int{1, 2, 3})
+		})
+
+		Convey("SetAction 会替换掉所有的 Action", func() {
+			b.SetAction(func() {
+				order = append(order, 0)
+			})
+			goWait(b)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(order, ShouldResemble, []int{0})
+		})
+	})
+}
+
+func TestActionPanic(t *testing.T) {
+	Convey("如果 Barrier 的 Action 会 panic", t, func() {
+		participants := 3
+		b := New(participants).SetAction(func() {
+			panic("action panics")
+		})
+
+		Convey("其他的参与者会返回 ErrBroken，最后一个参与者返回 ErrActionPanic", func() {
+			errs := make(chan error, participants-1)
+			for i := 1; i < participants; i++ {
+				go func() {
+					errs <- b.Wait(context.TODO())
+				}()
+			}
+			for b.NumberWaiting() < participants-1 {
+				runtime.Gosched()
+			}
+			err := b.Wait(context.TODO())
+			So(errors.Is(err, ErrActionPanic), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "action panics")
+			for i := 1; i < participants; i++ {
+				So(<-errs, shouldBeBrokenBy, ErrBroken)
+			}
+
+			Convey("Barrier 依然可以继续使用", func() {
+				b.SetAction(nil)
+				goWait(b)
+				goWait(b)
+				So(b.Wait(context.TODO()), ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestActionCtx(t *testing.T) {
+	Convey("如果 Barrier 的 Action 需要 context", t, func() {
+		type key struct{}
+		b := New(2).SetActionCtx(func(ctx context.Context) error {
+			if ctx.Value(key{}) == nil {
+				return errors.New("not the context of last arrived")
+			}
+			return ctx.Err()
+		})
+
+		Convey("Action 会收到最后一个参与者的 context", func() {
+			goWait(b)
+			for b.NumberWaiting() < 1 {
+				runtime.Gosched()
+			}
+			ctx := context.WithValue(context.Background(), key{}, 1)
+			So(b.Wait(ctx), ShouldBeNil)
+		})
+
+		Convey("context 已经 cancel 了，Action 依然会执行，所有参与者都会返回 context 的 error", func() {
+			actionErr := make(chan error, 1)
+			b.SetActionCtx(func(ctx context.Context) error {
+				actionErr <- ctx.Err()
+				return ctx.Err()
+			})
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- b.Wait(context.TODO())
+			}()
+			for b.NumberWaiting() < 1 {
+				runtime.Gosched()
+			}
+			ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, 1))
+			cancel()
+			So(errors.Is(b.Wait(ctx), context.Canceled), ShouldBeTrue)
+			So(errors.Is(<-errCh, context.Canceled), ShouldBeTrue)
+			So(<-actionErr, ShouldEqual, context.Canceled)
+		})
+
+		Convey("后设置的 SetAction 会替代 SetActionCtx", func() {
+			status := 0
+			b.SetAction(func() {
+				status = 1
+			})
+			goWait(b)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(status, ShouldEqual, 1)
+		})
+	})
+}
+
+func TestIsBrokenWithoutLock(t *testing.T) {
+	Convey("假设 Barrier 的锁被占用了", t, func() {
+		b := New(2, WithStickyBroken())
+		bp := b.(*barrier)
+
+		Convey("IsBroken 不用等待锁，也能返回 Barrier 的状态", func() {
+			bp.lock.Lock()
+			So(b.IsBroken(), ShouldBeFalse)
+			bp.lock.Unlock()
+			b.Break()
+			bp.lock.Lock()
+			So(b.IsBroken(), ShouldBeTrue)
+			bp.lock.Unlock()
+
+			Convey("Reset 以后，Barrier 不再是 broken", func() {
+				b.Reset()
+				So(b.IsBroken(), ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func Benchmark_IsBroken(b *testing.B) {
+	cb := New(2)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			cb.IsBroken()
+		}
+	})
+}
+
 func TestBarrierStatus(t *testing.T) {
 	Convey("假设 Barrier 有 3 个参与者，其中", t, func() {
 		b := New(3)
@@ -147,13 +524,13 @@ func TestBarrierStatus(t *testing.T) {
 
 				Convey("第 3 个参与者执行了 Wait", func() {
 					err := b.Wait(context.TODO())
-					So(err, ShouldEqual, ErrBroken)
+					So(err, shouldBeBrokenBy, ErrBroken)
 					So(<-statusCh, ShouldEqual, -1)
 				})
 
 				Convey("第 3 个参与者执行了 Break", func() {
 					err := b.Wait(context.TODO())
-					So(err, ShouldEqual, ErrBroken)
+					So(err, shouldBeBrokenBy, ErrBroken)
 					So(<-statusCh, ShouldEqual, -1)
 				})
 			})
@@ -166,13 +543,13 @@ func TestBarrierStatus(t *testing.T) {
 
 			Convey("第 2 个参与者执行了 Wait", func() {
 				err := b.Wait(context.TODO())
-				So(err, ShouldEqual, ErrBroken)
+				So(err, shouldBeBrokenBy, ErrBroken)
 				So(b.IsBroken(), ShouldBeTrue)
 				So(status, ShouldEqual, 0)
 
 				Convey("第 3 个参与者执行了 Wait", func() {
 					err := b.Wait(context.TODO())
-					So(err, ShouldEqual, ErrBroken)
+					So(err, shouldBeBrokenBy, ErrBroken)
 					So(<-statusCh, ShouldEqual, -1)
 				})
 
@@ -189,7 +566,7 @@ func TestBarrierStatus(t *testing.T) {
 
 				Convey("第 3 个参与者执行了 Wait", func() {
 					err := b.Wait(context.TODO())
-					So(err, ShouldEqual, ErrBroken)
+					So(err, shouldBeBrokenBy, ErrBroken)
 					So(<-statusCh, ShouldEqual, -1)
 				})
 
@@ -202,6 +579,150 @@ func TestBarrierStatus(t *testing.T) {
 	})
 }
 
+func TestOnBroken(t *testing.T) {
+	Convey("假设 Barrier 有 3 个参与者，并设置了 OnBroken", t, func() {
+		var causes []error
+		b := New(3)
+		b.OnBroken(func(cause error) {
+			So(b.IsBroken(), ShouldBeTrue)
+			causes = append(causes, cause)
+		})
+
+		Convey("两个参与者先后 Break，OnBroken 只会执行一次", func() {
+			b.Break()
+			b.Break()
+			So(causes, ShouldResemble, []error{ErrBroken})
+
+			Convey("下一轮 Break 的时候，OnBroken 会再执行一次", func() {
+				b.Wait(context.TODO())
+				So(b.IsBroken(), ShouldBeFalse)
+				b.Break()
+				So(causes, ShouldResemble, []error{ErrBroken, ErrBroken})
+			})
+		})
+
+		Convey("context 被 cancel 的时候，cause 是 context 的 error", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			err := b.Wait(ctx)
+			So(len(causes), ShouldEqual, 1)
+			So(causes[0], ShouldEqual, err)
+			So(errors.Is(causes[0], context.Canceled), ShouldBeTrue)
+		})
+	})
+}
+
+func TestOnRelease(t *testing.T) {
+	Convey("假设 Barrier 有 2 个参与者，并添加了 2 个 OnRelease", t, func() {
+		infos := make(chan RoundInfo, 4)
+		order := make(chan int, 4)
+		block := make(chan struct{})
+		b := New(2).OnRelease(func(info RoundInfo) {
+			<-block
+			order <- 1
+			infos <- info
+		}).OnRelease(nil).OnRelease(func(RoundInfo) {
+			order <- 2
+		})
+
+		Convey("hook 不会阻塞 Wait，释放以后按顺序执行", func() {
+			goWait(b)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			close(block)
+			So(<-order, ShouldEqual, 1)
+			So(<-order, ShouldEqual, 2)
+			info := <-infos
+			So(info.Round, ShouldEqual, 1)
+			So(info.Parties, ShouldEqual, 2)
+			So(info.Released.IsZero(), ShouldBeFalse)
+		})
+
+		Convey("被打破的一轮不会执行 hook", func() {
+			close(block)
+			b.Break()
+			b.Wait(context.TODO())
+			goWait(b)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So((<-infos).Round, ShouldEqual, 2)
+		})
+	})
+}
+
+func TestBreakWith(t *testing.T) {
+	Convey("假设 Barrier 有 2 个参与者，已经完成了 1 轮，第 2 轮有 1 个参与者在等待", t, func() {
+		b := New(2)
+		goWait(b)
+		So(b.Wait(context.TODO()), ShouldBeNil)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- b.Wait(context.TODO())
+		}()
+		for b.NumberWaiting() < 1 {
+			runtime.Gosched()
+		}
+
+		Convey("BreakWith 以后，等待的参与者会得到带有原因的 *BrokenError", func() {
+			errDisk := errors.New("disk is full")
+			b.(CauseBreaker).BreakWith(errDisk)
+			err := <-errCh
+			So(err, shouldBeBrokenBy, errDisk)
+			var be *BrokenError
+			So(errors.As(err, &be), ShouldBeTrue)
+			So(be.Cause, ShouldEqual, errDisk)
+			So(be.Round, ShouldEqual, 1)
+			So(be.Canceled, ShouldBeFalse)
+		})
+
+		Convey("参与者的 context 被取消以后，*BrokenError 会标记 Canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			err := b.Wait(ctx)
+			So(<-errCh, ShouldEqual, err)
+			var be *BrokenError
+			So(errors.As(err, &be), ShouldBeTrue)
+			So(be.Canceled, ShouldBeTrue)
+			So(errors.Is(err, context.Canceled), ShouldBeTrue)
+			So(err.Error(), ShouldEqual, "barrier is broken: context canceled")
+		})
+	})
+}
+
+func TestBreakCtx(t *testing.T) {
+	Convey("假设 Barrier 有 2 个参与者，其中 1 个已经在 Wait 了", t, func() {
+		actionCount := 0
+		b := New(2).SetAction(func() {
+			actionCount++
+		})
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- b.Wait(context.TODO())
+		}()
+		for b.NumberWaiting() < 1 {
+			runtime.Gosched()
+		}
+
+		Convey("context 已经 cancel 了，BreakCtx 什么也不做，返回 context 的 error", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			So(b.BreakCtx(ctx), ShouldEqual, context.Canceled)
+			So(b.IsBroken(), ShouldBeFalse)
+			So(b.NumberWaiting(), ShouldEqual, 1)
+		})
+
+		Convey("BreakCtx 会和 Break 一样，执行 Action 并重置", func() {
+			So(b.BreakCtx(context.TODO()), ShouldBeNil)
+			So(<-errCh, shouldBeBrokenBy, ErrBroken)
+			So(actionCount, ShouldEqual, 1)
+			So(b.Round(), ShouldEqual, 1)
+		})
+
+		Convey("Close 以后，BreakCtx 返回 ErrClosed", func() {
+			b.Close()
+			So(b.BreakCtx(context.TODO()), ShouldEqual, ErrClosed)
+		})
+	})
+}
+
 func TestTooMuchWaiting(t *testing.T) {
 	noSend := make(chan struct{})
 	Convey("如果所有的 participants 已经到齐了", t, func() {
@@ -218,6 +739,37 @@ func TestTooMuchWaiting(t *testing.T) {
 	})
 }
 
+func TestTooMuchWaitingWithoutPanic(t *testing.T) {
+	noSend := make(chan struct{})
+	Convey("如果 Barrier 不会因为参与者太多而 panic", t, func() {
+		b := NewStrict(2, false).SetAction(func() {
+			<-noSend
+		})
+		goWait(b)
+		goWait(b)
+		for b.NumberWaiting() < 2 {
+			runtime.Gosched()
+		}
+		Convey("再次调用 b.Wait，会返回 ErrTooManyParties，并且不会计入参与者", func() {
+			So(b.Wait(context.TODO()), ShouldEqual, ErrTooManyParties)
+			So(b.NumberWaiting(), ShouldEqual, 2)
+		})
+	})
+
+	Convey("NewStrict 也可以和 New 一样会 panic", t, func() {
+		b := NewStrict(1, true).SetAction(func() {
+			<-noSend
+		})
+		goWait(b)
+		for b.NumberWaiting() < 1 {
+			runtime.Gosched()
+		}
+		So(func() {
+			b.Wait(context.TODO())
+		}, ShouldPanicWith, tooMuchWaiting)
+	})
+}
+
 func TestContextCancel(t *testing.T) {
 	Convey("Barrier 中有一个 goroutine 已经 waiting", t, func() {
 		b := New(2)
@@ -251,25 +803,861 @@ func TestContextCancel(t *testing.T) {
 	})
 }
 
-// TODO: 这里出现过报错
-func TestBarrierCyclic(t *testing.T) {
-	round := 5
-	participants := 7
-	roundCount := 0
-	roundCh := make(chan int, 1)
-	b := New(participants).SetAction(func() {
-		roundCount++
-		roundCh <- roundCount
-	})
+func TestWaitAs(t *testing.T) {
+	Convey("假设 Barrier 有 2 个参与者，shard-7-writer 已经在等待", t, func() {
+		b := New(2)
+		ctx, cancel := context.WithCancel(context.Background())
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- b.WaitAs(ctx, "shard-7-writer")
+		}()
+		for b.NumberWaiting() < 1 {
+			runtime.Gosched()
+		}
 
-	Convey("循环使用同一个 Barrier", t, func() {
-		for r := 1; r <= round; r++ {
-			for p := 1; p < participants; p++ {
-				goWait(b)
-				So(count(b), ShouldEqual, p)
-			}
-			// err := b.Wait(context.TODO())
-			// So(err, ShouldBeNil)
+		Convey("它被取消以后，error 会说明是它 break 了这一轮", func() {
+			cancel()
+			err := <-errCh
+			So(err.Error(), ShouldEqual, "barrier is broken by shard-7-writer: context canceled")
+			var be *BrokenError
+			So(errors.As(err, &be), ShouldBeTrue)
+			So(be.By, ShouldEqual, "shard-7-writer")
+			So(b.Wait(context.TODO()), ShouldEqual, err)
+		})
+
+		Convey("其他参与者到达以后，它会正常返回", func() {
+			So(b.WaitAs(context.TODO(), "shard-8-writer"), ShouldBeNil)
+			So(<-errCh, ShouldBeNil)
+			cancel()
+		})
+
+		Convey("同一个参与者在这一轮再次到达，会返回 ErrDuplicateArrival，并说明是谁", func() {
+			err := b.WaitAs(context.TODO(), "shard-7-writer")
+			So(errors.Is(err, ErrDuplicateArrival), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "shard-7-writer")
+			So(b.NumberWaiting(), ShouldEqual, 1)
+			So(b.IsBroken(), ShouldBeFalse)
+
+			Convey("它在下一轮可以再次到达", func() {
+				So(b.WaitAs(context.TODO(), "shard-8-writer"), ShouldBeNil)
+				So(<-errCh, ShouldBeNil)
+				goWait(b)
+				So(b.WaitAs(context.TODO(), "shard-7-writer"), ShouldBeNil)
+				cancel()
+			})
+		})
+	})
+}
+
+func TestReset(t *testing.T) {
+	Convey("假设 Barrier 有 3 个参与者，其中 2 个已经在 Wait 了", t, func() {
+		actionCount := 0
+		b := New(3).SetAction(func() {
+			actionCount++
+		})
+		errs := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				errs <- b.Wait(context.TODO())
+			}()
+		}
+		for b.NumberWaiting() < 2 {
+			runtime.Gosched()
+		}
+
+		Convey("Reset 以后", func() {
+			b.Reset()
+
+			Convey("等待的参与者都会返回 ErrBroken", func() {
+				So(<-errs, shouldBeBrokenBy, ErrBroken)
+				So(<-errs, shouldBeBrokenBy, ErrBroken)
+			})
+
+			Convey("新的 round 没有 broken，也没有参与者", func() {
+				So(b.IsBroken(), ShouldBeFalse)
+				So(b.NumberWaiting(), ShouldEqual, 0)
+			})
+
+			Convey("Action 不会被执行", func() {
+				So(actionCount, ShouldEqual, 0)
+			})
+
+			Convey("Barrier 依然可以继续使用", func() {
+				goWait(b)
+				goWait(b)
+				So(b.Wait(context.TODO()), ShouldBeNil)
+				So(actionCount, ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestReentrantWait(t *testing.T) {
+	Convey("假设 Barrier 有 2 个参与者，Action 中又用它的 context 调用了 Barrier 的方法", t, func() {
+		var errs []error
+		var completed bool
+		b := New(2)
+		b.SetActionCtx(func(ctx context.Context) error {
+			errs = append(errs, b.Wait(ctx))
+			errs = append(errs, b.BreakCtx(ctx))
+			var err error
+			completed, err = b.TryWait()
+			errs = append(errs, err)
+			return nil
+		})
+
+		Convey("Wait 和 BreakCtx 会返回 ErrReentrantWait，TryWait 返回 false，这一轮正常完成", func() {
+			goWait(b)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(errs, ShouldResemble, []error{ErrReentrantWait, ErrReentrantWait, nil})
+			So(completed, ShouldBeFalse)
+			So(b.IsDrained(), ShouldBeTrue)
+			So(b.Stats().CompletedRounds, ShouldEqual, 1)
+		})
+	})
+
+	Convey("假设 Barrier 有 3 个参与者，Action 中调用了 WaitN 和 WaitWeighted", t, func() {
+		var errs []error
+		b := New(3)
+		b.SetActionCtx(func(ctx context.Context) error {
+			errs = append(errs, b.WaitN(ctx, 1))
+			errs = append(errs, b.WaitWeighted(ctx, 1))
+			return nil
+		})
+
+		Convey("它们也会返回 ErrReentrantWait，而不会死锁", func() {
+			goWait(b)
+			goWait(b)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(errs, ShouldResemble, []error{ErrReentrantWait, ErrReentrantWait})
+			So(b.Stats().CompletedRounds, ShouldEqual, 1)
+		})
+	})
+
+	Convey("假设 Barrier 有 3 个参与者，2 个到达就放行，Action 中调用了 Wait", t, func() {
+		var errs []error
+		b := NewQuorum(3, 2)
+		b.SetActionCtx(func(ctx context.Context) error {
+			errs = append(errs, b.Wait(ctx))
+			return nil
+		})
+
+		Convey("它会返回 ErrReentrantWait，而不会作为迟到者加入这一轮", func() {
+			goWait(b)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(errs, ShouldResemble, []error{ErrReentrantWait})
+		})
+	})
+
+	Convey("假设 Action 用它的 context 等待另一个 Barrier", t, func() {
+		other := New(2)
+		b := New(1)
+		b.SetActionCtx(func(ctx context.Context) error {
+			return other.Wait(ctx)
+		})
+
+		Convey("另一个 Barrier 照常等待，不会被当作重入", func() {
+			goWait(other)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+		})
+	})
+}
+
+func TestWaitChan(t *testing.T) {
+	Convey("假设 Barrier 有 2 个参与者，其中 1 个调用了 WaitChan", t, func() {
+		b := New(2)
+		ch := b.(ChanWaiter).WaitChan(context.TODO())
+		So(b.NumberWaiting(), ShouldEqual, 1)
+
+		Convey("另一个参与者到达之前，channel 收不到结果", func() {
+			received := false
+			select {
+			case <-ch:
+				received = true
+			case <-time.After(time.Millisecond):
+			}
+			So(received, ShouldBeFalse)
+		})
+
+		Convey("另一个参与者到达以后，channel 收到 nil error", func() {
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So((<-ch).Err, ShouldBeNil)
+		})
+
+		Convey("最后到达的参与者调用 WaitChan，channel 立即就有结果", func() {
+			So((<-b.(ChanWaiter).WaitChan(context.TODO())).Err, ShouldBeNil)
+			So((<-ch).Err, ShouldBeNil)
+		})
+
+		Convey("这一轮被 Break 以后，channel 收到 ErrBroken", func() {
+			b.Break()
+			So((<-ch).Err, shouldBeBrokenBy, ErrBroken)
+		})
+	})
+}
+
+func TestWaitExchange(t *testing.T) {
+	Convey("假设 Barrier 有 4 个参与者，每个都贡献一个整数", t, func() {
+		participants := 4
+		b := New(participants)
+		var wg sync.WaitGroup
+		results := make(chan []interface{}, participants)
+		wg.Add(participants)
+		for i := 0; i < participants; i++ {
+			go func(mine int) {
+				values, err := b.WaitExchange(context.TODO(), mine)
+				if err == nil {
+					results <- values
+				}
+				wg.Done()
+			}(i)
+		}
+		wg.Wait()
+		close(results)
+
+		Convey("所有的参与者都会得到相同的、完整的贡献", func() {
+			var first []interface{}
+			n := 0
+			for values := range results {
+				n++
+				if first == nil {
+					first = values
+				}
+				So(values, ShouldResemble, first)
+			}
+			So(n, ShouldEqual, participants)
+			So(first, ShouldHaveLength, participants)
+			for i := 0; i < participants; i++ {
+				So(first, ShouldContain, i)
+			}
+		})
+	})
+
+	Convey("假设 Barrier 有 2 个参与者，其中一个 Break 了", t, func() {
+		b := New(2)
+		go b.Break()
+		values, err := b.WaitExchange(context.TODO(), 1)
+		So(err, shouldBeBrokenBy, ErrBroken)
+		So(values, ShouldBeNil)
+	})
+}
+
+func TestWaitIndex(t *testing.T) {
+	Convey("假设 Barrier 有 3 个参与者，依次调用 WaitIndex", t, func() {
+		participants := 3
+		b := New(participants)
+		indexes := make(chan int, participants-1)
+		for i := 1; i < participants; i++ {
+			go func() {
+				index, _ := b.WaitIndex(context.TODO())
+				indexes <- index
+			}()
+			for b.NumberWaiting() < i {
+				runtime.Gosched()
+			}
+		}
+		index, err := b.WaitIndex(context.TODO())
+
+		Convey("最后到达的得到 0，最先到达的得到 participants-1", func() {
+			So(err, ShouldBeNil)
+			So(index, ShouldEqual, 0)
+			got := []int{<-indexes, <-indexes}
+			So(got, ShouldContain, participants-1)
+			So(got, ShouldContain, 1)
+		})
+	})
+}
+
+func TestWaitAction(t *testing.T) {
+	Convey("假设 Barrier 有 3 个参与者，并设置了 Action", t, func() {
+		participants := 3
+		var ran []string
+		b := New(participants).SetAction(func() {
+			ran = append(ran, "global")
+		})
+		waitAction := func(name string) func() error {
+			return func() error {
+				ran = append(ran, name)
+				return nil
+			}
+		}
+
+		Convey("3 个参与者依次带着不同的 action 到达，只会执行最后一个的", func() {
+			errs := make(chan error, participants-1)
+			for i := 1; i < participants; i++ {
+				name := fmt.Sprint(i)
+				go func() {
+					errs <- b.WaitAction(context.TODO(), waitAction(name))
+				}()
+				for b.NumberWaiting() < i {
+					runtime.Gosched()
+				}
+			}
+			So(b.WaitAction(context.TODO(), waitAction("last")), ShouldBeNil)
+			So(<-errs, ShouldBeNil)
+			So(<-errs, ShouldBeNil)
+			So(ran, ShouldResemble, []string{"last"})
+
+			Convey("下一轮依然执行 Barrier 的 Action", func() {
+				goWait(b)
+				goWait(b)
+				So(b.Wait(context.TODO()), ShouldBeNil)
+				So(ran, ShouldResemble, []string{"last", "global"})
+			})
+		})
+
+		Convey("最后到达的 action 返回 error，所有的参与者都会得到这个 error", func() {
+			errAction := errors.New("action failed")
+			errs := make(chan error, participants-1)
+			for i := 1; i < participants; i++ {
+				go func() {
+					errs <- b.Wait(context.TODO())
+				}()
+				for b.NumberWaiting() < i {
+					runtime.Gosched()
+				}
+			}
+			err := b.WaitAction(context.TODO(), func() error {
+				return errAction
+			})
+			So(err, shouldBeBrokenBy, errAction)
+			So(<-errs, shouldBeBrokenBy, errAction)
+			So(<-errs, shouldBeBrokenBy, errAction)
+			So(ran, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestWaitWeighted(t *testing.T) {
+	Convey("假设 Barrier 有 5 个参与者", t, func() {
+		participants := 5
+		b := New(participants)
+
+		Convey("n 不是正数时，返回 ErrInvalidWeight", func() {
+			So(b.WaitWeighted(context.TODO(), 0), ShouldEqual, ErrInvalidWeight)
+		})
+
+		Convey("n 超过了 participants 时，返回 ErrTooManyParties，并且不会计入参与者", func() {
+			So(b.WaitWeighted(context.TODO(), participants+1), ShouldEqual, ErrTooManyParties)
+			So(b.IsDrained(), ShouldBeTrue)
+		})
+
+		Convey("一个 goroutine 代表 3 个参与者，另一个代表 2 个，这一轮会完成", func() {
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- b.WaitWeighted(context.TODO(), 3)
+			}()
+			for b.NumberWaiting() < 3 {
+				runtime.Gosched()
+			}
+			So(b.WaitWeighted(context.TODO(), 3), ShouldEqual, ErrTooManyParties)
+			So(b.WaitWeighted(context.TODO(), 2), ShouldBeNil)
+			So(<-errCh, ShouldBeNil)
+			So(b.Round(), ShouldEqual, 1)
+
+			Convey("之后，和普通的 Wait 混用也没有问题", func() {
+				for i := 0; i < 2; i++ {
+					goWait(b)
+				}
+				for b.NumberWaiting() < 2 {
+					runtime.Gosched()
+				}
+				So(b.WaitWeighted(context.TODO(), 3), ShouldBeNil)
+				So(b.Round(), ShouldEqual, 2)
+			})
+		})
+	})
+}
+
+func TestWaitIndexed(t *testing.T) {
+	Convey("假设 Barrier 有 5 个参与者，都调用 WaitIndexed", t, func() {
+		participants := 5
+		b := New(participants)
+		var wg sync.WaitGroup
+		indexes := make(chan int, participants)
+		wg.Add(participants)
+		for i := 0; i < participants; i++ {
+			go func() {
+				index, err := b.WaitIndexed(context.TODO())
+				if err == nil {
+					indexes <- index
+				}
+				wg.Done()
+			}()
+		}
+		wg.Wait()
+		close(indexes)
+
+		Convey("每个参与者得到的序号都不相同，只有 1 个是 5", func() {
+			seen := make(map[int]bool, participants)
+			for index := range indexes {
+				So(seen[index], ShouldBeFalse)
+				seen[index] = true
+			}
+			for i := 1; i <= participants; i++ {
+				So(seen[i], ShouldBeTrue)
+			}
+		})
+	})
+}
+
+func TestWaitN(t *testing.T) {
+	Convey("假设 Barrier 有 3 个参与者", t, func() {
+		participants := 3
+		b := New(participants)
+
+		Convey("k 不在 [1, participants] 之间时，WaitN 会返回 ErrQuorumOutOfRange", func() {
+			So(b.WaitN(context.TODO(), 0), ShouldEqual, ErrQuorumOutOfRange)
+			So(b.WaitN(context.TODO(), participants+1), ShouldEqual, ErrQuorumOutOfRange)
+			So(b.IsDrained(), ShouldBeTrue)
+		})
+
+		Convey("k = 2 时，2 个参与者到达后，这一轮就会完成", func() {
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- b.WaitN(context.TODO(), 2)
+			}()
+			for b.NumberWaiting() < 1 {
+				runtime.Gosched()
+			}
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(<-errCh, ShouldBeNil)
+			So(b.Round(), ShouldEqual, 1)
+		})
+
+		Convey("k = 1 时，WaitN 立即完成这一轮，随后到达的参与者加入下一轮", func() {
+			var executed int32
+			b.SetAction(func() {
+				atomic.AddInt32(&executed, 1)
+			})
+			So(b.WaitN(context.TODO(), 1), ShouldBeNil)
+			So(b.Round(), ShouldEqual, 1)
+			for i := 0; i < participants; i++ {
+				goWait(b)
+			}
+			for b.Round() < 2 {
+				runtime.Gosched()
+			}
+			So(atomic.LoadInt32(&executed), ShouldEqual, 2)
+			So(b.IsDrained(), ShouldBeTrue)
+		})
+
+		Convey("并发的 WaitN 中，多出来的参与者会进入下一轮", func() {
+			var wg sync.WaitGroup
+			errs := make(chan error, participants*2)
+			wg.Add(participants * 2)
+			for i := 0; i < participants*2; i++ {
+				go func() {
+					errs <- b.WaitN(context.TODO(), 2)
+					wg.Done()
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				So(err, ShouldBeNil)
+			}
+			So(b.Round(), ShouldEqual, participants)
+		})
+	})
+}
+
+func TestTryArrive(t *testing.T) {
+	Convey("假设 Barrier 有 3 个参与者", t, func() {
+		b := New(3)
+
+		Convey("前 2 个 TryArrive 立即返回 false，并且计入了参与者", func() {
+			for i := 1; i <= 2; i++ {
+				tripped, err := b.(TryArriver).TryArrive()
+				So(tripped, ShouldBeFalse)
+				So(err, ShouldBeNil)
+				So(b.NumberWaiting(), ShouldEqual, i)
+			}
+
+			Convey("第 3 个 TryArrive 完成了这一轮，返回 true", func() {
+				tripped, err := b.(TryArriver).TryArrive()
+				So(tripped, ShouldBeTrue)
+				So(err, ShouldBeNil)
+				So(b.Round(), ShouldEqual, 1)
+				So(b.IsDrained(), ShouldBeTrue)
+			})
+
+			Convey("最后一个 Wait 的参与者也会完成这一轮，不会等待 TryArrive 的参与者", func() {
+				So(b.Wait(context.TODO()), ShouldBeNil)
+				So(b.Round(), ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestTryWait(t *testing.T) {
+	Convey("假设 Barrier 有 3 个参与者，其中 1 个已经在 Wait 了", t, func() {
+		participants := 3
+		b := New(participants)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- b.Wait(context.TODO())
+		}()
+		for b.NumberWaiting() < 1 {
+			runtime.Gosched()
+		}
+
+		Convey("同时有两个参与者 TryWait，都不是最后一个", func() {
+			var wg sync.WaitGroup
+			results := make(chan bool, 2)
+			wg.Add(2)
+			for i := 0; i < 2; i++ {
+				go func() {
+					completed, err := b.TryWait()
+					if err == nil {
+						results <- completed
+					}
+					wg.Done()
+				}()
+			}
+			wg.Wait()
+			close(results)
+			Convey("都会返回 false，并且不会计入参与者", func() {
+				for completed := range results {
+					So(completed, ShouldBeFalse)
+				}
+				So(b.NumberWaiting(), ShouldEqual, 1)
+			})
+		})
+
+		Convey("第 2 个参与者 Wait 后，TryWait 会完成这一轮", func() {
+			goWait(b)
+			for b.NumberWaiting() < 2 {
+				runtime.Gosched()
+			}
+			completed, err := b.TryWait()
+			So(completed, ShouldBeTrue)
+			So(err, ShouldBeNil)
+			So(<-errCh, ShouldBeNil)
+			So(b.NumberWaiting(), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestArriveAndAwaitRelease(t *testing.T) {
+	Convey("假设 Barrier 有 3 个参与者", t, func() {
+		participants := 3
+		actionCount := 0
+		b := New(participants).SetAction(func() {
+			actionCount++
+		})
+
+		Convey("参与者 1 Arrive，参与者 2 Wait，参与者 3 Arrive", func() {
+			token1, err := b.Arrive()
+			So(err, ShouldBeNil)
+			errCh := make(chan error, 2)
+			go func() {
+				errCh <- b.Wait(context.TODO())
+			}()
+			for b.NumberWaiting() < 2 {
+				runtime.Gosched()
+			}
+			token3, err := b.Arrive()
+			So(err, ShouldBeNil)
+			So(token3, ShouldNotEqual, token1)
+
+			Convey("所有的参与者都到达了，但是 Action 还没有执行", func() {
+				So(b.NumberWaiting(), ShouldEqual, participants)
+				So(actionCount, ShouldEqual, 0)
+				So(b.Round(), ShouldEqual, 0)
+			})
+
+			Convey("所有的参与者都 AwaitRelease 以后，Action 才会执行", func() {
+				go func() {
+					errCh <- b.AwaitRelease(context.TODO(), token1)
+				}()
+				So(b.AwaitRelease(context.TODO(), token3), ShouldBeNil)
+				So(<-errCh, ShouldBeNil)
+				So(<-errCh, ShouldBeNil)
+				So(actionCount, ShouldEqual, 1)
+				So(b.Round(), ShouldEqual, 1)
+
+				Convey("同一个 token 不能 AwaitRelease 两次", func() {
+					So(b.AwaitRelease(context.TODO(), token1), ShouldEqual, ErrInvalidToken)
+				})
+			})
+		})
+
+		Convey("round 被 Reset 以后，AwaitRelease 会返回 ErrBroken", func() {
+			token, err := b.Arrive()
+			So(err, ShouldBeNil)
+			b.Reset()
+			So(b.AwaitRelease(context.TODO(), token), shouldBeBrokenBy, ErrBroken)
+		})
+	})
+}
+
+func TestClose(t *testing.T) {
+	Convey("假设 Barrier 有 3 个参与者，其中 2 个已经在 Wait 了", t, func() {
+		b := New(3)
+		errs := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				errs <- b.Wait(context.TODO())
+			}()
+		}
+		for b.NumberWaiting() < 2 {
+			runtime.Gosched()
+		}
+
+		Convey("Close 以后", func() {
+			So(b.Close(), ShouldBeNil)
+
+			Convey("等待的参与者都会返回 ErrClosed", func() {
+				So(<-errs, ShouldEqual, ErrClosed)
+				So(<-errs, ShouldEqual, ErrClosed)
+				So(b.IsBroken(), ShouldBeTrue)
+			})
+
+			Convey("再次 Close 什么也不会发生", func() {
+				So(b.Close(), ShouldBeNil)
+			})
+
+			Convey("Wait，Break 和 TryWait 都会立即返回，并且不会计入参与者", func() {
+				So(b.Wait(context.TODO()), ShouldEqual, ErrClosed)
+				b.Break()
+				completed, err := b.TryWait()
+				So(completed, ShouldBeFalse)
+				So(err, ShouldEqual, ErrClosed)
+				So(b.NumberWaiting(), ShouldEqual, 2)
+			})
+		})
+	})
+}
+
+func TestCloseRace(t *testing.T) {
+	Convey("多个 goroutine 不停地 Wait 的时候，Barrier 被 Close 了", t, func() {
+		participants := 8
+		for i := 0; i < 20; i++ {
+			b := New(participants)
+			results := make(chan error, participants)
+			for p := 0; p < participants; p++ {
+				go func() {
+					for {
+						if err := b.Wait(context.TODO()); err != nil {
+							results <- err
+							return
+						}
+					}
+				}()
+			}
+			time.Sleep(time.Millisecond)
+			So(b.Close(), ShouldBeNil)
+
+			// 所有的 Wait 都会返回 ErrClosed，不会卡住
+			timeout := time.After(5 * time.Second)
+			for p := 0; p < participants; p++ {
+				select {
+				case err := <-results:
+					So(err, ShouldEqual, ErrClosed)
+				case <-timeout:
+					So("Wait 卡住了", ShouldBeEmpty)
+					return
+				}
+			}
+			So(b.Wait(context.TODO()), ShouldEqual, ErrClosed)
+			So(b.IsBroken(), ShouldBeTrue)
+		}
+	})
+}
+
+func TestLockFreeArrival(t *testing.T) {
+	Convey("只需要计数的到达，不用加锁", t, func() {
+		b := New(3).(*barrier)
+		So(atomic.LoadUint64(&b.round.state)&frozen, ShouldEqual, 0)
+		goWait(b)
+		for b.NumberWaiting() < 1 {
+			runtime.Gosched()
+		}
+		r, count, awaited, ok := b.arriveLockFree()
+		So(ok, ShouldBeTrue)
+		So(r, ShouldEqual, b.round)
+		So(count, ShouldEqual, 2)
+		So(awaited, ShouldEqual, 2)
+
+		Convey("加锁时，这一轮被冻结，到达要等锁", func() {
+			b.lockArrivals()
+			_, _, _, ok := b.arriveLockFree()
+			So(ok, ShouldBeFalse)
+			b.unlockArrivals()
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(b.Round(), ShouldEqual, 1)
+		})
+
+		Convey("设置了 Observer 以后，每一轮都被冻结", func() {
+			b.SetObserver(&recorder{})
+			_, _, _, ok := b.arriveLockFree()
+			So(ok, ShouldBeFalse)
+			b.SetObserver(nil)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(atomic.LoadUint64(&b.round.state)&frozen, ShouldEqual, 0)
+		})
+	})
+
+	Convey("不用加锁的到达，和加锁的到达交替的时候，每一轮都能完成", t, func() {
+		participants, rounds := 8, 200
+		b := New(participants)
+		stop := make(chan struct{})
+		toggled := make(chan struct{})
+		go func() {
+			defer close(toggled)
+			o := &recorder{}
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				b.SetObserver(o)
+				b.SetObserver(nil)
+				runtime.Gosched()
+			}
+		}()
+		var wg sync.WaitGroup
+		errs := make(chan error, participants)
+		for p := 0; p < participants; p++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < rounds; i++ {
+					if err := b.Wait(context.TODO()); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		close(stop)
+		<-toggled
+		So(len(errs), ShouldEqual, 0)
+		So(b.Round(), ShouldEqual, rounds)
+		So(b.Stats().PartiesServed, ShouldEqual, participants*rounds)
+	})
+}
+
+func TestWaitTimeout(t *testing.T) {
+	Convey("假设 Barrier 有 3 个参与者，其中 2 个已经在 Wait 了", t, func() {
+		participants := 3
+		b := New(participants)
+		errs := make(chan error, participants-1)
+		for i := 1; i < participants; i++ {
+			go func() {
+				errs <- b.WaitTimeout(10 * time.Millisecond)
+			}()
+		}
+
+		Convey("最后一个参与者迟迟不来，WaitTimeout 都会返回 ErrTimeout", func() {
+			for i := 1; i < participants; i++ {
+				So(errors.Is(<-errs, ErrTimeout), ShouldBeTrue)
+			}
+			So(b.IsBroken(), ShouldBeTrue)
+		})
+	})
+}
+
+func TestSetRoundTimeout(t *testing.T) {
+	Convey("假设 Barrier 有 2 个参与者，每一轮要在 10ms 内完成", t, func() {
+		timeout := 10 * time.Millisecond
+		b := New(2).SetRoundTimeout(timeout)
+
+		Convey("只有 1 个参与者到达，这一轮会超时被 break", func() {
+			err := b.Wait(context.TODO())
+			So(errors.Is(err, ErrTimeout), ShouldBeTrue)
+			So(b.IsBroken(), ShouldBeTrue)
+		})
+
+		Convey("参与者按时到达，这一轮正常完成，之后也不会被 break", func() {
+			goWait(b)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			time.Sleep(timeout * 2)
+			So(b.IsBroken(), ShouldBeFalse)
+			So(b.Round(), ShouldEqual, 1)
+		})
+	})
+}
+
+func TestLastArrivedContextCanceled(t *testing.T) {
+	Convey("假设 Barrier 有 2 个参与者，其中 1 个已经在 Wait 了", t, func() {
+		b := New(2)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- b.Wait(context.TODO())
+		}()
+		for b.NumberWaiting() < 1 {
+			runtime.Gosched()
+		}
+
+		Convey("最后一个参与者的 context 已经 cancel 了", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			err := b.Wait(ctx)
+
+			Convey("最后一个参与者会返回 context 的 error", func() {
+				So(err.Error(), ShouldEqual, "barrier is broken: context canceled")
+			})
+
+			Convey("这一轮被 break 了，另一个参与者也会返回 context 的 error", func() {
+				So(<-errCh, ShouldEqual, err)
+			})
+
+			Convey("下一轮不受影响", func() {
+				So(b.IsBroken(), ShouldBeFalse)
+				goWait(b)
+				So(b.Wait(context.TODO()), ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestContextCancelCause(t *testing.T) {
+	Convey("假设 Barrier 有 3 个参与者，其中 1 个参与者的 context 会被 cancel", t, func() {
+		b := New(3)
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error, 2)
+		go func() {
+			errs <- b.Wait(context.TODO())
+		}()
+		go func() {
+			errs <- b.Wait(ctx)
+		}()
+		for b.NumberWaiting() < 2 {
+			runtime.Gosched()
+		}
+		cancel()
+
+		Convey("这一轮所有的参与者，都能知道是 context 被 cancel 了", func() {
+			So(errors.Is(<-errs, context.Canceled), ShouldBeTrue)
+			So(errors.Is(<-errs, context.Canceled), ShouldBeTrue)
+			So(errors.Is(b.Wait(context.TODO()), context.Canceled), ShouldBeTrue)
+		})
+	})
+}
+
+// TODO: 这里出现过报错
+func TestBarrierCyclic(t *testing.T) {
+	round := 5
+	participants := 7
+	roundCount := 0
+	roundCh := make(chan int, 1)
+	b := New(participants).SetAction(func() {
+		roundCount++
+		roundCh <- roundCount
+	})
+
+	Convey("循环使用同一个 Barrier", t, func() {
+		for r := 1; r <= round; r++ {
+			for p := 1; p < participants; p++ {
+				goWait(b)
+				So(count(b), ShouldEqual, p)
+			}
+			// err := b.Wait(context.TODO())
+			// So(err, ShouldBeNil)
 			err := b.Wait(context.TODO())
 			So(err, ShouldBeNil)
 			So(<-roundCh, ShouldEqual, r)
@@ -277,6 +1665,94 @@ func TestBarrierCyclic(t *testing.T) {
 	})
 }
 
+func TestIsDrained(t *testing.T) {
+	Convey("假设 Barrier 有 2 个参与者", t, func() {
+		b := New(2)
+
+		Convey("还没有参与者到达的时候，是 drained", func() {
+			So(b.IsDrained(), ShouldBeTrue)
+		})
+
+		Convey("有参与者在 Wait 的时候，不是 drained", func() {
+			goWait(b)
+			for b.NumberWaiting() < 1 {
+				runtime.Gosched()
+			}
+			So(b.IsDrained(), ShouldBeFalse)
+
+			Convey("完成一轮以后，又是 drained", func() {
+				So(b.Wait(context.TODO()), ShouldBeNil)
+				So(b.IsDrained(), ShouldBeTrue)
+			})
+		})
+
+		Convey("Close 以后，不是 drained", func() {
+			b.Close()
+			So(b.IsDrained(), ShouldBeFalse)
+		})
+	})
+}
+
+func TestRound(t *testing.T) {
+	round := 5
+	participants := 3
+	b := New(participants)
+
+	Convey("循环使用同一个 Barrier 的时候，Round 等于已经完成的轮数", t, func() {
+		So(b.Round(), ShouldEqual, 0)
+		for r := 1; r <= round; r++ {
+			for p := 1; p < participants; p++ {
+				goWait(b)
+			}
+			for b.NumberWaiting() < participants-1 {
+				runtime.Gosched()
+			}
+			if r%2 == 0 {
+				b.Break()
+			} else {
+				b.Wait(context.TODO())
+			}
+			So(b.Round(), ShouldEqual, r)
+		}
+		b.Reset()
+		So(b.Round(), ShouldEqual, round+1)
+	})
+}
+
+func TestStats(t *testing.T) {
+	participants := 3
+	b := New(participants)
+
+	Convey("成功和 Break 的轮次交替进行以后，Stats 会统计所有的轮次", t, func() {
+		So(b.Stats(), ShouldResemble, Stats{})
+		start := time.Now()
+		for r := 1; r <= 5; r++ {
+			for p := 1; p < participants; p++ {
+				goWait(b)
+			}
+			for b.NumberWaiting() < participants-1 {
+				runtime.Gosched()
+			}
+			if r%2 == 0 {
+				b.Break()
+			} else {
+				b.Wait(context.TODO())
+			}
+		}
+		goWait(b)
+		for b.NumberWaiting() < 1 {
+			runtime.Gosched()
+		}
+		b.Reset()
+
+		stats := b.Stats()
+		So(stats.CompletedRounds, ShouldEqual, 3)
+		So(stats.BrokenRounds, ShouldEqual, 3)
+		So(stats.PartiesServed, ShouldEqual, 5*participants+1)
+		So(stats.LastCompletion, ShouldHappenOnOrAfter, start)
+	})
+}
+
 // below is benchmark
 
 func oneRound(parties, cycles int, wait func(context.Context) error) {
@@ -297,6 +1773,7 @@ func Benchmark_CyclicBarrier(b *testing.B) {
 	parties := 10
 	cycles := 10
 	cb := cyclicbarrier.New(parties)
+	b.ReportAllocs()
 	//
 	for i := 1; i < b.N; i++ {
 		oneRound(parties, cycles, cb.Await)
@@ -307,12 +1784,83 @@ func Benchmark_Barrier(b *testing.B) {
 	parties := 10
 	cycles := 10
 	cb := New(parties)
+	b.ReportAllocs()
 	//
 	for i := 1; i < b.N; i++ {
 		oneRound(parties, cycles, cb.Wait)
 	}
 }
 
+// cycle lets parties goroutines wait b.N rounds by wait, each round is an op.
+func cycle(b *testing.B, parties int, wait func(context.Context) error) {
+	var wg sync.WaitGroup
+	wg.Add(parties - 1)
+	for i := 1; i < parties; i++ {
+		go func() {
+			for n := 0; n < b.N; n++ {
+				wait(context.TODO())
+			}
+			wg.Done()
+		}()
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		wait(context.TODO())
+	}
+	wg.Wait()
+}
+
+func Benchmark_Cycle_8(b *testing.B) {
+	cycle(b, 8, New(8).Wait)
+}
+
+func Benchmark_Cycle_8_Spin(b *testing.B) {
+	cycle(b, 8, New(8, WithSpin(100)).Wait)
+}
+
+func Benchmark_Cycle_64(b *testing.B) {
+	cycle(b, 64, New(64).Wait)
+}
+
+func Benchmark_Cycle_64_CondBased(b *testing.B) {
+	cycle(b, 64, New(64, WithWaitStrategy(CondBased)).Wait)
+}
+
+func Benchmark_Cycle_64_CondBased_Action(b *testing.B) {
+	cycle(b, 64, New(64, WithWaitStrategy(CondBased)).SetAction(func() {}).Wait)
+}
+
+func Benchmark_Cycle_64_CyclicBarrier(b *testing.B) {
+	cycle(b, 64, cyclicbarrier.New(64).Await)
+}
+
+// benchmarkFanout reports the wake-up latency of the release by fanout-ns/op,
+// and the CPU cost of a round by cpu-ns/op, which is 0 if the CPU time of
+// the process is not measured on the platform.
+func benchmarkFanout(b *testing.B, opts ...Option) {
+	parties := 1000
+	cb := New(parties, opts...)
+	var fanout time.Duration
+	b.ResetTimer()
+	cpu := cpuTime()
+	for i := 0; i < b.N; i++ {
+		oneRound(parties, 1, cb.Wait)
+		fanout += cb.(FanoutReporter).LastReleaseFanoutDuration()
+	}
+	cpu = cpuTime() - cpu
+	b.ReportMetric(float64(fanout.Nanoseconds())/float64(b.N), "fanout-ns/op")
+	b.ReportMetric(float64(cpu.Nanoseconds())/float64(b.N), "cpu-ns/op")
+}
+
+func Benchmark_Fanout_Simultaneous(b *testing.B) {
+	benchmarkFanout(b)
+}
+
+func Benchmark_Fanout_Staggered(b *testing.B) {
+	benchmarkFanout(b, WithStaggeredWakeup(100))
+}
+
 type boc struct {
 	isOk bool
 	l    sync.RWMutex
@@ -384,3 +1932,80 @@ func Benchmark_boc_readclosedChannel(b *testing.B) {
 		wg.Wait()
 	}
 }
+
+func TestNewQuorum(t *testing.T) {
+	Convey("quorum 不在 [1, participants] 之间时，NewQuorum 会 panic", t, func() {
+		So(func() { NewQuorum(3, 0) }, ShouldPanicWith, ErrQuorumOutOfRange.Error())
+		So(func() { NewQuorum(3, 4) }, ShouldPanicWith, ErrQuorumOutOfRange.Error())
+	})
+
+	Convey("假设 Barrier 有 3 个参与者，quorum 为 2", t, func() {
+		participants := 3
+		var executed int32
+		b := NewQuorum(participants, 2, WithAction(func() error {
+			atomic.AddInt32(&executed, 1)
+			return nil
+		}))
+
+		Convey("2 个参与者到达后，这一轮就会完成", func() {
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- b.Wait(context.TODO())
+			}()
+			for b.NumberWaiting() < 1 {
+				runtime.Gosched()
+			}
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(<-errCh, ShouldBeNil)
+			So(b.Round(), ShouldEqual, 1)
+
+			Convey("迟到的参与者加入已完成的这一轮，立即返回，不会再次执行 action", func() {
+				So(b.Wait(context.TODO()), ShouldBeNil)
+				So(atomic.LoadInt32(&executed), ShouldEqual, 1)
+				So(b.Round(), ShouldEqual, 1)
+				So(b.IsDrained(), ShouldBeTrue)
+
+				Convey("所有参与者到达后，下一轮才会开始", func() {
+					go func() {
+						errCh <- b.Wait(context.TODO())
+					}()
+					So(b.Wait(context.TODO()), ShouldBeNil)
+					So(<-errCh, ShouldBeNil)
+					So(b.Round(), ShouldEqual, 2)
+					So(atomic.LoadInt32(&executed), ShouldEqual, 2)
+				})
+			})
+		})
+
+		Convey("等待被取消时，这一轮会被打破", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			So(b.Wait(ctx), shouldBeBrokenBy, context.Canceled)
+		})
+	})
+}
+
+func TestRuntimeTrace(t *testing.T) {
+	Convey("假设 Barrier 有 2 个参与者，并且启用了 runtime/trace", t, func() {
+		b := New(2).(*barrier)
+		var buf bytes.Buffer
+		So(trace.Start(&buf), ShouldBeNil)
+
+		Convey("启用以后开始的每一轮都有一个 task", func() {
+			So(b.round.task, ShouldBeNil)
+			for i := 0; i < 3; i++ {
+				goWait(b)
+				So(b.Wait(context.TODO()), ShouldBeNil)
+				So(b.round.task, ShouldNotBeNil)
+			}
+			trace.Stop()
+			So(buf.Len(), ShouldBeGreaterThan, 0)
+		})
+
+		Reset(func() {
+			if trace.IsEnabled() {
+				trace.Stop()
+			}
+		})
+	})
+}
@@ -0,0 +1,110 @@
+// Package barrierprom exports the metrics of a Barrier to Prometheus.
+package barrierprom
+
+import (
+	"time"
+
+	"github.com/aQuaYi/barrier"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "barrier"
+
+// Collector is a prometheus.Collector of a Barrier, which exports
+//
+//	barrier_rounds_total            rounds completed, successfully or broken
+//	barrier_broken_rounds_total     rounds broken
+//	barrier_current_waiting         goroutines arrived in the current round
+//	barrier_wait_duration_seconds   histogram of the waits
+//	barrier_action_duration_seconds histogram of the actions
+//
+// with the label barrier, whose value is the name of the Barrier.
+type Collector struct {
+	b       barrier.Barrier
+	rounds  *prometheus.Desc
+	broken  *prometheus.Desc
+	waiting *prometheus.Desc
+	waits   prometheus.Histogram
+	actions prometheus.Histogram
+}
+
+// NewCollector initializes a new instance of the Collector of b named name.
+// It adds an observer to b to measure the durations, which is combined
+// with the observer set before, if b is a barrier.ObserverAdder, like the
+// Barrier returned by barrier.New. Otherwise it replaces the observer by
+// SetObserver.
+func NewCollector(b barrier.Barrier, name string) *Collector {
+	labels := prometheus.Labels{"barrier": name}
+	c := &Collector{
+		b: b,
+		rounds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "rounds_total"),
+			"Rounds completed, successfully or broken.",
+			nil, labels),
+		broken: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "broken_rounds_total"),
+			"Rounds broken.",
+			nil, labels),
+		waiting: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "current_waiting"),
+			"Goroutines arrived in the current round.",
+			nil, labels),
+		waits: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "wait_duration_seconds",
+			Help:        "How long the goroutines waited.",
+			ConstLabels: labels,
+		}),
+		actions: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "action_duration_seconds",
+			Help:        "How long the actions of the rounds took.",
+			ConstLabels: labels,
+		}),
+	}
+	if adder, ok := b.(barrier.ObserverAdder); ok {
+		adder.AddObserver(observer{c})
+	} else {
+		b.SetObserver(observer{c})
+	}
+	return c
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.rounds
+	ch <- c.broken
+	ch <- c.waiting
+	c.waits.Describe(ch)
+	c.actions.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.b.Stats()
+	ch <- prometheus.MustNewConstMetric(c.rounds, prometheus.CounterValue,
+		float64(stats.CompletedRounds+stats.BrokenRounds))
+	ch <- prometheus.MustNewConstMetric(c.broken, prometheus.CounterValue,
+		float64(stats.BrokenRounds))
+	ch <- prometheus.MustNewConstMetric(c.waiting, prometheus.GaugeValue,
+		float64(c.b.NumberWaiting()))
+	c.waits.Collect(ch)
+	c.actions.Collect(ch)
+}
+
+// observer measures the durations for c.
+type observer struct {
+	c *Collector
+}
+
+func (o observer) OnArrive(int)  {}
+func (o observer) OnTrip(uint64) {}
+func (o observer) OnBreak(error) {}
+
+func (o observer) OnWaited(d time.Duration) {
+	o.c.waits.Observe(d.Seconds())
+}
+
+func (o observer) OnAction(d time.Duration) {
+	o.c.actions.Observe(d.Seconds())
+}
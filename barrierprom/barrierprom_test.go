@@ -0,0 +1,107 @@
+package barrierprom
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aQuaYi/barrier"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// gather returns the metrics of reg by name.
+func gather(reg *prometheus.Registry) map[string]*dto.Metric {
+	families, err := reg.Gather()
+	So(err, ShouldBeNil)
+	res := make(map[string]*dto.Metric, len(families))
+	for _, f := range families {
+		So(len(f.Metric), ShouldEqual, 1)
+		res[f.GetName()] = f.Metric[0]
+	}
+	return res
+}
+
+func TestCollector(t *testing.T) {
+	Convey("假设 Barrier 有 2 个参与者，并注册了 Collector", t, func() {
+		b := barrier.New(2, barrier.WithAction(func() error {
+			time.Sleep(time.Millisecond)
+			return nil
+		}))
+		reg := prometheus.NewRegistry()
+		So(reg.Register(NewCollector(b, "test")), ShouldBeNil)
+
+		Convey("完成 2 轮，被打破 1 轮，还有 1 个参与者在等待", func() {
+			for i := 0; i < 2; i++ {
+				go b.Wait(context.TODO())
+				So(b.Wait(context.TODO()), ShouldBeNil)
+			}
+			b.Break()
+			b.Wait(context.TODO())
+			go b.Wait(context.TODO())
+			for b.NumberWaiting() < 1 {
+				runtime.Gosched()
+			}
+			metrics := gather(reg)
+			So(metrics["barrier_rounds_total"].GetCounter().GetValue(), ShouldEqual, 3)
+			So(metrics["barrier_broken_rounds_total"].GetCounter().GetValue(), ShouldEqual, 1)
+			So(metrics["barrier_current_waiting"].GetGauge().GetValue(), ShouldEqual, 1)
+			actions := metrics["barrier_action_duration_seconds"].GetHistogram()
+			So(actions.GetSampleCount(), ShouldBeGreaterThanOrEqualTo, 2)
+			So(actions.GetSampleSum(), ShouldBeGreaterThanOrEqualTo, 0.002)
+			So(metrics["barrier_wait_duration_seconds"].GetHistogram().GetSampleCount(),
+				ShouldBeGreaterThanOrEqualTo, 4)
+			label := metrics["barrier_rounds_total"].GetLabel()[0]
+			So(label.GetName(), ShouldEqual, "barrier")
+			So(label.GetValue(), ShouldEqual, "test")
+			b.Close()
+		})
+	})
+
+	Convey("假设 Barrier 已经设置了 observer，再注册 Collector", t, func() {
+		var trips int32
+		b := barrier.New(1, barrier.WithObserver(tripCounter{&trips}))
+		reg := prometheus.NewRegistry()
+		So(reg.Register(NewCollector(b, "test")), ShouldBeNil)
+
+		Convey("原来的 observer 依然会被通知，Collector 也会测量等待", func() {
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(atomic.LoadInt32(&trips), ShouldEqual, 1)
+			So(gather(reg)["barrier_wait_duration_seconds"].GetHistogram().GetSampleCount(), ShouldEqual, 1)
+		})
+	})
+
+	Convey("假设 Barrier 只有 1 个参与者，并注册了 Collector", t, func() {
+		b := barrier.New(1)
+		reg := prometheus.NewRegistry()
+		So(reg.Register(NewCollector(b, "test")), ShouldBeNil)
+
+		Convey("Wait 的各种变体都会被测量", func() {
+			ctx := context.TODO()
+			_, err := b.WaitIndexed(ctx)
+			So(err, ShouldBeNil)
+			So((<-b.(barrier.ChanWaiter).WaitChan(ctx)).Err, ShouldBeNil)
+			So(b.WaitAction(ctx, func() error { return nil }), ShouldBeNil)
+			So(b.WaitWeighted(ctx, 1), ShouldBeNil)
+			token, err := b.Arrive()
+			So(err, ShouldBeNil)
+			So(b.AwaitRelease(ctx, token), ShouldBeNil)
+			So(gather(reg)["barrier_wait_duration_seconds"].GetHistogram().GetSampleCount(), ShouldEqual, 5)
+		})
+	})
+}
+
+// tripCounter counts the trips it is notified of.
+type tripCounter struct {
+	trips *int32
+}
+
+func (o tripCounter) OnArrive(int)  {}
+func (o tripCounter) OnBreak(error) {}
+
+func (o tripCounter) OnTrip(uint64) {
+	atomic.AddInt32(o.trips, 1)
+}
@@ -0,0 +1,25 @@
+module github.com/aQuaYi/barrier/barrierprom
+
+go 1.18
+
+require (
+	github.com/aQuaYi/barrier v0.0.0-20261017072745-c5729500169b
+	github.com/prometheus/client_golang v1.14.0
+	github.com/prometheus/client_model v0.3.0
+	github.com/smartystreets/goconvey v0.0.0-20190731233626-505e41936337
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.1.2 // indirect
+	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/gopherjs/gopherjs v0.0.0-20190915194858-d3ddacdb130f // indirect
+	github.com/jtolds/gls v4.20.0+incompatible // indirect
+	github.com/marusama/cyclicbarrier v0.0.0-20181027101648-08d457ab265c // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
+	github.com/prometheus/common v0.37.0 // indirect
+	github.com/prometheus/procfs v0.8.0 // indirect
+	github.com/smartystreets/assertions v1.0.1 // indirect
+	golang.org/x/sys v0.0.0-20220919091848-fb04ddd9f9c8 // indirect
+	google.golang.org/protobuf v1.28.1 // indirect
+)
@@ -0,0 +1,237 @@
+package barriertest
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/aQuaYi/barrier"
+)
+
+// ErrInjected is the cause of the rounds broken by Chaos.
+var ErrInjected = errors.New("barrier fault is injected by chaos")
+
+// Config configures the faults injected by Chaos.
+type Config struct {
+	// BreakProbability is the probability that an arrival breaks the round
+	// with ErrInjected, instead of waiting.
+	BreakProbability float64
+	// CancelProbability is the probability that the context of an arrival
+	// is canceled, within MaxDelay after the arrival.
+	CancelProbability float64
+	// MaxDelay is the maximum random delay of the arrivals.
+	MaxDelay time.Duration
+	// Seed seeds the random decisions, so that the same seed makes the same
+	// decisions for the arrivals in the same order.
+	Seed int64
+}
+
+// Chaos returns a Barrier, which waits b, but randomly delays the arrivals,
+// breaks the rounds, and cancels the contexts of the waiting goroutines,
+// according to cfg. It verifies the parties of b survive the broken rounds.
+//
+// The faults are injected into the arrivals of the Wait methods, including
+// WaitTimeout, which is never canceled. The arrival breaking the round
+// arrives b with a context done with ErrInjected, so that b breaks the
+// round, and the arrival returns the error reported by b, like the other
+// parties of the round. With barrier.LeaveQuietly, the arrival leaves the
+// round instead, and returns ErrInjected.
+// The chaining methods, like SetAction and OnBroken, return the Barrier
+// of Chaos, and the other methods of b are called as they are.
+func Chaos(b barrier.Barrier, cfg Config) barrier.Barrier {
+	return &chaos{
+		Barrier: b,
+		cfg:     cfg,
+		rand:    rand.New(rand.NewSource(cfg.Seed)),
+	}
+}
+
+type chaos struct {
+	barrier.Barrier
+	cfg  Config
+	lock sync.Mutex // guards rand
+	rand *rand.Rand
+}
+
+// fault is the faults of an arrival decided by Chaos.
+type fault struct {
+	delay       time.Duration
+	breaks      bool
+	cancels     bool
+	cancelAfter time.Duration
+}
+
+// decide decides the faults of an arrival.
+func (c *chaos) decide() (f fault) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.cfg.MaxDelay > 0 {
+		f.delay = time.Duration(c.rand.Int63n(int64(c.cfg.MaxDelay)))
+		f.cancelAfter = time.Duration(c.rand.Int63n(int64(c.cfg.MaxDelay)))
+	}
+	f.breaks = c.rand.Float64() < c.cfg.BreakProbability
+	f.cancels = c.rand.Float64() < c.cfg.CancelProbability
+	return
+}
+
+// inject delays the arrival with ctx, and returns the context to arrive
+// with, which is canceled by cancel after the arrival returns.
+// The context is done with ErrInjected, if the fault breaks the round.
+func (c *chaos) inject(ctx context.Context) (context.Context, context.CancelFunc) {
+	f := c.decide()
+	// the arrival with ctx done breaks the round, instead of leaving it.
+	sleep(ctx, f.delay)
+	if f.breaks {
+		return injected{ctx}, func() {}
+	}
+	if !f.cancels {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	if f.cancelAfter <= 0 {
+		cancel()
+		return ctx, cancel
+	}
+	timer := time.AfterFunc(f.cancelAfter, cancel)
+	return ctx, func() {
+		timer.Stop()
+		cancel()
+	}
+}
+
+// closed is the channel returned by injected.Done.
+var closed = make(chan struct{})
+
+func init() {
+	close(closed)
+}
+
+// injected is a context done with ErrInjected, which breaks the round
+// arrived with it, as the barrier breaks the round with the error of the
+// context of the arrival.
+type injected struct {
+	context.Context
+}
+
+func (injected) Done() <-chan struct{} {
+	return closed
+}
+
+func (injected) Err() error {
+	return ErrInjected
+}
+
+// sleep pauses for d, unless ctx is done before then.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *chaos) Wait(ctx context.Context) error {
+	ctx, cancel := c.inject(ctx)
+	defer cancel()
+	return c.Barrier.Wait(ctx)
+}
+
+func (c *chaos) WaitAs(ctx context.Context, name string) error {
+	ctx, cancel := c.inject(ctx)
+	defer cancel()
+	return c.Barrier.WaitAs(ctx, name)
+}
+
+func (c *chaos) WaitExchange(ctx context.Context, mine interface{}) ([]interface{}, error) {
+	ctx, cancel := c.inject(ctx)
+	defer cancel()
+	return c.Barrier.WaitExchange(ctx, mine)
+}
+
+func (c *chaos) WaitAction(ctx context.Context, action func() error) error {
+	ctx, cancel := c.inject(ctx)
+	defer cancel()
+	return c.Barrier.WaitAction(ctx, action)
+}
+
+func (c *chaos) WaitIndexed(ctx context.Context) (int, error) {
+	ctx, cancel := c.inject(ctx)
+	defer cancel()
+	return c.Barrier.WaitIndexed(ctx)
+}
+
+func (c *chaos) WaitIndex(ctx context.Context) (int, error) {
+	ctx, cancel := c.inject(ctx)
+	defer cancel()
+	return c.Barrier.WaitIndex(ctx)
+}
+
+func (c *chaos) WaitN(ctx context.Context, k int) error {
+	ctx, cancel := c.inject(ctx)
+	defer cancel()
+	return c.Barrier.WaitN(ctx, k)
+}
+
+func (c *chaos) WaitWeighted(ctx context.Context, n int) error {
+	ctx, cancel := c.inject(ctx)
+	defer cancel()
+	return c.Barrier.WaitWeighted(ctx, n)
+}
+
+func (c *chaos) WaitTimeout(d time.Duration) error {
+	f := c.decide()
+	time.Sleep(f.delay)
+	if f.breaks {
+		// the arrival breaks the round at once, which is never timed out.
+		return c.Barrier.Wait(injected{context.Background()})
+	}
+	return c.Barrier.WaitTimeout(d)
+}
+
+func (c *chaos) SetAction(action func()) barrier.Barrier {
+	c.Barrier.SetAction(action)
+	return c
+}
+
+func (c *chaos) SetActionE(action func() error) barrier.Barrier {
+	c.Barrier.SetActionE(action)
+	return c
+}
+
+func (c *chaos) SetActionCtx(action func(context.Context) error) barrier.Barrier {
+	c.Barrier.SetActionCtx(action)
+	return c
+}
+
+func (c *chaos) AddAction(action func() error) barrier.Barrier {
+	c.Barrier.AddAction(action)
+	return c
+}
+
+func (c *chaos) SetRoundTimeout(d time.Duration) barrier.Barrier {
+	c.Barrier.SetRoundTimeout(d)
+	return c
+}
+
+func (c *chaos) OnBroken(hook func(cause error)) barrier.Barrier {
+	c.Barrier.OnBroken(hook)
+	return c
+}
+
+func (c *chaos) OnRelease(hook func(barrier.RoundInfo)) barrier.Barrier {
+	c.Barrier.OnRelease(hook)
+	return c
+}
+
+func (c *chaos) SetObserver(o barrier.Observer) barrier.Barrier {
+	c.Barrier.SetObserver(o)
+	return c
+}
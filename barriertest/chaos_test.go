@@ -0,0 +1,105 @@
+package barriertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aQuaYi/barrier"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestChaos(t *testing.T) {
+	ctx := context.TODO()
+
+	Convey("假设 Chaos 一定会 break 这一轮", t, func() {
+		b := barrier.New(2)
+		c := Chaos(b, Config{BreakProbability: 1})
+
+		Convey("到达的参与者和其他参与者都返回包装了 ErrInjected 的 BrokenError", func() {
+			ch := goWait(b.Wait)
+			for b.NumberWaiting() < 1 {
+				time.Sleep(time.Millisecond)
+			}
+			err := c.Wait(ctx)
+			So(errors.Is(err, barrier.ErrBroken), ShouldBeTrue)
+			So(errors.Is(err, ErrInjected), ShouldBeTrue)
+			err = <-ch
+			So(errors.Is(err, barrier.ErrBroken), ShouldBeTrue)
+			So(errors.Is(err, ErrInjected), ShouldBeTrue)
+		})
+
+		Convey("WaitAs 的参与者返回的错误，和 Barrier 报告给其他参与者的一样", func() {
+			// 先完成一轮，被 break 的是第 1 轮
+			ch := goWait(b.Wait)
+			So(b.Wait(ctx), ShouldBeNil)
+			So(<-ch, ShouldBeNil)
+			ch = goWait(b.Wait)
+			for b.NumberWaiting() < 1 {
+				time.Sleep(time.Millisecond)
+			}
+			var mine, theirs *barrier.BrokenError
+			So(errors.As(c.WaitAs(ctx, "chaos"), &mine), ShouldBeTrue)
+			So(errors.As(<-ch, &theirs), ShouldBeTrue)
+			So(*mine, ShouldResemble, *theirs)
+			So(mine.Round, ShouldEqual, 1)
+			So(mine.By, ShouldEqual, "chaos")
+		})
+
+		Convey("链式调用返回的还是 Chaos", func() {
+			So(c.SetAction(func() {}), ShouldEqual, c)
+			So(c.OnBroken(func(error) {}), ShouldEqual, c)
+			So(c.OnRelease(func(barrier.RoundInfo) {}), ShouldEqual, c)
+			So(c.SetObserver(nil), ShouldEqual, c)
+			So(c.SetRoundTimeout(0), ShouldEqual, c)
+		})
+
+		Convey("WaitIndexed 和 WaitTimeout 也会 break 这一轮", func() {
+			_, err := c.WaitIndexed(ctx)
+			So(errors.Is(err, ErrInjected), ShouldBeTrue)
+			So(errors.Is(c.WaitTimeout(time.Second), ErrInjected), ShouldBeTrue)
+		})
+	})
+
+	Convey("假设 Chaos 一定会取消 context", t, func() {
+		b := barrier.New(2)
+		c := Chaos(b, Config{CancelProbability: 1, MaxDelay: 5 * time.Millisecond})
+
+		Convey("等待的参与者会因为 context 被取消而 break 这一轮", func() {
+			err := c.Wait(ctx)
+			So(errors.Is(err, context.Canceled), ShouldBeTrue)
+			var be *barrier.BrokenError
+			So(errors.As(err, &be), ShouldBeTrue)
+			So(be.Canceled, ShouldBeTrue)
+			So(b.IsBroken(), ShouldBeTrue)
+		})
+	})
+
+	Convey("假设 Chaos 不注入错误，只延迟到达", t, func() {
+		b := barrier.New(2)
+		c := Chaos(b, Config{MaxDelay: 5 * time.Millisecond})
+
+		Convey("这一轮照常完成", func() {
+			ch := goWait(c.Wait)
+			So(c.Wait(ctx), ShouldBeNil)
+			So(<-ch, ShouldBeNil)
+			So(b.Round(), ShouldEqual, 1)
+		})
+	})
+
+	Convey("相同的 Seed 会做出相同的决定", t, func() {
+		decisions := func(seed int64) []bool {
+			c := Chaos(barrier.New(1), Config{BreakProbability: 0.5, Seed: seed})
+			var res []bool
+			for i := 0; i < 32; i++ {
+				res = append(res, c.Wait(ctx) != nil)
+			}
+			return res
+		}
+		first := decisions(42)
+		So(decisions(42), ShouldResemble, first)
+		So(first, ShouldContain, true)
+		So(first, ShouldContain, false)
+	})
+}
@@ -0,0 +1,129 @@
+package barriertest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aQuaYi/barrier"
+)
+
+// Controller is a real barrier.Barrier, whose rounds are released step by
+// step by the test. Once all the parties of a round have arrived, the round
+// is held by a gate, which waits for ReleaseRound or InjectBreak, so the
+// test decides when the parties go on, without sleeping.
+//
+// The gate is an action of the barrier. The action set by the options of
+// NewController runs before it, and the actions added by AddAction run
+// after it, unless the round is broken by InjectBreak. SetAction and the
+// others replace the gate, and so does the action of WaitAction.
+type Controller struct {
+	barrier.Barrier
+	events  barrier.EventSubscriber // the Barrier
+	release chan error              // received by the gate of the tripped round
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewController returns a Controller of the barrier created by
+// barrier.New with parties and opts.
+func NewController(parties int, opts ...barrier.Option) *Controller {
+	b := barrier.New(parties, opts...)
+	c := &Controller{
+		Barrier: b,
+		events:  b.(barrier.EventSubscriber),
+		release: make(chan error),
+		done:    make(chan struct{}),
+	}
+	c.AddAction(c.gate)
+	return c
+}
+
+// gate holds the tripped round until the test releases or breaks it.
+func (c *Controller) gate() error {
+	select {
+	case err := <-c.release:
+		return err
+	case <-c.done:
+		return barrier.ErrClosed
+	}
+}
+
+// watch returns a channel, which receives a signal after every arrival
+// and completed round, until stop is called. It is closed by Close.
+func (c *Controller) watch() (signals <-chan barrier.RoundEvent, stop func()) {
+	// only the latest event is kept, the others are signals alike.
+	events := c.events.Subscribe(1, barrier.DropOldest, barrier.EventArrived|barrier.EventTripped)
+	return events, func() {
+		c.events.Unsubscribe(events)
+	}
+}
+
+// WaitUntilArrived waits until n parties have arrived in the current round.
+// Once it returns, the n goroutines are waiting in the round, or the round
+// is held by the gate if n is all of its parties.
+// It returns ctx.Err() if ctx is done before then, and barrier.ErrClosed
+// if the barrier is closed.
+func (c *Controller) WaitUntilArrived(ctx context.Context, n int) error {
+	signals, stop := c.watch()
+	defer stop()
+	for c.NumberWaiting() < n {
+		select {
+		case _, ok := <-signals:
+			if !ok {
+				return barrier.ErrClosed
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// ReleaseRound waits until all the parties of the current round have
+// arrived, and releases the round. Once it returns, the round has
+// completed, and counted by Round, and its parties return nil from Wait.
+// It returns ctx.Err() if ctx is done before the round trips.
+func (c *Controller) ReleaseRound(ctx context.Context) error {
+	return c.step(ctx, nil)
+}
+
+// InjectBreak is ReleaseRound, but the round is broken with cause, as if
+// an action returned it. The parties of the round return a
+// *barrier.BrokenError wrapping cause.
+func (c *Controller) InjectBreak(ctx context.Context, cause error) error {
+	if cause == nil {
+		cause = barrier.ErrBroken
+	}
+	return c.step(ctx, cause)
+}
+
+// step passes err to the gate of the tripped round, and waits until the
+// round is completed.
+func (c *Controller) step(ctx context.Context, err error) error {
+	signals, stop := c.watch()
+	defer stop()
+	// the tripped round is held by the gate, so Round is not changing.
+	round := c.Round()
+	select {
+	case c.release <- err:
+	case <-c.done:
+		return barrier.ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	for c.Round() == round {
+		if _, ok := <-signals; !ok {
+			return barrier.ErrClosed
+		}
+	}
+	return nil
+}
+
+// Close releases the round held by the gate with barrier.ErrClosed,
+// and closes the barrier.
+func (c *Controller) Close() error {
+	c.once.Do(func() {
+		close(c.done)
+	})
+	return c.Barrier.Close()
+}
@@ -0,0 +1,99 @@
+package barriertest
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aQuaYi/barrier"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestController(t *testing.T) {
+	Convey("假设 Controller 控制有 3 个参与者的 Barrier", t, func() {
+		var actions int32
+		c := NewController(3)
+		c.AddAction(func() error {
+			atomic.AddInt32(&actions, 1)
+			return nil
+		})
+		ctx := context.TODO()
+		defer c.Close()
+
+		Convey("WaitUntilArrived 返回时，参与者都已经到达", func() {
+			goWait(c.Wait)
+			goWait(c.Wait)
+			So(c.WaitUntilArrived(ctx, 2), ShouldBeNil)
+			So(c.NumberWaiting(), ShouldEqual, 2)
+		})
+
+		Convey("所有参与者都到达后，这一轮要等 ReleaseRound 才会完成", func() {
+			errs := []<-chan error{goWait(c.Wait), goWait(c.Wait), goWait(c.Wait)}
+			So(c.WaitUntilArrived(ctx, 3), ShouldBeNil)
+			So(c.Round(), ShouldEqual, 0)
+			for _, ch := range errs {
+				select {
+				case <-ch:
+					t.Fatal("released before ReleaseRound")
+				default:
+				}
+			}
+
+			So(c.ReleaseRound(ctx), ShouldBeNil)
+			So(c.Round(), ShouldEqual, 1)
+			So(atomic.LoadInt32(&actions), ShouldEqual, 1)
+			for _, ch := range errs {
+				So(<-ch, ShouldBeNil)
+			}
+		})
+
+		Convey("InjectBreak 让这一轮的参与者返回包装了原因的 BrokenError", func() {
+			cause := errors.New("injected")
+			errs := []<-chan error{goWait(c.Wait), goWait(c.Wait), goWait(c.Wait)}
+			So(c.InjectBreak(ctx, cause), ShouldBeNil)
+			So(c.Round(), ShouldEqual, 1)
+			for _, ch := range errs {
+				err := <-ch
+				So(errors.Is(err, barrier.ErrBroken), ShouldBeTrue)
+				So(errors.Is(err, cause), ShouldBeTrue)
+			}
+
+			Convey("之后的轮次照常由 ReleaseRound 控制", func() {
+				errs := []<-chan error{goWait(c.Wait), goWait(c.Wait), goWait(c.Wait)}
+				So(c.ReleaseRound(ctx), ShouldBeNil)
+				for _, ch := range errs {
+					So(<-ch, ShouldBeNil)
+				}
+				So(c.Round(), ShouldEqual, 2)
+			})
+		})
+
+		Convey("这一轮没有完成时，ReleaseRound 在 context 结束后返回", func() {
+			goWait(c.Wait)
+			cctx, cancel := context.WithCancel(ctx)
+			cancel()
+			So(c.ReleaseRound(cctx), ShouldEqual, context.Canceled)
+			So(c.WaitUntilArrived(cctx, 3), ShouldEqual, context.Canceled)
+		})
+
+		Convey("Close 会放开被 Controller 拦住的这一轮", func() {
+			errs := []<-chan error{goWait(c.Wait), goWait(c.Wait), goWait(c.Wait)}
+			So(c.WaitUntilArrived(ctx, 3), ShouldBeNil)
+			So(c.Close(), ShouldBeNil)
+			for _, ch := range errs {
+				So(errors.Is(<-ch, barrier.ErrClosed), ShouldBeTrue)
+			}
+			So(c.ReleaseRound(ctx), ShouldEqual, barrier.ErrClosed)
+		})
+
+		Convey("Close 后，等不到参与者的 WaitUntilArrived 返回 ErrClosed", func() {
+			goWait(c.Wait)
+			ch := make(chan error, 1)
+			go func() { ch <- c.WaitUntilArrived(ctx, 3) }()
+			So(c.WaitUntilArrived(ctx, 1), ShouldBeNil)
+			So(c.Close(), ShouldBeNil)
+			So(<-ch, ShouldEqual, barrier.ErrClosed)
+		})
+	})
+}
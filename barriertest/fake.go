@@ -0,0 +1,746 @@
+// Package barriertest provides the utilities for testing the code built on
+// the barriers of package barrier.
+package barriertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aQuaYi/barrier"
+)
+
+// Arrival is an arrival observed by a Fake.
+type Arrival struct {
+	Round  uint64 // number of the completed rounds before it
+	Name   string // name passed to WaitAs or RegisterAs, if any
+	Method string // method of Barrier arriving, like "Wait" or "Break"
+}
+
+// Fake is a barrier.Barrier, whose rounds never trip by themselves.
+// The goroutines arriving a Fake wait until the test trips the round by
+// TripNow, or breaks it by BreakNow, so the code taking a Barrier can be
+// unit tested without the choreography of the goroutines of its parties.
+// The arrivals are recorded for the assertions, like AssertArrivals.
+//
+// Otherwise a Fake behaves like the Barrier created by barrier.New:
+// the context done or the timeout of a waiting goroutine breaks the round,
+// Break breaks it, and Close breaks it with barrier.ErrClosed. But the
+// goroutine arriving more than the parties of the round returns
+// barrier.ErrTooManyParties instead of panic, and the hooks of OnRelease
+// are called by TripNow before it returns.
+type Fake struct {
+	lock        sync.Mutex
+	parties     int
+	cur         *round
+	rounds      uint64
+	broken      bool
+	closed      bool
+	arrivals    []Arrival
+	changed     chan struct{} // closed and replaced by every arrival
+	tokens      map[int]*round
+	nextToken   int
+	actions     []func(context.Context) error
+	onBroken    func(cause error)
+	onRelease   []func(barrier.RoundInfo)
+	observer    barrier.Observer
+	subscribers []chan barrier.RoundEvent // returned by Events
+	stats       barrier.Stats
+}
+
+// round is a round of a Fake.
+type round struct {
+	count  int // parties arrived, weighted by WaitWeighted
+	names  []string
+	values []interface{}
+	action func() error // passed to WaitAction by the last arrival
+	ctx    context.Context
+	err    error
+	done   chan struct{} // closed once the round is released or broken
+}
+
+func newRound() *round {
+	return &round{ctx: context.Background(), done: make(chan struct{})}
+}
+
+var _ barrier.Barrier = (*Fake)(nil)
+
+// New returns a new Fake for parties.
+// It panics if parties is not positive, like barrier.New.
+func New(parties int) *Fake {
+	if parties <= 0 {
+		panic(barrier.ErrNonPositiveParticipants)
+	}
+	return &Fake{
+		parties: parties,
+		cur:     newRound(),
+		changed: make(chan struct{}),
+		tokens:  make(map[int]*round),
+	}
+}
+
+// TripNow completes the current round, even if not all of its parties have
+// arrived. It runs the actions of the round like the last arrived goroutine
+// of a Barrier, and then releases the waiting goroutines.
+// It returns the error returned by the goroutines of the round, which is
+// nil unless an action fails, and barrier.ErrClosed after Close.
+func (f *Fake) TripNow() error {
+	f.lock.Lock()
+	if f.closed {
+		f.lock.Unlock()
+		return barrier.ErrClosed
+	}
+	r := f.cur
+	f.cur = newRound()
+	actions := f.actions
+	f.lock.Unlock()
+
+	var err error
+	if r.action != nil {
+		err = r.action()
+	} else {
+		for _, action := range actions {
+			if err = action(r.ctx); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		f.lock.Lock()
+		f.finish(r, err, "")
+		return r.err
+	}
+
+	f.lock.Lock()
+	f.rounds++
+	f.broken = false
+	f.stats.CompletedRounds++
+	f.stats.PartiesServed += uint64(r.count)
+	f.stats.LastCompletion = time.Now()
+	info := barrier.RoundInfo{Round: f.rounds, Parties: r.count, Released: f.stats.LastCompletion}
+	f.publish(r.count, false)
+	observer, hooks := f.observer, f.onRelease
+	f.lock.Unlock()
+	if observer != nil {
+		observer.OnTrip(info.Round)
+	}
+	close(r.done)
+	for _, hook := range hooks {
+		hook(info)
+	}
+	return nil
+}
+
+// BreakNow breaks the current round with err, nil means barrier.ErrBroken,
+// and starts a new round. The goroutines waiting in the round return
+// a *barrier.BrokenError wrapping err.
+// The Fake is broken until the next round trips, or Reset is called.
+func (f *Fake) BreakNow(err error) {
+	f.lock.Lock()
+	if f.closed {
+		f.lock.Unlock()
+		return
+	}
+	r := f.cur
+	f.cur = newRound()
+	f.finish(r, err, "")
+}
+
+// finish breaks r, which has been replaced by a new round, with err.
+// It should be called with f.lock held, which is released.
+func (f *Fake) finish(r *round, err error, by string) {
+	if err == nil {
+		err = barrier.ErrBroken
+	}
+	cause := err
+	if err != barrier.ErrClosed {
+		err = &barrier.BrokenError{
+			Cause:    err,
+			Round:    f.rounds,
+			Canceled: errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded),
+			By:       by,
+		}
+	}
+	r.err = err
+	f.rounds++
+	f.broken = true
+	f.stats.BrokenRounds++
+	f.stats.PartiesServed += uint64(r.count)
+	f.publish(r.count, true)
+	observer, hook := f.observer, f.onBroken
+	f.lock.Unlock()
+	if hook != nil {
+		hook(cause)
+	}
+	if observer != nil {
+		observer.OnBreak(cause)
+	}
+	close(r.done)
+}
+
+// breakRound breaks r by the goroutine of name, unless r is not the current
+// round any more, because it has tripped or been broken.
+func (f *Fake) breakRound(r *round, err error, name string) {
+	f.lock.Lock()
+	if r != f.cur {
+		f.lock.Unlock()
+		return
+	}
+	f.cur = newRound()
+	f.finish(r, err, name)
+}
+
+// WaitForArrivals waits until n parties have arrived in the current round,
+// so that the test trips the round after the goroutines under test arrive.
+// It returns ctx.Err() if ctx is done before then.
+func (f *Fake) WaitForArrivals(ctx context.Context, n int) error {
+	for {
+		f.lock.Lock()
+		count, changed := f.cur.count, f.changed
+		f.lock.Unlock()
+		if count >= n {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Arrivals returns all the arrivals observed, in arrival order.
+func (f *Fake) Arrivals() []Arrival {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]Arrival(nil), f.arrivals...)
+}
+
+// AssertArrivals reports whether n arrivals are observed in total,
+// and fails t if not.
+func (f *Fake) AssertArrivals(t testing.TB, n int) bool {
+	t.Helper()
+	if got := len(f.Arrivals()); got != n {
+		t.Errorf("barriertest: %d arrivals observed, want %d", got, n)
+		return false
+	}
+	return true
+}
+
+// AssertWaiting reports whether n parties have arrived in the current round,
+// and fails t if not.
+func (f *Fake) AssertWaiting(t testing.TB, n int) bool {
+	t.Helper()
+	if got := f.NumberWaiting(); got != n {
+		t.Errorf("barriertest: %d parties waiting, want %d", got, n)
+		return false
+	}
+	return true
+}
+
+// AssertArrivedAs reports whether the arrivals observed are of names,
+// in arrival order, and fails t if not.
+func (f *Fake) AssertArrivedAs(t testing.TB, names ...string) bool {
+	t.Helper()
+	arrivals := f.Arrivals()
+	got := make([]string, len(arrivals))
+	for i, a := range arrivals {
+		got[i] = a.Name
+	}
+	if !equal(got, names) {
+		t.Errorf("barriertest: arrivals of %q observed, want %q", got, names)
+		return false
+	}
+	return true
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// arrive records the arrival of weight parties in the current round.
+func (f *Fake) arrive(ctx context.Context, method, name string, weight int, v interface{}, action func() error) (r *round, index int, err error) {
+	f.lock.Lock()
+	if f.closed {
+		f.lock.Unlock()
+		return nil, 0, barrier.ErrClosed
+	}
+	r = f.cur
+	if r.count+weight > f.parties {
+		f.lock.Unlock()
+		return nil, 0, barrier.ErrTooManyParties
+	}
+	if name != "" {
+		for _, n := range r.names {
+			if n == name {
+				f.lock.Unlock()
+				return nil, 0, barrier.ErrDuplicateArrival
+			}
+		}
+	}
+	r.count += weight
+	r.names = append(r.names, name)
+	// like barrier.New, every arrival has its slot in arrival order, which
+	// is nil unless it contributes.
+	for len(r.values) < r.count {
+		r.values = append(r.values, nil)
+	}
+	r.values[r.count-1] = v
+	r.action, r.ctx = action, ctx
+	f.arrivals = append(f.arrivals, Arrival{Round: f.rounds, Name: name, Method: method})
+	close(f.changed)
+	f.changed = make(chan struct{})
+	index, observer := r.count, f.observer
+	f.lock.Unlock()
+	if observer != nil {
+		observer.OnArrive(index)
+	}
+	return r, index, nil
+}
+
+// await waits r no more than timeout, if timeout > 0.
+func (f *Fake) await(ctx context.Context, r *round, name string, timeout time.Duration) error {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		f.breakRound(r, ctx.Err(), name)
+	case <-expired:
+		f.breakRound(r, barrier.ErrTimeout, name)
+	}
+	<-r.done
+	return r.err
+}
+
+func (f *Fake) wait(ctx context.Context, method, name string, weight int, v interface{}, action func() error) (*round, int, error) {
+	r, index, err := f.arrive(ctx, method, name, weight, v, action)
+	if err != nil {
+		return nil, 0, err
+	}
+	return r, index, f.await(ctx, r, name, 0)
+}
+
+func (f *Fake) Wait(ctx context.Context) error {
+	_, _, err := f.wait(ctx, "Wait", "", 1, nil, nil)
+	return err
+}
+
+func (f *Fake) WaitAs(ctx context.Context, name string) error {
+	_, _, err := f.wait(ctx, "WaitAs", name, 1, nil, nil)
+	return err
+}
+
+func (f *Fake) WaitExchange(ctx context.Context, mine interface{}) ([]interface{}, error) {
+	r, _, err := f.wait(ctx, "WaitExchange", "", 1, mine, nil)
+	if err != nil {
+		return nil, err
+	}
+	return r.values, nil
+}
+
+func (f *Fake) WaitAction(ctx context.Context, action func() error) error {
+	_, _, err := f.wait(ctx, "WaitAction", "", 1, nil, action)
+	return err
+}
+
+func (f *Fake) WaitIndexed(ctx context.Context) (int, error) {
+	_, index, err := f.wait(ctx, "WaitIndexed", "", 1, nil, nil)
+	return index, err
+}
+
+func (f *Fake) WaitIndex(ctx context.Context) (int, error) {
+	_, index, err := f.wait(ctx, "WaitIndex", "", 1, nil, nil)
+	return f.Parties() - index, err
+}
+
+// WaitN is Wait, the round still waits for TripNow or BreakNow, even if
+// k goroutines are waiting.
+func (f *Fake) WaitN(ctx context.Context, k int) error {
+	if k < 1 || k > f.Parties() {
+		return barrier.ErrQuorumOutOfRange
+	}
+	_, _, err := f.wait(ctx, "WaitN", "", 1, nil, nil)
+	return err
+}
+
+func (f *Fake) WaitWeighted(ctx context.Context, n int) error {
+	if n <= 0 {
+		return barrier.ErrInvalidWeight
+	}
+	_, _, err := f.wait(ctx, "WaitWeighted", "", n, nil, nil)
+	return err
+}
+
+func (f *Fake) WaitTimeout(d time.Duration) error {
+	r, _, err := f.arrive(context.Background(), "WaitTimeout", "", 1, nil, nil)
+	if err != nil {
+		return err
+	}
+	return f.await(context.Background(), r, "", d)
+}
+
+func (f *Fake) Break() {
+	f.BreakCtx(context.Background())
+}
+
+func (f *Fake) BreakCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r, _, err := f.arrive(ctx, "Break", "", 1, nil, nil)
+	if err != nil {
+		return err
+	}
+	f.breakRound(r, nil, "")
+	return nil
+}
+
+func (f *Fake) Arrive() (int, error) {
+	r, _, err := f.arrive(context.Background(), "Arrive", "", 1, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.nextToken++
+	f.tokens[f.nextToken] = r
+	return f.nextToken, nil
+}
+
+func (f *Fake) AwaitRelease(ctx context.Context, token int) error {
+	f.lock.Lock()
+	r, ok := f.tokens[token]
+	delete(f.tokens, token)
+	f.lock.Unlock()
+	if !ok {
+		return barrier.ErrInvalidToken
+	}
+	return f.await(ctx, r, "", 0)
+}
+
+// TryWait never arrives, because the rounds of a Fake only trip by TripNow.
+func (f *Fake) TryWait() (bool, error) {
+	if f.isClosed() {
+		return false, barrier.ErrClosed
+	}
+	return false, nil
+}
+
+func (f *Fake) IsBroken() bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.broken || f.closed
+}
+
+func (f *Fake) SetAction(action func()) barrier.Barrier {
+	if action == nil {
+		return f.SetActionCtx(nil)
+	}
+	return f.SetActionCtx(func(context.Context) error {
+		action()
+		return nil
+	})
+}
+
+func (f *Fake) SetActionE(action func() error) barrier.Barrier {
+	if action == nil {
+		return f.SetActionCtx(nil)
+	}
+	return f.SetActionCtx(func(context.Context) error {
+		return action()
+	})
+}
+
+func (f *Fake) SetActionCtx(action func(context.Context) error) barrier.Barrier {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.actions = nil
+	if action != nil {
+		f.actions = []func(context.Context) error{action}
+	}
+	return f
+}
+
+func (f *Fake) AddAction(action func() error) barrier.Barrier {
+	if action == nil {
+		return f
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.actions = append(f.actions[:len(f.actions):len(f.actions)], func(context.Context) error {
+		return action()
+	})
+	return f
+}
+
+// Reset breaks the current round with barrier.ErrBroken, if any party has
+// arrived in it, and clears the broken state.
+func (f *Fake) Reset() {
+	f.lock.Lock()
+	if f.closed {
+		f.lock.Unlock()
+		return
+	}
+	r := f.cur
+	if r.count == 0 {
+		f.broken = false
+		f.lock.Unlock()
+		return
+	}
+	f.cur = newRound()
+	f.finish(r, nil, "")
+	f.lock.Lock()
+	f.broken = false
+	f.lock.Unlock()
+}
+
+func (f *Fake) NumberWaiting() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.cur.count
+}
+
+func (f *Fake) IsDrained() bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.cur.count == 0 && !f.broken && !f.closed
+}
+
+func (f *Fake) Close() error {
+	f.lock.Lock()
+	if f.closed {
+		f.lock.Unlock()
+		return nil
+	}
+	for _, ch := range f.subscribers {
+		close(ch)
+	}
+	f.subscribers = nil
+	f.closed = true
+	r := f.cur
+	f.cur = newRound()
+	f.finish(r, barrier.ErrClosed, "")
+	return nil
+}
+
+func (f *Fake) isClosed() bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.closed
+}
+
+func (f *Fake) Round() uint64 {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.rounds
+}
+
+func (f *Fake) RoundNumber() uint64 {
+	return f.Round()
+}
+
+func (f *Fake) Stats() barrier.Stats {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.stats
+}
+
+func (f *Fake) String() string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return fmt.Sprintf("Fake{participants:%d waiting:%d broken:%t round:%d}",
+		f.parties, f.cur.count, f.broken, f.rounds)
+}
+
+func (f *Fake) Participants() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.parties
+}
+
+func (f *Fake) Parties() int {
+	return f.Participants()
+}
+
+func (f *Fake) Resize(participants int) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if participants <= 0 {
+		return barrier.ErrNonPositiveParticipants
+	}
+	if f.cur.count > 0 {
+		return barrier.ErrRoundInProgress
+	}
+	f.parties = participants
+	return nil
+}
+
+// SetRoundTimeout does nothing, the rounds of a Fake never time out.
+func (f *Fake) SetRoundTimeout(time.Duration) barrier.Barrier {
+	return f
+}
+
+func (f *Fake) Register() barrier.Participant {
+	return f.RegisterAs("")
+}
+
+func (f *Fake) RegisterAs(name string) barrier.Participant {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.closed {
+		return &participant{f: f, name: name, err: barrier.ErrClosed}
+	}
+	f.parties++
+	return &participant{f: f, name: name}
+}
+
+func (f *Fake) Deregister() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.closed {
+		return barrier.ErrClosed
+	}
+	if f.parties <= 1 {
+		return barrier.ErrNonPositiveParticipants
+	}
+	f.parties--
+	return nil
+}
+
+func (f *Fake) OnBroken(hook func(cause error)) barrier.Barrier {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.onBroken = hook
+	return f
+}
+
+// OnRelease adds a hook, which is called by TripNow after the release.
+func (f *Fake) OnRelease(hook func(barrier.RoundInfo)) barrier.Barrier {
+	if hook == nil {
+		return f
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.onRelease = append(f.onRelease[:len(f.onRelease):len(f.onRelease)], hook)
+	return f
+}
+
+func (f *Fake) SetObserver(o barrier.Observer) barrier.Barrier {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.observer = o
+	return f
+}
+
+func (f *Fake) Events() <-chan barrier.RoundEvent {
+	ch := make(chan barrier.RoundEvent, 16)
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.closed {
+		close(ch)
+		return ch
+	}
+	f.subscribers = append(f.subscribers, ch)
+	return ch
+}
+
+// publish sends the EventTripped of the round completed with parties to
+// the channels returned by Events, without blocking. broken reports
+// whether the round is broken.
+// It should be called with f.lock held.
+func (f *Fake) publish(parties int, broken bool) {
+	e := barrier.RoundEvent{
+		Kind:    barrier.EventTripped,
+		Round:   f.rounds,
+		Broken:  broken,
+		Parties: parties,
+		When:    time.Now(),
+	}
+	for _, ch := range f.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// participant is a party of a Fake registered by Register.
+type participant struct {
+	f            *Fake
+	name         string
+	err          error // ErrClosed if it is registered after Close
+	deregistered bool
+	lock         sync.Mutex
+}
+
+// check returns why p can not be used, if any.
+func (p *participant) check() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	if p.deregistered {
+		return barrier.ErrDeregistered
+	}
+	return nil
+}
+
+func (p *participant) Wait(ctx context.Context) error {
+	if err := p.check(); err != nil {
+		return err
+	}
+	_, _, err := p.f.wait(ctx, "Wait", p.name, 1, nil, nil)
+	return err
+}
+
+func (p *participant) Arrive() error {
+	if err := p.check(); err != nil {
+		return err
+	}
+	_, _, err := p.f.arrive(context.Background(), "Arrive", p.name, 1, nil, nil)
+	return err
+}
+
+func (p *participant) ArriveAndWait(ctx context.Context) error {
+	return p.Wait(ctx)
+}
+
+func (p *participant) ArriveAndDeregister() error {
+	if err := p.Arrive(); err != nil {
+		return err
+	}
+	return p.Deregister()
+}
+
+func (p *participant) Deregister() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	if p.deregistered {
+		return barrier.ErrDeregistered
+	}
+	if err := p.f.Deregister(); err != nil {
+		return err
+	}
+	p.deregistered = true
+	return nil
+}
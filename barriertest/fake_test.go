@@ -0,0 +1,245 @@
+package barriertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aQuaYi/barrier"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// goWait lets a goroutine call wait, and returns the channel of its result.
+func goWait(wait func(ctx context.Context) error) <-chan error {
+	ch := make(chan error, 1)
+	go func() {
+		ch <- wait(context.TODO())
+	}()
+	return ch
+}
+
+// recorder is a testing.TB recording the errors, instead of failing.
+type recorder struct {
+	testing.TB
+	errors int
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors++
+}
+
+func TestFake(t *testing.T) {
+	Convey("假设 Fake 有 3 个参与者", t, func() {
+		f := New(3)
+		ctx := context.TODO()
+
+		Convey("即使所有参与者都到达了，这一轮也要等 TripNow 才会完成", func() {
+			errs := []<-chan error{goWait(f.Wait), goWait(f.Wait), goWait(f.Wait)}
+			So(f.WaitForArrivals(ctx, 3), ShouldBeNil)
+			select {
+			case <-errs[0]:
+				t.Fatal("released before TripNow")
+			case <-time.After(10 * time.Millisecond):
+			}
+			f.AssertWaiting(t, 3)
+
+			So(f.TripNow(), ShouldBeNil)
+			for _, ch := range errs {
+				So(<-ch, ShouldBeNil)
+			}
+			So(f.Round(), ShouldEqual, 1)
+			So(f.NumberWaiting(), ShouldEqual, 0)
+			So(f.Stats().CompletedRounds, ShouldEqual, 1)
+		})
+
+		Convey("BreakNow 让等待的参与者返回包装了原因的 BrokenError", func() {
+			cause := errors.New("shard lost")
+			ch := goWait(f.Wait)
+			So(f.WaitForArrivals(ctx, 1), ShouldBeNil)
+			f.BreakNow(cause)
+			err := <-ch
+			So(errors.Is(err, barrier.ErrBroken), ShouldBeTrue)
+			So(errors.Is(err, cause), ShouldBeTrue)
+			So(f.IsBroken(), ShouldBeTrue)
+			So(f.Stats().BrokenRounds, ShouldEqual, 1)
+
+			Convey("下一轮完成后就不再是 broken", func() {
+				So(f.TripNow(), ShouldBeNil)
+				So(f.IsBroken(), ShouldBeFalse)
+				So(f.Round(), ShouldEqual, 2)
+			})
+		})
+
+		Convey("TripNow 会运行 action，失败的 action 会 break 这一轮", func() {
+			want := errors.New("action failed")
+			f.SetActionE(func() error { return want })
+			ch := goWait(f.Wait)
+			So(f.WaitForArrivals(ctx, 1), ShouldBeNil)
+			err := f.TripNow()
+			So(errors.Is(err, want), ShouldBeTrue)
+			So(<-ch, ShouldEqual, err)
+			So(f.IsBroken(), ShouldBeTrue)
+		})
+
+		Convey("WaitAction 的 action 代替 Fake 的 action", func() {
+			var ran []string
+			f.AddAction(func() error {
+				ran = append(ran, "barrier")
+				return nil
+			})
+			ch := goWait(func(ctx context.Context) error {
+				return f.WaitAction(ctx, func() error {
+					ran = append(ran, "round")
+					return nil
+				})
+			})
+			So(f.WaitForArrivals(ctx, 1), ShouldBeNil)
+			So(f.TripNow(), ShouldBeNil)
+			So(<-ch, ShouldBeNil)
+			So(ran, ShouldResemble, []string{"round"})
+		})
+
+		Convey("记录所有的到达，以便断言", func() {
+			goWait(func(ctx context.Context) error { return f.WaitAs(ctx, "reader") })
+			So(f.WaitForArrivals(ctx, 1), ShouldBeNil)
+			goWait(func(ctx context.Context) error { return f.WaitAs(ctx, "writer") })
+			So(f.WaitForArrivals(ctx, 2), ShouldBeNil)
+			So(f.WaitAs(ctx, "reader"), ShouldEqual, barrier.ErrDuplicateArrival)
+			So(f.TripNow(), ShouldBeNil)
+			_, err := f.Arrive()
+			So(err, ShouldBeNil)
+			So(f.AssertArrivals(t, 3), ShouldBeTrue)
+			So(f.AssertArrivedAs(t, "reader", "writer", ""), ShouldBeTrue)
+			So(f.Arrivals(), ShouldResemble, []Arrival{
+				{Round: 0, Name: "reader", Method: "WaitAs"},
+				{Round: 0, Name: "writer", Method: "WaitAs"},
+				{Round: 1, Method: "Arrive"},
+			})
+		})
+
+		Convey("断言失败时会让测试失败", func() {
+			mock := &recorder{TB: t}
+			So(f.AssertArrivals(mock, 1), ShouldBeFalse)
+			So(f.AssertWaiting(mock, 1), ShouldBeFalse)
+			So(f.AssertArrivedAs(mock, "reader"), ShouldBeFalse)
+			So(mock.errors, ShouldEqual, 3)
+		})
+
+		Convey("context 结束的参与者会 break 这一轮", func() {
+			ch := goWait(f.Wait)
+			So(f.WaitForArrivals(ctx, 1), ShouldBeNil)
+			cctx, cancel := context.WithCancel(ctx)
+			cancel()
+			err := f.Wait(cctx)
+			So(errors.Is(err, context.Canceled), ShouldBeTrue)
+			var be *barrier.BrokenError
+			So(errors.As(<-ch, &be), ShouldBeTrue)
+			So(be.Canceled, ShouldBeTrue)
+		})
+
+		Convey("WaitTimeout 超时后会 break 这一轮", func() {
+			err := f.WaitTimeout(time.Millisecond)
+			So(errors.Is(err, barrier.ErrTimeout), ShouldBeTrue)
+		})
+
+		Convey("WaitExchange 在 TripNow 后返回所有的贡献", func() {
+			ch := make(chan []interface{}, 1)
+			go func() {
+				values, _ := f.WaitExchange(ctx, 1)
+				ch <- values
+			}()
+			So(f.WaitForArrivals(ctx, 1), ShouldBeNil)
+			go f.WaitExchange(ctx, 2)
+			So(f.WaitForArrivals(ctx, 2), ShouldBeNil)
+			So(f.TripNow(), ShouldBeNil)
+			So(<-ch, ShouldResemble, []interface{}{1, 2})
+		})
+
+		Convey("WaitExchange 贡献 nil 的参与者也占一个位置", func() {
+			ch := make(chan []interface{}, 1)
+			go func() {
+				values, _ := f.WaitExchange(ctx, nil)
+				ch <- values
+			}()
+			So(f.WaitForArrivals(ctx, 1), ShouldBeNil)
+			go f.WaitExchange(ctx, 2)
+			So(f.WaitForArrivals(ctx, 2), ShouldBeNil)
+			So(f.TripNow(), ShouldBeNil)
+			So(<-ch, ShouldResemble, []interface{}{nil, 2})
+		})
+
+		Convey("Arrive 和 AwaitRelease 要配对使用", func() {
+			token, err := f.Arrive()
+			So(err, ShouldBeNil)
+			ch := goWait(func(ctx context.Context) error { return f.AwaitRelease(ctx, token) })
+			So(f.TripNow(), ShouldBeNil)
+			So(<-ch, ShouldBeNil)
+			So(f.AwaitRelease(ctx, token), ShouldEqual, barrier.ErrInvalidToken)
+		})
+
+		Convey("超过参与者数量的到达会返回 ErrTooManyParties", func() {
+			So(f.WaitWeighted(ctx, 4), ShouldEqual, barrier.ErrTooManyParties)
+			So(f.WaitWeighted(ctx, 0), ShouldEqual, barrier.ErrInvalidWeight)
+			So(f.WaitN(ctx, 4), ShouldEqual, barrier.ErrQuorumOutOfRange)
+		})
+
+		Convey("Close 之后的到达都返回 ErrClosed", func() {
+			ch := goWait(f.Wait)
+			So(f.WaitForArrivals(ctx, 1), ShouldBeNil)
+			So(f.Close(), ShouldBeNil)
+			So(<-ch, ShouldEqual, barrier.ErrClosed)
+			So(f.Wait(ctx), ShouldEqual, barrier.ErrClosed)
+			So(f.TripNow(), ShouldEqual, barrier.ErrClosed)
+			So(f.IsBroken(), ShouldBeTrue)
+		})
+
+		Convey("Reset 会 break 有参与者的这一轮", func() {
+			ch := goWait(f.Wait)
+			So(f.WaitForArrivals(ctx, 1), ShouldBeNil)
+			f.Reset()
+			So(errors.Is(<-ch, barrier.ErrBroken), ShouldBeTrue)
+			So(f.IsBroken(), ShouldBeFalse)
+			So(f.IsDrained(), ShouldBeTrue)
+		})
+
+		Convey("Events 会收到 TripNow 和 BreakNow 的事件", func() {
+			events := f.Events()
+			So(f.TripNow(), ShouldBeNil)
+			f.BreakNow(nil)
+			e := <-events
+			So(e.Round, ShouldEqual, 1)
+			So(e.Broken, ShouldBeFalse)
+			e = <-events
+			So(e.Round, ShouldEqual, 2)
+			So(e.Broken, ShouldBeTrue)
+			f.Close()
+			_, ok := <-events
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("Register 的参与者用它的名字到达", func() {
+			p := f.RegisterAs("loader")
+			So(f.Participants(), ShouldEqual, 4)
+			ch := goWait(p.Wait)
+			So(f.WaitForArrivals(ctx, 1), ShouldBeNil)
+			So(f.TripNow(), ShouldBeNil)
+			So(<-ch, ShouldBeNil)
+			So(f.AssertArrivedAs(t, "loader"), ShouldBeTrue)
+			So(p.Deregister(), ShouldBeNil)
+			So(p.Wait(ctx), ShouldEqual, barrier.ErrDeregistered)
+			So(f.Participants(), ShouldEqual, 3)
+		})
+
+		Convey("Close 以后 Register 的参与者，所有的调用都返回 ErrClosed", func() {
+			f.Close()
+			p := f.Register()
+			So(f.Participants(), ShouldEqual, 3)
+			So(p.Wait(ctx), ShouldEqual, barrier.ErrClosed)
+			So(p.Arrive(), ShouldEqual, barrier.ErrClosed)
+			So(p.Deregister(), ShouldEqual, barrier.ErrClosed)
+		})
+	})
+}
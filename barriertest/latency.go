@@ -0,0 +1,165 @@
+package barriertest
+
+import (
+	"context"
+	"time"
+
+	"github.com/aQuaYi/barrier"
+)
+
+// Delays are the artificial delays of a participant injected by Latency.
+type Delays struct {
+	BeforeArrival time.Duration // before the participant arrives the round
+	AfterRelease  time.Duration // after the round is released or broken
+}
+
+// Latency returns a Barrier, which waits b, but delays every participant by
+// the delays of its name, which is passed to WaitAs or RegisterAs, and is
+// empty for the other Wait methods. It simulates the slow participants, to
+// test how the stragglers are handled under a controlled skew.
+//
+// If the context of the participant is done before it arrives, it returns
+// ctx.Err() without arriving, like a straggler never arriving.
+// The chaining methods, like SetAction and OnBroken, return the Barrier
+// of Latency, and the other methods of b are called as they are.
+func Latency(b barrier.Barrier, delays func(name string) Delays) barrier.Barrier {
+	return &latency{Barrier: b, delays: delays}
+}
+
+type latency struct {
+	barrier.Barrier
+	delays func(name string) Delays
+}
+
+// around calls wait between the delays of name.
+func (l *latency) around(ctx context.Context, name string, wait func(ctx context.Context) error) error {
+	d := l.delays(name)
+	if err := sleep(ctx, d.BeforeArrival); err != nil {
+		return err
+	}
+	err := wait(ctx)
+	time.Sleep(d.AfterRelease)
+	return err
+}
+
+func (l *latency) Wait(ctx context.Context) error {
+	return l.around(ctx, "", l.Barrier.Wait)
+}
+
+func (l *latency) WaitAs(ctx context.Context, name string) error {
+	return l.around(ctx, name, func(ctx context.Context) error {
+		return l.Barrier.WaitAs(ctx, name)
+	})
+}
+
+func (l *latency) WaitExchange(ctx context.Context, mine interface{}) (values []interface{}, err error) {
+	err = l.around(ctx, "", func(ctx context.Context) (err error) {
+		values, err = l.Barrier.WaitExchange(ctx, mine)
+		return
+	})
+	return
+}
+
+func (l *latency) WaitAction(ctx context.Context, action func() error) error {
+	return l.around(ctx, "", func(ctx context.Context) error {
+		return l.Barrier.WaitAction(ctx, action)
+	})
+}
+
+func (l *latency) WaitIndexed(ctx context.Context) (index int, err error) {
+	err = l.around(ctx, "", func(ctx context.Context) (err error) {
+		index, err = l.Barrier.WaitIndexed(ctx)
+		return
+	})
+	return
+}
+
+func (l *latency) WaitIndex(ctx context.Context) (index int, err error) {
+	err = l.around(ctx, "", func(ctx context.Context) (err error) {
+		index, err = l.Barrier.WaitIndex(ctx)
+		return
+	})
+	return
+}
+
+func (l *latency) WaitN(ctx context.Context, k int) error {
+	return l.around(ctx, "", func(ctx context.Context) error {
+		return l.Barrier.WaitN(ctx, k)
+	})
+}
+
+func (l *latency) WaitWeighted(ctx context.Context, n int) error {
+	return l.around(ctx, "", func(ctx context.Context) error {
+		return l.Barrier.WaitWeighted(ctx, n)
+	})
+}
+
+func (l *latency) WaitTimeout(d time.Duration) error {
+	return l.around(context.Background(), "", func(context.Context) error {
+		return l.Barrier.WaitTimeout(d)
+	})
+}
+
+func (l *latency) SetAction(action func()) barrier.Barrier {
+	l.Barrier.SetAction(action)
+	return l
+}
+
+func (l *latency) SetActionE(action func() error) barrier.Barrier {
+	l.Barrier.SetActionE(action)
+	return l
+}
+
+func (l *latency) SetActionCtx(action func(context.Context) error) barrier.Barrier {
+	l.Barrier.SetActionCtx(action)
+	return l
+}
+
+func (l *latency) AddAction(action func() error) barrier.Barrier {
+	l.Barrier.AddAction(action)
+	return l
+}
+
+func (l *latency) SetRoundTimeout(d time.Duration) barrier.Barrier {
+	l.Barrier.SetRoundTimeout(d)
+	return l
+}
+
+func (l *latency) OnBroken(hook func(cause error)) barrier.Barrier {
+	l.Barrier.OnBroken(hook)
+	return l
+}
+
+func (l *latency) OnRelease(hook func(barrier.RoundInfo)) barrier.Barrier {
+	l.Barrier.OnRelease(hook)
+	return l
+}
+
+func (l *latency) SetObserver(o barrier.Observer) barrier.Barrier {
+	l.Barrier.SetObserver(o)
+	return l
+}
+
+func (l *latency) Register() barrier.Participant {
+	return l.RegisterAs("")
+}
+
+func (l *latency) RegisterAs(name string) barrier.Participant {
+	return &slowParticipant{Participant: l.Barrier.RegisterAs(name), l: l, name: name}
+}
+
+// slowParticipant is a participant registered to Latency, whose Wait is
+// delayed like WaitAs.
+type slowParticipant struct {
+	barrier.Participant
+	l    *latency
+	name string
+}
+
+func (p *slowParticipant) Wait(ctx context.Context) error {
+	return p.l.around(ctx, p.name, p.Participant.Wait)
+}
+
+func (p *slowParticipant) ArriveAndWait(ctx context.Context) error {
+	return p.Wait(ctx)
+}
@@ -0,0 +1,78 @@
+package barriertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aQuaYi/barrier"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLatency(t *testing.T) {
+	ctx := context.TODO()
+	delays := map[string]Delays{
+		"straggler": {BeforeArrival: 50 * time.Millisecond},
+		"slowpoke":  {AfterRelease: 50 * time.Millisecond},
+	}
+
+	Convey("假设 Barrier 的参与者按名字延迟", t, func() {
+		b := barrier.New(2)
+		l := Latency(b, func(name string) Delays { return delays[name] })
+
+		Convey("到达前的延迟让参与者成为最后到达的", func() {
+			start := time.Now()
+			ch := goWait(func(ctx context.Context) error { return l.WaitAs(ctx, "straggler") })
+			index, err := l.WaitIndexed(ctx)
+			So(err, ShouldBeNil)
+			So(index, ShouldEqual, 1)
+			So(<-ch, ShouldBeNil)
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 50*time.Millisecond)
+		})
+
+		Convey("释放后的延迟只影响这个参与者的返回", func() {
+			done := make(chan time.Time, 1)
+			go func() {
+				l.WaitAs(ctx, "slowpoke")
+				done <- time.Now()
+			}()
+			So(l.Wait(ctx), ShouldBeNil)
+			released := time.Now()
+			So((<-done).Sub(released), ShouldBeGreaterThan, 25*time.Millisecond)
+		})
+
+		Convey("延迟的参与者会让设置了超时的其他参与者超时", func() {
+			go l.WaitAs(ctx, "straggler")
+			err := l.WaitTimeout(10 * time.Millisecond)
+			So(errors.Is(err, barrier.ErrTimeout), ShouldBeTrue)
+		})
+
+		Convey("到达前 context 就结束的参与者不会到达", func() {
+			cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+			defer cancel()
+			So(errors.Is(l.WaitAs(cctx, "straggler"), context.DeadlineExceeded), ShouldBeTrue)
+			So(b.NumberWaiting(), ShouldEqual, 0)
+		})
+
+		Convey("链式调用以后，延迟还在", func() {
+			chained := l.SetAction(func() {}).OnRelease(func(barrier.RoundInfo) {})
+			So(chained, ShouldEqual, l)
+			start := time.Now()
+			ch := goWait(func(ctx context.Context) error { return chained.WaitAs(ctx, "straggler") })
+			So(chained.Wait(ctx), ShouldBeNil)
+			So(<-ch, ShouldBeNil)
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 50*time.Millisecond)
+		})
+
+		Convey("Register 的参与者也按名字延迟", func() {
+			p := l.RegisterAs("straggler")
+			start := time.Now()
+			ch, other := goWait(p.Wait), goWait(l.Wait)
+			So(l.Wait(ctx), ShouldBeNil)
+			So(<-ch, ShouldBeNil)
+			So(<-other, ShouldBeNil)
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 50*time.Millisecond)
+		})
+	})
+}
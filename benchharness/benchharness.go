@@ -0,0 +1,180 @@
+// Package benchharness benchmarks the implementations of barriers across
+// a matrix of party counts and rounds, and reports the results in JSON,
+// so that the performance of the wait strategies can be compared.
+package benchharness
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/aQuaYi/barrier"
+	"github.com/marusama/cyclicbarrier"
+)
+
+// Subject is an implementation of barriers under benchmark.
+type Subject struct {
+	Name string
+	// New returns the wait function of a new barrier for parties.
+	New func(parties int) func(ctx context.Context) error
+}
+
+// Barrier returns the Subject of barrier.New with opts.
+func Barrier(name string, opts ...barrier.Option) Subject {
+	return Subject{
+		Name: name,
+		New: func(parties int) func(ctx context.Context) error {
+			return barrier.New(parties, opts...).Wait
+		},
+	}
+}
+
+// CyclicBarrier returns the Subject of marusama/cyclicbarrier.
+func CyclicBarrier() Subject {
+	return Subject{
+		Name: "cyclicbarrier",
+		New: func(parties int) func(ctx context.Context) error {
+			return cyclicbarrier.New(parties).Await
+		},
+	}
+}
+
+// WaitGroup returns the Subject of a barrier, which is a new sync.WaitGroup
+// every round.
+func WaitGroup() Subject {
+	return Subject{
+		Name: "sync.WaitGroup",
+		New: func(parties int) func(ctx context.Context) error {
+			w := &waitGroup{parties: parties, wg: new(sync.WaitGroup)}
+			w.wg.Add(parties)
+			return w.wait
+		},
+	}
+}
+
+// waitGroup is a barrier, whose parties wait a sync.WaitGroup of the round.
+type waitGroup struct {
+	lock    sync.Mutex
+	wg      *sync.WaitGroup
+	arrived int
+	parties int
+}
+
+func (w *waitGroup) wait(context.Context) error {
+	w.lock.Lock()
+	wg := w.wg
+	w.arrived++
+	if w.arrived == w.parties {
+		// the later arrivals wait the next round.
+		w.arrived = 0
+		w.wg = new(sync.WaitGroup)
+		w.wg.Add(w.parties)
+	}
+	w.lock.Unlock()
+	wg.Done()
+	wg.Wait()
+	return nil
+}
+
+// Config is the matrix of a benchmark.
+type Config struct {
+	Subjects []Subject
+	Parties  []int // numbers of the parties of a barrier
+	Cycles   []int // numbers of the rounds the parties wait
+}
+
+// Result is the benchmark of a Subject, whose parties wait Cycles rounds.
+type Result struct {
+	Subject        string  `json:"subject"`
+	Parties        int     `json:"parties"`
+	Cycles         int     `json:"cycles"`
+	Elapsed        int64   `json:"elapsed_ns"`
+	NsPerRound     float64 `json:"ns_per_round"`
+	AllocsPerRound float64 `json:"allocs_per_round"`
+	BytesPerRound  float64 `json:"bytes_per_round"`
+	ErrorsPerRound float64 `json:"errors_per_round"`
+}
+
+// Report is the results of a benchmark, and where they are measured.
+type Report struct {
+	GoVersion  string   `json:"go_version"`
+	GOOS       string   `json:"goos"`
+	GOARCH     string   `json:"goarch"`
+	NumCPU     int      `json:"num_cpu"`
+	GOMAXPROCS int      `json:"gomaxprocs"`
+	Results    []Result `json:"results"`
+}
+
+// Run benchmarks every Subject of cfg, with every number of the parties
+// and the cycles, one after another.
+func Run(cfg Config) Report {
+	report := Report{
+		GoVersion:  runtime.Version(),
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		NumCPU:     runtime.NumCPU(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+	}
+	for _, s := range cfg.Subjects {
+		for _, parties := range cfg.Parties {
+			for _, cycles := range cfg.Cycles {
+				report.Results = append(report.Results, measure(s, parties, cycles))
+			}
+		}
+	}
+	return report
+}
+
+// measure lets parties goroutines wait cycles rounds of a new barrier of s.
+// The allocations include the ones of starting the goroutines.
+func measure(s Subject, parties, cycles int) Result {
+	wait := s.New(parties)
+	var (
+		wg            sync.WaitGroup
+		lock          sync.Mutex
+		errs          int
+		before, after runtime.MemStats
+	)
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	wg.Add(parties)
+	for i := 0; i < parties; i++ {
+		go func() {
+			defer wg.Done()
+			failed := 0
+			for r := 0; r < cycles; r++ {
+				if wait(context.Background()) != nil {
+					failed++
+				}
+			}
+			lock.Lock()
+			errs += failed
+			lock.Unlock()
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	n := float64(cycles)
+	return Result{
+		Subject:        s.Name,
+		Parties:        parties,
+		Cycles:         cycles,
+		Elapsed:        elapsed.Nanoseconds(),
+		NsPerRound:     float64(elapsed.Nanoseconds()) / n,
+		AllocsPerRound: float64(after.Mallocs-before.Mallocs) / n,
+		BytesPerRound:  float64(after.TotalAlloc-before.TotalAlloc) / n,
+		ErrorsPerRound: float64(errs) / n,
+	}
+}
+
+// WriteJSON writes the report to w in indented JSON.
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,87 @@
+package benchharness
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aQuaYi/barrier"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRun(t *testing.T) {
+	Convey("假设比较 barrier, cyclicbarrier 和 sync.WaitGroup", t, func() {
+		cfg := Config{
+			Subjects: []Subject{
+				Barrier("barrier"),
+				Barrier("barrier/cond", barrier.WithWaitStrategy(barrier.CondBased)),
+				CyclicBarrier(),
+				WaitGroup(),
+			},
+			Parties: []int{1, 4},
+			Cycles:  []int{10, 100},
+		}
+
+		Convey("每个实现的每组 parties 和 cycles 都有结果", func() {
+			report := Run(cfg)
+			So(report.GoVersion, ShouldNotBeEmpty)
+			So(report.NumCPU, ShouldBeGreaterThan, 0)
+			So(report.GOMAXPROCS, ShouldBeGreaterThan, 0)
+			So(report.Results, ShouldHaveLength, 4*2*2)
+			i := 0
+			for _, s := range cfg.Subjects {
+				for _, parties := range cfg.Parties {
+					for _, cycles := range cfg.Cycles {
+						r := report.Results[i]
+						So(r.Subject, ShouldEqual, s.Name)
+						So(r.Parties, ShouldEqual, parties)
+						So(r.Cycles, ShouldEqual, cycles)
+						So(r.Elapsed, ShouldBeGreaterThan, 0)
+						So(r.NsPerRound, ShouldBeGreaterThan, 0)
+						So(r.ErrorsPerRound, ShouldEqual, 0)
+						i++
+					}
+				}
+			}
+		})
+
+		Convey("报告可以写成 JSON", func() {
+			cfg.Parties, cfg.Cycles = []int{2}, []int{10}
+			var buf bytes.Buffer
+			So(Run(cfg).WriteJSON(&buf), ShouldBeNil)
+			var got map[string]interface{}
+			So(json.Unmarshal(buf.Bytes(), &got), ShouldBeNil)
+			So(got, ShouldContainKey, "go_version")
+			results := got["results"].([]interface{})
+			So(results, ShouldHaveLength, 4)
+			first := results[0].(map[string]interface{})
+			So(first["subject"], ShouldEqual, "barrier")
+			So(first["parties"], ShouldEqual, 2)
+			So(first["cycles"], ShouldEqual, 10)
+			So(first, ShouldContainKey, "ns_per_round")
+			So(first, ShouldContainKey, "allocs_per_round")
+		})
+	})
+}
+
+func TestWaitGroup(t *testing.T) {
+	Convey("sync.WaitGroup 的参与者要等到一轮的所有参与者都到达", t, func() {
+		wait := WaitGroup().New(3)
+		done := make(chan struct{})
+		for i := 0; i < 2; i++ {
+			go func() {
+				wait(context.TODO())
+				done <- struct{}{}
+			}()
+		}
+		select {
+		case <-done:
+			t.Fatal("released before all the parties arrived")
+		default:
+		}
+		So(wait(context.TODO()), ShouldBeNil)
+		<-done
+		<-done
+	})
+}
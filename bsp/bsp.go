@@ -0,0 +1,73 @@
+// Package bsp runs bulk synchronous parallel computations, the workers of
+// which execute supersteps in lock-step, with a barrier between them.
+package bsp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aQuaYi/barrier"
+)
+
+// ErrHalt is returned by step to vote to halt.
+// The computation stops after a superstep, in which all the workers
+// have voted to halt.
+var ErrHalt = errors.New("bsp halt")
+
+// Run launches workers goroutines, each of them calls step for the
+// superstep 0, 1, 2 and so on, and waits all the others to finish the
+// superstep before the next one.
+// It returns nil once all the workers vote to halt in a superstep.
+// If step returns an error other than ErrHalt, the worker breaks the
+// round, and Run returns the first of such errors, after the other
+// workers are canceled by the context passed to step.
+func Run(ctx context.Context, workers int, step func(ctx context.Context, superstep, worker int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		votes  int32
+		halted bool // set by the action, read after the release
+		once   sync.Once
+		res    error
+		wg     sync.WaitGroup
+	)
+	b := barrier.New(workers, barrier.WithAction(func() error {
+		halted = atomic.SwapInt32(&votes, 0) == int32(workers)
+		return nil
+	}))
+	breaker := b.(barrier.CauseBreaker)
+	fail := func(err error) {
+		once.Do(func() {
+			res = err
+			cancel()
+		})
+	}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func(worker int) {
+			defer wg.Done()
+			for superstep := 0; ; superstep++ {
+				err := step(ctx, superstep, worker)
+				switch {
+				case errors.Is(err, ErrHalt):
+					atomic.AddInt32(&votes, 1)
+				case err != nil:
+					fail(err)
+					breaker.BreakWith(err)
+					return
+				}
+				if err := b.Wait(ctx); err != nil {
+					fail(err)
+					return
+				}
+				if halted {
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	return res
+}
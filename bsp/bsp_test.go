@@ -0,0 +1,62 @@
+package bsp
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRun(t *testing.T) {
+	Convey("假设有 4 个 worker", t, func() {
+		workers := 4
+
+		Convey("每个 superstep 都在所有 worker 完成上一个 superstep 后开始", func() {
+			steps := make([]int32, workers)
+			var behind int32
+			err := Run(context.TODO(), workers, func(ctx context.Context, superstep, worker int) error {
+				for i := range steps {
+					if int(atomic.LoadInt32(&steps[i])) < superstep {
+						atomic.AddInt32(&behind, 1)
+					}
+				}
+				atomic.AddInt32(&steps[worker], 1)
+				if superstep == 9 {
+					return ErrHalt
+				}
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(atomic.LoadInt32(&behind), ShouldEqual, 0)
+			for i := range steps {
+				So(steps[i], ShouldEqual, 10)
+			}
+		})
+
+		Convey("只有所有 worker 都投票停止时，计算才会停止", func() {
+			var calls int32
+			err := Run(context.TODO(), workers, func(ctx context.Context, superstep, worker int) error {
+				atomic.AddInt32(&calls, 1)
+				if worker == 0 && superstep < 3 {
+					return nil
+				}
+				return ErrHalt
+			})
+			So(err, ShouldBeNil)
+			So(atomic.LoadInt32(&calls), ShouldEqual, 4*workers)
+		})
+
+		Convey("step 返回错误时，Run 返回这个错误，其他 worker 被取消", func() {
+			errStep := errors.New("step failed")
+			err := Run(context.TODO(), workers, func(ctx context.Context, superstep, worker int) error {
+				if superstep == 2 && worker == 1 {
+					return errStep
+				}
+				return nil
+			})
+			So(errors.Is(err, errStep), ShouldBeTrue)
+		})
+	})
+}
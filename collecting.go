@@ -0,0 +1,36 @@
+package barrier
+
+import "context"
+
+// CollectingBarrier is a Barrier, which collects a value from every
+// participant, and reduces them once all participants have arrived.
+// Participants calling Wait or Break of the embedded Barrier contribute
+// the zero value of T.
+type CollectingBarrier[T any] struct {
+	Barrier
+	b *barrier
+}
+
+// NewCollecting initializes a new instance of the CollectingBarrier.
+// reduce is called by the last arrived goroutine with the values of the round
+// in arrival order, before the action and before releasing the others.
+// If reduce returns an error, the round is broken with it like SetActionE.
+func NewCollecting[T any](participants int, reduce func([]T) error, opts ...Option) *CollectingBarrier[T] {
+	opts = append(opts, func(b *barrier) {
+		b.collector = func(values []interface{}) (interface{}, error) {
+			// values belongs to the round, a new round has a new one.
+			return nil, reduce(unbox[T](values))
+		}
+	})
+	b := New(participants, opts...).(*barrier)
+	return &CollectingBarrier[T]{
+		Barrier: b,
+		b:       b,
+	}
+}
+
+// Submit contributes v to the round, and waits like Wait.
+func (c *CollectingBarrier[T]) Submit(ctx context.Context, v T) error {
+	_, _, err := c.b.wait(ctx, box[T]{v}, 0, 0)
+	return err
+}
@@ -0,0 +1,75 @@
+package barrier
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCollectingBarrier(t *testing.T) {
+	Convey("假设 CollectingBarrier 有 3 个参与者", t, func() {
+		participants := 3
+		var collected [][]int
+		errReduce := errors.New("reduce failed")
+		c := NewCollecting(participants, func(vs []int) error {
+			collected = append(collected, vs)
+			if vs[0] < 0 {
+				return errReduce
+			}
+			return nil
+		})
+
+		submitInOrder := func(base int) []error {
+			errs := make(chan error, participants-1)
+			for i := 1; i < participants; i++ {
+				go func(v int) {
+					errs <- c.Submit(context.TODO(), v)
+				}(base * i)
+				for c.NumberWaiting() < i {
+					runtime.Gosched()
+				}
+			}
+			res := []error{c.Submit(context.TODO(), base*participants)}
+			for i := 1; i < participants; i++ {
+				res = append(res, <-errs)
+			}
+			return res
+		}
+
+		Convey("reduce 会按照到达的顺序收到所有的值", func() {
+			for _, err := range submitInOrder(1) {
+				So(err, ShouldBeNil)
+			}
+			So(collected, ShouldResemble, [][]int{{1, 2, 3}})
+
+			Convey("下一轮会收到新的值", func() {
+				for _, err := range submitInOrder(10) {
+					So(err, ShouldBeNil)
+				}
+				So(collected, ShouldResemble, [][]int{{1, 2, 3}, {10, 20, 30}})
+			})
+		})
+
+		Convey("reduce 返回 error 的时候，所有参与者都会返回这个 error", func() {
+			for _, err := range submitInOrder(-1) {
+				So(err, shouldBeBrokenBy, errReduce)
+			}
+		})
+
+		Convey("用 Wait 参与的，贡献的是零值", func() {
+			goWait(c)
+			for c.NumberWaiting() < 1 {
+				runtime.Gosched()
+			}
+			go c.Submit(context.TODO(), 2)
+			for c.NumberWaiting() < 2 {
+				runtime.Gosched()
+			}
+			So(c.Submit(context.TODO(), 3), ShouldBeNil)
+			So(collected, ShouldResemble, [][]int{{0, 2, 3}})
+		})
+	})
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package barrier
+
+import "time"
+
+// cpuTime returns 0, the CPU time of the process is not measured here.
+func cpuTime() time.Duration {
+	return 0
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package barrier
+
+import (
+	"syscall"
+	"time"
+)
+
+// cpuTime returns the CPU time consumed by the process, user and system.
+func cpuTime() time.Duration {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
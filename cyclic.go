@@ -0,0 +1,33 @@
+package barrier
+
+import (
+	"context"
+
+	"github.com/marusama/cyclicbarrier"
+)
+
+var _ cyclicbarrier.CyclicBarrier = cyclic{}
+
+// cyclic adapts Barrier to cyclicbarrier.CyclicBarrier
+type cyclic struct {
+	Barrier
+}
+
+// AsCyclicBarrier returns b as a cyclicbarrier.CyclicBarrier,
+// so that it can replace the one of github.com/marusama/cyclicbarrier.
+// Await is Wait, GetNumberWaiting is NumberWaiting and GetParties is Participants.
+func AsCyclicBarrier(b Barrier) cyclicbarrier.CyclicBarrier {
+	return cyclic{Barrier: b}
+}
+
+func (c cyclic) Await(ctx context.Context) error {
+	return c.Wait(ctx)
+}
+
+func (c cyclic) GetNumberWaiting() int {
+	return c.NumberWaiting()
+}
+
+func (c cyclic) GetParties() int {
+	return c.Participants()
+}
@@ -0,0 +1,43 @@
+package barrier
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAsCyclicBarrier(t *testing.T) {
+	Convey("把有 2 个参与者的 Barrier 当作 cyclicbarrier.CyclicBarrier 使用", t, func() {
+		b := New(2)
+		cb := AsCyclicBarrier(b)
+		So(cb.GetParties(), ShouldEqual, 2)
+
+		Convey("2 个参与者 Await 以后，这一轮完成", func() {
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- cb.Await(context.TODO())
+			}()
+			for cb.GetNumberWaiting() < 1 {
+				runtime.Gosched()
+			}
+			So(cb.Await(context.TODO()), ShouldBeNil)
+			So(<-errCh, ShouldBeNil)
+			So(b.Round(), ShouldEqual, 1)
+		})
+
+		Convey("Reset 以后，等待中的参与者返回 ErrBroken", func() {
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- cb.Await(context.TODO())
+			}()
+			for cb.GetNumberWaiting() < 1 {
+				runtime.Gosched()
+			}
+			cb.Reset()
+			So(<-errCh, shouldBeBrokenBy, ErrBroken)
+			So(cb.IsBroken(), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,62 @@
+package barrier
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// WithDebug records the stacks of the goroutines calling Wait, and when they
+// arrive, for DebugDump. It is expensive, do not use it in production.
+func WithDebug() Option {
+	return func(b *barrier) {
+		b.debug = true
+	}
+}
+
+// DebugDumper is implemented by the Barrier returned by New, which dumps
+// its waiting goroutines. Check it by a type assertion, like FanoutReporter.
+type DebugDumper interface {
+	// DebugDump writes the state of the current round to w, with the waiting
+	// goroutines and how long they have waited. The waiters are listed with
+	// WithDebug or WithWatchdog, and their stacks at the time of Wait with WithDebug.
+	DebugDump(w io.Writer)
+}
+
+func (b *barrier) DebugDump(w io.Writer) {
+	// the write lock keeps the arrivals from writing r.arrivals and r.stacks.
+	b.lockArrivals()
+	r := b.round
+	arrived := r.count()
+	if arrived > r.parties {
+		arrived = r.parties
+	}
+	var arrivals []time.Time
+	var stacks [][]byte
+	var names []string
+	if r.arrivals != nil {
+		arrivals = append(arrivals, r.arrivals[:arrived]...)
+	}
+	if r.stacks != nil {
+		stacks = append(stacks, r.stacks[:arrived]...)
+	}
+	if r.names != nil {
+		names = append(names, r.names[:arrived]...)
+	}
+	round, parties, broken := b.rounds, r.parties, r.isBroken
+	b.unlockArrivals()
+
+	now := time.Now()
+	fmt.Fprintf(w, "round %d: %d of %d parties arrived, %d missing, broken: %t\n",
+		round, arrived, parties, parties-arrived, broken)
+	for i, at := range arrivals {
+		var name string
+		if i < len(names) && names[i] != "" {
+			name = " (" + names[i] + ")"
+		}
+		fmt.Fprintf(w, "waiter #%d%s: waited %s\n", i+1, name, now.Sub(at))
+		if i < len(stacks) && stacks[i] != nil {
+			fmt.Fprintf(w, "%s\n", stacks[i])
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package barrier
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDebugDump(t *testing.T) {
+	Convey("假设 Barrier 有 3 个参与者，并且开启了调试", t, func() {
+		b := New(3, WithDebug())
+		// 等第一个到达以后，第二个再到达，它们的序号才是确定的
+		goWait(b)
+		for b.NumberWaiting() < 1 {
+			runtime.Gosched()
+		}
+		go b.WaitAs(context.TODO(), "shard-7-writer")
+		for b.NumberWaiting() < 2 {
+			runtime.Gosched()
+		}
+
+		Convey("DebugDump 会打印等待中的 goroutine 和它们的调用栈", func() {
+			var buf bytes.Buffer
+			b.(DebugDumper).DebugDump(&buf)
+			dump := buf.String()
+			So(dump, ShouldStartWith, "round 0: 2 of 3 parties arrived, 1 missing, broken: false\n")
+			So(dump, ShouldContainSubstring, "waiter #1: waited ")
+			So(dump, ShouldContainSubstring, "waiter #2 (shard-7-writer): waited ")
+			So(strings.Count(dump, "runtime/debug.Stack"), ShouldEqual, 2)
+		})
+	})
+
+	Convey("假设 Barrier 没有开启调试", t, func() {
+		b := New(3)
+		goWait(b)
+		for b.NumberWaiting() < 1 {
+			runtime.Gosched()
+		}
+
+		Convey("DebugDump 只打印这一轮的状态", func() {
+			var buf bytes.Buffer
+			b.(DebugDumper).DebugDump(&buf)
+			So(buf.String(), ShouldEqual, "round 0: 1 of 3 parties arrived, 2 missing, broken: false\n")
+		})
+	})
+}
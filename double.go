@@ -0,0 +1,40 @@
+package barrier
+
+import "context"
+
+// DoubleBarrier lets a group of goroutines enter a phase together, and
+// leave it together, like the double barrier recipe of etcd.
+// The phases are cyclic, so the goroutines can enter again after leaving.
+type DoubleBarrier struct {
+	enter Barrier
+	leave Barrier
+}
+
+// NewDoubleBarrier initializes a new instance of the DoubleBarrier.
+// opts are applied to the enter and leave barriers both.
+func NewDoubleBarrier(participants int, opts ...Option) *DoubleBarrier {
+	return &DoubleBarrier{
+		enter: New(participants, opts...),
+		leave: New(participants, opts...),
+	}
+}
+
+// Enter waits all the participants to enter the phase.
+// It returns the error of the enter barrier, like Barrier.Wait,
+// then the caller should not call Leave of this phase.
+func (d *DoubleBarrier) Enter(ctx context.Context) error {
+	return d.enter.Wait(ctx)
+}
+
+// Leave waits all the participants to leave the phase.
+// It returns the error of the leave barrier, like Barrier.Wait.
+func (d *DoubleBarrier) Leave(ctx context.Context) error {
+	return d.leave.Wait(ctx)
+}
+
+// Reset resets the enter and leave barriers both,
+// after a phase is broken.
+func (d *DoubleBarrier) Reset() {
+	d.enter.Reset()
+	d.leave.Reset()
+}
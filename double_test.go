@@ -0,0 +1,71 @@
+package barrier
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDoubleBarrier(t *testing.T) {
+	Convey("假设 DoubleBarrier 有 4 个参与者", t, func() {
+		participants := 4
+		d := NewDoubleBarrier(participants)
+
+		Convey("所有参与者一起进入，一起离开，并且可以重复多次", func() {
+			phases := 5
+			var inside, left int32
+			var wg sync.WaitGroup
+			wg.Add(participants)
+			for i := 0; i < participants; i++ {
+				go func() {
+					defer wg.Done()
+					for p := 0; p < phases; p++ {
+						if d.Enter(context.TODO()) != nil {
+							return
+						}
+						atomic.AddInt32(&inside, 1)
+						if d.Leave(context.TODO()) != nil {
+							return
+						}
+						// 离开时，所有参与者都已经进入过了
+						if atomic.LoadInt32(&inside) == int32(participants) {
+							atomic.AddInt32(&left, 1)
+						}
+						if d.Enter(context.TODO()) != nil {
+							return
+						}
+						atomic.AddInt32(&inside, -1)
+						if d.Leave(context.TODO()) != nil {
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			So(atomic.LoadInt32(&left), ShouldEqual, participants*phases)
+			So(atomic.LoadInt32(&inside), ShouldEqual, 0)
+		})
+
+		Convey("被取消的 Enter 打破进入的这一轮，Reset 后可以重新开始", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			So(d.Enter(ctx), shouldBeBrokenBy, context.Canceled)
+			So(d.Enter(context.TODO()), shouldBeBrokenBy, context.Canceled)
+			d.Reset()
+			var wg sync.WaitGroup
+			wg.Add(participants)
+			for i := 0; i < participants; i++ {
+				go func() {
+					defer wg.Done()
+					if d.Enter(context.TODO()) == nil {
+						d.Leave(context.TODO())
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	})
+}
@@ -0,0 +1,269 @@
+package barrier
+
+import "time"
+
+// eventsBuffer is the capacity of the channels returned by Events.
+const eventsBuffer = 16
+
+// EventKind is the kind of a RoundEvent.
+// The kinds are bit flags, which can be combined for Subscribe.
+type EventKind uint8
+
+const (
+	// EventTripped is sent once a round completes, successfully or broken.
+	EventTripped EventKind = 1 << iota
+	// EventArrived is sent for every arrival.
+	EventArrived
+	// EventBroken is sent once a round is broken.
+	EventBroken
+	// EventReset is sent once Reset resets a round.
+	EventReset
+	// EventClosed is sent by Close, before the channels are closed.
+	EventClosed
+
+	// EventAll is all the kinds.
+	EventAll = EventTripped | EventArrived | EventBroken | EventReset | EventClosed
+)
+
+// DropPolicy decides which event is dropped, while the buffer of the
+// channel of a subscription is full.
+type DropPolicy int
+
+const (
+	// DropNewest drops the new event. It is the policy of Events.
+	DropNewest DropPolicy = iota
+	// DropOldest drops the oldest buffered event to make room for the new one.
+	DropOldest
+)
+
+// RoundEvent describes an event of a round of a Barrier.
+type RoundEvent struct {
+	Kind    EventKind
+	Round   uint64    // number of the round, the first one is 1
+	Broken  bool      // the round is broken instead of completing successfully
+	Parties int       // goroutines arrived in the round
+	Cause   error     // why the round is broken, for EventBroken
+	When    time.Time // when the event happened
+}
+
+// Observer is notified of the lifecycle of the rounds of a Barrier.
+// Its methods are called synchronously, so they should return quickly.
+type Observer interface {
+	// OnArrive is called by every arrived goroutine, with its arrival
+	// count in the round.
+	OnArrive(count int)
+	// OnTrip is called by the goroutine tripping a round, with the number
+	// of completed rounds including it, before the release of the round.
+	OnTrip(round uint64)
+	// OnBreak is called once a round is broken, with the cause like the
+	// hook of OnBroken.
+	OnBreak(cause error)
+}
+
+// DurationObserver is an Observer, which is notified of the durations of
+// the waits and the actions as well.
+type DurationObserver interface {
+	Observer
+	// OnWaited is called by every goroutine returning from Wait, and the
+	// others waiting like it, with how long it has waited.
+	OnWaited(d time.Duration)
+	// OnAction is called by the goroutine tripping a round, with how long
+	// the action of the round took.
+	OnAction(d time.Duration)
+}
+
+// RoundInfo describes a released round of a Barrier.
+type RoundInfo struct {
+	Round    uint64    // number of completed rounds including this one
+	Parties  int       // goroutines arrived in the round when it tripped
+	Released time.Time // when the release began
+}
+
+// EventSubscriber is implemented by the Barrier returned by New, which
+// sends the events of all the kinds. Check it by a type assertion, like
+// FanoutReporter.
+type EventSubscriber interface {
+	// Subscribe is Events, but receives the events of kinds, which is a
+	// combination of EventKind, with a channel buffering buffer events.
+	// policy decides which event is dropped while the buffer is full.
+	// Events is Subscribe(16, DropNewest, EventTripped).
+	// It panics if buffer is negative.
+	Subscribe(buffer int, policy DropPolicy, kinds EventKind) <-chan RoundEvent
+
+	// Unsubscribe stops sending events to the channel returned by Events
+	// or Subscribe, and closes it. It does nothing with an unknown channel.
+	Unsubscribe(events <-chan RoundEvent)
+}
+
+// subscriber is a subscription of the events.
+type subscriber struct {
+	ch     chan RoundEvent
+	kinds  EventKind
+	policy DropPolicy
+}
+
+func (b *barrier) Events() <-chan RoundEvent {
+	return b.Subscribe(eventsBuffer, DropNewest, EventTripped)
+}
+
+func (b *barrier) Subscribe(buffer int, policy DropPolicy, kinds EventKind) <-chan RoundEvent {
+	if buffer < 0 {
+		panic(negativeBuffer)
+	}
+	ch := make(chan RoundEvent, buffer)
+	b.lockArrivals()
+	if b.closed {
+		close(ch)
+	} else {
+		b.subscribers = append(b.subscribers, subscriber{
+			ch:     ch,
+			kinds:  kinds,
+			policy: policy,
+		})
+	}
+	b.unlockArrivals()
+	return ch
+}
+
+func (b *barrier) Unsubscribe(events <-chan RoundEvent) {
+	b.lockArrivals()
+	defer b.unlockArrivals()
+	for i, s := range b.subscribers {
+		if s.ch == events {
+			b.subscribers = append(b.subscribers[:i], b.subscribers[i+1:]...)
+			close(s.ch)
+			return
+		}
+	}
+}
+
+// publish sends the EventTripped of r to the subscribers.
+// It should be called with b.lock held.
+func (b *barrier) publish(r *round, when time.Time) {
+	if len(b.subscribers) == 0 {
+		return
+	}
+	b.send(RoundEvent{
+		Kind:    EventTripped,
+		Round:   b.rounds,
+		Broken:  r.isBroken,
+		Parties: r.count(),
+		When:    when,
+	})
+}
+
+// emit sends the event of kind to the subscribers.
+// The event is broken, if cause is not nil.
+// It should be called with b.lock held, for reading at least.
+func (b *barrier) emit(kind EventKind, round uint64, parties int, cause error) {
+	if len(b.subscribers) == 0 {
+		return
+	}
+	b.send(RoundEvent{
+		Kind:    kind,
+		Round:   round,
+		Broken:  cause != nil,
+		Parties: parties,
+		Cause:   cause,
+		When:    time.Now(),
+	})
+}
+
+// send sends e to the subscribers of its kind without blocking.
+// The event is dropped by the policy for the subscriber whose buffer
+// is full.
+func (b *barrier) send(e RoundEvent) {
+	for _, s := range b.subscribers {
+		if s.kinds&e.Kind == 0 {
+			continue
+		}
+		select {
+		case s.ch <- e:
+			continue
+		default:
+		}
+		if s.policy != DropOldest {
+			continue
+		}
+		select {
+		case <-s.ch:
+		default:
+		}
+		select {
+		case s.ch <- e:
+		default:
+		}
+	}
+}
+
+// Observers combines observers into one, which notifies them in order.
+// nil observers are skipped. The combined one is a DurationObserver, which
+// notifies the durations to the DurationObservers among them.
+func Observers(observers ...Observer) Observer {
+	var res multiObserver
+	for _, o := range observers {
+		switch o := o.(type) {
+		case nil:
+		case multiObserver:
+			res = append(res, o...)
+		default:
+			res = append(res, o)
+		}
+	}
+	return res
+}
+
+// ObserverAdder is implemented by the Barrier returned by New, whose
+// observers can be added one by one. Check it by a type assertion, like
+// FanoutReporter.
+type ObserverAdder interface {
+	// AddObserver combines o with the observer set before by Observers,
+	// so that both of them are notified. nil o is ignored.
+	AddObserver(o Observer)
+}
+
+func (b *barrier) AddObserver(o Observer) {
+	if o == nil {
+		return
+	}
+	b.lockArrivals()
+	b.setObserver(Observers(b.observer, o))
+	b.unlockArrivals()
+}
+
+// multiObserver is the observers combined by Observers.
+type multiObserver []Observer
+
+func (m multiObserver) OnArrive(count int) {
+	for _, o := range m {
+		o.OnArrive(count)
+	}
+}
+
+func (m multiObserver) OnTrip(round uint64) {
+	for _, o := range m {
+		o.OnTrip(round)
+	}
+}
+
+func (m multiObserver) OnBreak(cause error) {
+	for _, o := range m {
+		o.OnBreak(cause)
+	}
+}
+
+func (m multiObserver) OnWaited(d time.Duration) {
+	for _, o := range m {
+		if o, ok := o.(DurationObserver); ok {
+			o.OnWaited(d)
+		}
+	}
+}
+
+func (m multiObserver) OnAction(d time.Duration) {
+	for _, o := range m {
+		if o, ok := o.(DurationObserver); ok {
+			o.OnAction(d)
+		}
+	}
+}
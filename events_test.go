@@ -0,0 +1,254 @@
+package barrier
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEvents(t *testing.T) {
+	Convey("假设 Barrier 有 2 个参与者，并订阅了事件", t, func() {
+		participants := 2
+		b := New(participants)
+		events := b.Events()
+
+		Convey("完成 3 轮，其中第 2 轮被 Break，会收到 3 个事件", func() {
+			goWait(b)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			goWait(b)
+			b.Break()
+			goWait(b)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			for i, broken := range []bool{false, true, false} {
+				e := <-events
+				So(e.Round, ShouldEqual, i+1)
+				So(e.Broken, ShouldEqual, broken)
+				So(e.Parties, ShouldEqual, participants)
+				So(e.When.IsZero(), ShouldBeFalse)
+			}
+		})
+
+		Convey("没有人接收时，多出缓存的事件会被丢弃，Barrier 不会被阻塞", func() {
+			for i := 0; i < eventsBuffer*2; i++ {
+				goWait(b)
+				So(b.Wait(context.TODO()), ShouldBeNil)
+			}
+			So(len(events), ShouldEqual, eventsBuffer)
+			So((<-events).Round, ShouldEqual, 1)
+		})
+
+		Convey("Unsubscribe 以后，channel 会被关闭", func() {
+			b.(EventSubscriber).Unsubscribe(events)
+			goWait(b)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			_, ok := <-events
+			So(ok, ShouldBeFalse)
+			b.(EventSubscriber).Unsubscribe(events)
+		})
+
+		Convey("Close 以后，channel 会被关闭，之后 Events 返回的 channel 也是关闭的", func() {
+			So(b.Close(), ShouldBeNil)
+			_, ok := <-events
+			So(ok, ShouldBeFalse)
+			_, ok = <-b.Events()
+			So(ok, ShouldBeFalse)
+			b.(EventSubscriber).Unsubscribe(events)
+		})
+	})
+}
+
+func TestSubscribe(t *testing.T) {
+	Convey("假设 Barrier 有 2 个参与者，订阅了所有种类的事件", t, func() {
+		b := New(2)
+		events := b.(EventSubscriber).Subscribe(32, DropNewest, EventAll)
+		next := func() RoundEvent {
+			e := <-events
+			So(e.When.IsZero(), ShouldBeFalse)
+			return e
+		}
+
+		Convey("完成一轮，会依次收到 2 个 EventArrived 和 1 个 EventTripped", func() {
+			goWait(b)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			for i := 1; i <= 2; i++ {
+				e := next()
+				So(e.Kind, ShouldEqual, EventArrived)
+				So(e.Round, ShouldEqual, 1)
+				So(e.Parties, ShouldEqual, i)
+			}
+			e := next()
+			So(e.Kind, ShouldEqual, EventTripped)
+			So(e.Round, ShouldEqual, 1)
+			So(e.Broken, ShouldBeFalse)
+		})
+
+		Convey("Break 以后 Reset，会收到 EventBroken 和 EventReset", func() {
+			b.Break()
+			b.Reset()
+			So(next().Kind, ShouldEqual, EventArrived)
+			e := next()
+			So(e.Kind, ShouldEqual, EventBroken)
+			So(e.Cause, ShouldEqual, ErrBroken)
+			So(e.Round, ShouldEqual, 1)
+			So(next().Kind, ShouldEqual, EventTripped)
+			e = next()
+			So(e.Kind, ShouldEqual, EventReset)
+			So(e.Round, ShouldEqual, 1)
+			So(e.Parties, ShouldEqual, 1)
+
+			Convey("Close 会先发送 EventClosed，再关闭 channel", func() {
+				b.Close()
+				e := next()
+				So(e.Kind, ShouldEqual, EventClosed)
+				So(e.Round, ShouldEqual, 2)
+				_, ok := <-events
+				So(ok, ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey("假设只订阅 EventTripped，缓存 1 个事件，并丢弃最旧的事件", t, func() {
+		b := New(1)
+		events := b.(EventSubscriber).Subscribe(1, DropOldest, EventTripped)
+
+		Convey("完成 3 轮以后，只剩下最后一轮的事件", func() {
+			for i := 0; i < 3; i++ {
+				So(b.Wait(context.TODO()), ShouldBeNil)
+			}
+			So(len(events), ShouldEqual, 1)
+			So((<-events).Round, ShouldEqual, 3)
+		})
+	})
+
+	Convey("订阅时缓存的大小是负数，会 panic", t, func() {
+		So(func() {
+			New(1).(EventSubscriber).Subscribe(-1, DropNewest, EventAll)
+		}, ShouldPanicWith, negativeBuffer)
+	})
+}
+
+// recorder is an Observer recording the notifications.
+type recorder struct {
+	lock     sync.Mutex
+	arrivals []int
+	trips    []uint64
+	breaks   []error
+}
+
+func (r *recorder) OnArrive(count int) {
+	r.lock.Lock()
+	r.arrivals = append(r.arrivals, count)
+	r.lock.Unlock()
+}
+
+func (r *recorder) OnTrip(round uint64) {
+	r.lock.Lock()
+	r.trips = append(r.trips, round)
+	r.lock.Unlock()
+}
+
+func (r *recorder) OnBreak(cause error) {
+	r.lock.Lock()
+	r.breaks = append(r.breaks, cause)
+	r.lock.Unlock()
+}
+
+func TestObserver(t *testing.T) {
+	Convey("假设 Barrier 有 2 个参与者，并设置了 Observer", t, func() {
+		o := &recorder{}
+		b := New(2, WithObserver(o))
+
+		Convey("完成一轮时，通知每一次到达，和这一轮的完成", func() {
+			goWait(b)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			o.lock.Lock()
+			sort.Ints(o.arrivals)
+			So(o.arrivals, ShouldResemble, []int{1, 2})
+			So(o.trips, ShouldResemble, []uint64{1})
+			So(o.breaks, ShouldBeNil)
+			o.lock.Unlock()
+		})
+
+		Convey("一轮被打破时，通知打破的原因", func() {
+			b.Break()
+			So(b.Wait(context.TODO()), shouldBeBrokenBy, ErrBroken)
+			So(o.breaks, ShouldResemble, []error{ErrBroken})
+			So(o.trips, ShouldBeNil)
+
+			Convey("取消 Observer 以后，不再通知", func() {
+				b.SetObserver(nil)
+				goWait(b)
+				So(b.Wait(context.TODO()), ShouldBeNil)
+				So(o.trips, ShouldBeNil)
+				So(len(o.arrivals), ShouldEqual, 2)
+			})
+		})
+	})
+}
+
+// timer is a DurationObserver recording the durations.
+type timer struct {
+	recorder
+	waits   []time.Duration
+	actions []time.Duration
+}
+
+func (t *timer) OnWaited(d time.Duration) {
+	t.lock.Lock()
+	t.waits = append(t.waits, d)
+	t.lock.Unlock()
+}
+
+func (t *timer) OnAction(d time.Duration) {
+	t.lock.Lock()
+	t.actions = append(t.actions, d)
+	t.lock.Unlock()
+}
+
+func TestDurationObserver(t *testing.T) {
+	Convey("假设 Barrier 有 2 个参与者，action 需要 10ms，并设置了 DurationObserver", t, func() {
+		o := &timer{}
+		b := New(2, WithObserver(o), WithAction(func() error {
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		}))
+
+		Convey("完成一轮时，通知 2 次等待和 1 次 action 的时长", func() {
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- b.Wait(context.TODO())
+			}()
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(<-errCh, ShouldBeNil)
+			o.lock.Lock()
+			defer o.lock.Unlock()
+			So(len(o.waits), ShouldEqual, 2)
+			for _, d := range o.waits {
+				So(d, ShouldBeGreaterThanOrEqualTo, 10*time.Millisecond)
+			}
+			So(len(o.actions), ShouldEqual, 1)
+			So(o.actions[0], ShouldBeGreaterThanOrEqualTo, 10*time.Millisecond)
+		})
+	})
+}
+
+func TestObservers(t *testing.T) {
+	Convey("组合 2 个 Observer 和 nil，其中一个是 DurationObserver", t, func() {
+		r, d := &recorder{}, &timer{}
+		o := Observers(nil, r, Observers(d))
+		b := New(1, WithObserver(o))
+
+		Convey("2 个 Observer 都会收到通知，只有 DurationObserver 收到时长", func() {
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(r.arrivals, ShouldResemble, []int{1})
+			So(d.arrivals, ShouldResemble, []int{1})
+			So(r.trips, ShouldResemble, []uint64{1})
+			So(d.trips, ShouldResemble, []uint64{1})
+			So(len(d.waits), ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,33 @@
+package barrier
+
+import "context"
+
+// Exchange is a Barrier, every participant of which contributes a value,
+// and receives the values of all participants of the round, which is
+// known as all-gather.
+// Participants calling Wait or Break of the embedded Barrier contribute
+// the zero value of T.
+type Exchange[T any] struct {
+	Barrier
+	b *barrier
+}
+
+// NewExchange initializes a new instance of the Exchange.
+func NewExchange[T any](participants int, opts ...Option) *Exchange[T] {
+	b := New(participants, opts...).(*barrier)
+	return &Exchange[T]{
+		Barrier: b,
+		b:       b,
+	}
+}
+
+// Wait contributes v to the round, waits like Barrier.Wait, and returns
+// the values of the round in arrival order.
+// Every participant receives its own slice, which is free to modify.
+func (e *Exchange[T]) Wait(ctx context.Context, v T) ([]T, error) {
+	out, _, err := e.b.wait(ctx, box[T]{v}, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return unbox[T](out.values), nil
+}
@@ -0,0 +1,53 @@
+package barrier
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExchange(t *testing.T) {
+	Convey("假设 Exchange 有 4 个参与者，每个参与者贡献一个字符串", t, func() {
+		participants := 4
+		e := NewExchange[string](participants)
+		var wg sync.WaitGroup
+		results := make(chan []string, participants)
+		wg.Add(participants)
+		for _, v := range []string{"a", "b", "c", "d"} {
+			go func(v string) {
+				all, err := e.Wait(context.TODO(), v)
+				if err == nil {
+					results <- all
+				}
+				wg.Done()
+			}(v)
+		}
+		wg.Wait()
+		close(results)
+
+		Convey("每个参与者都得到了相同顺序的全部贡献，并且互不影响", func() {
+			var first []string
+			for all := range results {
+				if first == nil {
+					first = append([]string(nil), all...)
+				}
+				So(all, ShouldResemble, first)
+				all[0] = "modified"
+			}
+			sorted := append([]string(nil), first...)
+			sort.Strings(sorted)
+			So(sorted, ShouldResemble, []string{"a", "b", "c", "d"})
+		})
+	})
+
+	Convey("假设 Exchange 有 2 个参与者，其中 1 个 Break 了", t, func() {
+		e := NewExchange[int](2)
+		go e.Break()
+		all, err := e.Wait(context.TODO(), 1)
+		So(err, shouldBeBrokenBy, ErrBroken)
+		So(all, ShouldBeNil)
+	})
+}
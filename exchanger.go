@@ -0,0 +1,34 @@
+package barrier
+
+import "context"
+
+// Exchanger is a rendezvous of two goroutines, each of which hands its
+// value to the other. It is a Barrier of 2 participants, so the rounds
+// break and reset like the ones of Barrier.
+type Exchanger[T any] struct {
+	Barrier
+	b *barrier
+}
+
+// NewExchanger initializes a new instance of the Exchanger.
+func NewExchanger[T any](opts ...Option) *Exchanger[T] {
+	b := New(2, opts...).(*barrier)
+	return &Exchanger[T]{
+		Barrier: b,
+		b:       b,
+	}
+}
+
+// Exchange waits another goroutine to arrive, then gives v to it, and
+// returns its value.
+// A goroutine calling Wait or Break of the embedded Barrier gives the
+// zero value of T.
+func (e *Exchanger[T]) Exchange(ctx context.Context, v T) (T, error) {
+	out, count, err := e.b.wait(ctx, box[T]{v}, 0, 0)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	// count is 1 or 2, the other one arrives at 2 - count.
+	return unbox[T](out.values)[2-count], nil
+}
@@ -0,0 +1,46 @@
+package barrier
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExchanger(t *testing.T) {
+	Convey("假设有一个交换 int 的 Exchanger", t, func() {
+		e := NewExchanger[int]()
+
+		Convey("两个 goroutine 交换各自的值，可以多次交换", func() {
+			for i := 0; i < 10; i++ {
+				got := make(chan int, 1)
+				go func() {
+					v, err := e.Exchange(context.TODO(), i)
+					if err != nil {
+						v = -1
+					}
+					got <- v
+				}()
+				v, err := e.Exchange(context.TODO(), i*10)
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, i)
+				So(<-got, ShouldEqual, i*10)
+			}
+		})
+
+		Convey("Wait 的 goroutine 交出零值", func() {
+			go e.Wait(context.TODO())
+			v, err := e.Exchange(context.TODO(), 1)
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, 0)
+		})
+
+		Convey("被取消的 Exchange 打破这一轮，返回零值", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			v, err := e.Exchange(ctx, 1)
+			So(err, shouldBeBrokenBy, context.Canceled)
+			So(v, ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,23 @@
+package barrier
+
+import "expvar"
+
+// PublishExpvar publishes the live state of b as an expvar map named name,
+// which has the keys waiting, round, broken and participants.
+// Like expvar.Publish, it panics if name is already registered.
+func PublishExpvar(name string, b Barrier) *expvar.Map {
+	m := expvar.NewMap(name)
+	m.Set("waiting", expvar.Func(func() interface{} {
+		return b.NumberWaiting()
+	}))
+	m.Set("round", expvar.Func(func() interface{} {
+		return b.Round()
+	}))
+	m.Set("broken", expvar.Func(func() interface{} {
+		return b.IsBroken()
+	}))
+	m.Set("participants", expvar.Func(func() interface{} {
+		return b.Participants()
+	}))
+	return m
+}
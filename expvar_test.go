@@ -0,0 +1,46 @@
+package barrier
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPublishExpvar(t *testing.T) {
+	b := New(2)
+	name := fmt.Sprintf("barrier-test-%p", b)
+	PublishExpvar(name, b)
+	state := func() (res struct {
+		Waiting      int
+		Round        uint64
+		Broken       bool
+		Participants int
+	}) {
+		So(json.Unmarshal([]byte(expvar.Get(name).String()), &res), ShouldBeNil)
+		return
+	}
+
+	Convey("发布到 expvar 以后，可以读到 Barrier 的实时状态", t, func() {
+		s := state()
+		So(s.Participants, ShouldEqual, 2)
+		So(s.Round, ShouldEqual, 0)
+		So(s.Waiting, ShouldEqual, 0)
+		So(s.Broken, ShouldBeFalse)
+
+		goWait(b)
+		So(b.Wait(context.TODO()), ShouldBeNil)
+		b.Break()
+		s = state()
+		So(s.Round, ShouldEqual, 1)
+		So(s.Waiting, ShouldEqual, 1)
+		So(s.Broken, ShouldBeTrue)
+	})
+
+	Convey("重复发布同一个名字会 panic", t, func() {
+		So(func() { PublishExpvar(name, b) }, ShouldPanic)
+	})
+}
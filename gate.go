@@ -0,0 +1,78 @@
+package barrier
+
+import (
+	"context"
+	"sync"
+)
+
+// Gate is a starting gate. The goroutines calling Wait are blocked until
+// a coordinator calls Open, which releases all of them at once.
+// The later calls of Wait return at once, until the Gate is closed again.
+type Gate struct {
+	lock sync.Mutex
+	open chan struct{} // closed while the Gate is open
+}
+
+// NewGate initializes a new instance of the Gate, which is closed.
+func NewGate() *Gate {
+	return &Gate{
+		open: make(chan struct{}),
+	}
+}
+
+// Wait blocks until the Gate is open.
+// It returns ctx.Err() if ctx is done before that.
+func (g *Gate) Wait(ctx context.Context) error {
+	g.lock.Lock()
+	open := g.open
+	g.lock.Unlock()
+	select {
+	case <-open:
+		return nil
+	default:
+	}
+	select {
+	case <-open:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Open opens the Gate, and releases all the waiting goroutines.
+// It does nothing if the Gate is open already.
+func (g *Gate) Open() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	select {
+	case <-g.open:
+	default:
+		close(g.open)
+	}
+}
+
+// Close closes the Gate again, then the goroutines calling Wait are
+// blocked until the next Open. The goroutines released by the previous
+// Open are not affected.
+// It does nothing if the Gate is closed already.
+func (g *Gate) Close() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	select {
+	case <-g.open:
+		g.open = make(chan struct{})
+	default:
+	}
+}
+
+// IsOpen reports whether the Gate is open.
+func (g *Gate) IsOpen() bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	select {
+	case <-g.open:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,73 @@
+package barrier
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGate(t *testing.T) {
+	Convey("假设有 5 个 goroutine 在关闭的 Gate 前等待", t, func() {
+		g := NewGate()
+		So(g.IsOpen(), ShouldBeFalse)
+		waiters := 5
+		var started, released int32
+		var wg sync.WaitGroup
+		wg.Add(waiters)
+		for i := 0; i < waiters; i++ {
+			go func() {
+				atomic.AddInt32(&started, 1)
+				if g.Wait(context.TODO()) == nil {
+					atomic.AddInt32(&released, 1)
+				}
+				wg.Done()
+			}()
+		}
+		for atomic.LoadInt32(&started) < int32(waiters) {
+			runtime.Gosched()
+		}
+
+		Convey("Open 之前，没有 goroutine 被放行", func() {
+			So(atomic.LoadInt32(&released), ShouldEqual, 0)
+
+			Convey("被取消的 Wait 返回 ctx.Err()", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				So(g.Wait(ctx), ShouldEqual, context.Canceled)
+			})
+		})
+
+		Convey("Open 之后，所有 goroutine 都被放行，随后的 Wait 立即返回", func() {
+			g.Open()
+			g.Open()
+			wg.Wait()
+			So(atomic.LoadInt32(&released), ShouldEqual, waiters)
+			So(g.IsOpen(), ShouldBeTrue)
+			So(g.Wait(context.TODO()), ShouldBeNil)
+
+			Convey("再次 Close 之后，Wait 又会等待下一次 Open", func() {
+				g.Close()
+				g.Close()
+				So(g.IsOpen(), ShouldBeFalse)
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				So(g.Wait(ctx), ShouldEqual, context.Canceled)
+				errCh := make(chan error, 1)
+				go func() {
+					errCh <- g.Wait(context.TODO())
+				}()
+				g.Open()
+				So(<-errCh, ShouldBeNil)
+			})
+		})
+
+		Reset(func() {
+			g.Open()
+			wg.Wait()
+		})
+	})
+}
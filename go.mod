@@ -1,12 +1,14 @@
 module github.com/aQuaYi/barrier
 
-go 1.13
+go 1.18
 
 require (
-	github.com/gopherjs/gopherjs v0.0.0-20190915194858-d3ddacdb130f // indirect
 	github.com/marusama/cyclicbarrier v0.0.0-20181027101648-08d457ab265c
-	github.com/smartystreets/assertions v1.0.1 // indirect
 	github.com/smartystreets/goconvey v0.0.0-20190731233626-505e41936337
-	github.com/stretchr/testify v1.4.0
-	golang.org/x/tools v0.0.0-20191005014404-c9f9432ec4b2 // indirect
+)
+
+require (
+	github.com/gopherjs/gopherjs v0.0.0-20190915194858-d3ddacdb130f // indirect
+	github.com/jtolds/gls v4.20.0+incompatible // indirect
+	github.com/smartystreets/assertions v1.0.1 // indirect
 )
@@ -0,0 +1,179 @@
+package barrier
+
+import (
+	"context"
+	"time"
+)
+
+// Group composes child barriers, one for each team of goroutines, into a
+// parent barrier, which trips once the rounds of all children trip.
+// The participants of a child are released after the parent trips, so
+// every team completes its round together with the others.
+// Once a child round is broken, the parent round is broken, and the
+// current rounds of the other children as well.
+type Group struct {
+	parent   *barrier
+	children []*member
+}
+
+// NewGroup initializes a new instance of the Group, with a child for every
+// size of teams, which is the participants of the child.
+// opts are applied to the parent, whose actions run after all the children
+// trip.
+// It panics if teams is empty.
+func NewGroup(teams []int, opts ...Option) *Group {
+	g := &Group{
+		parent: New(len(teams), opts...).(*barrier),
+	}
+	hook := g.parent.onBroken
+	g.parent.OnBroken(func(cause error) {
+		if hook != nil {
+			hook(cause)
+		}
+		err := causeOf(cause)
+		for _, c := range g.children {
+			c.breakCurrent(err, parentBroken{err})
+		}
+	})
+	for _, size := range teams {
+		g.children = append(g.children, newMember(New(size).(*barrier), g.parent, g.parent))
+	}
+	return g
+}
+
+// Child returns the barrier of the i-th team.
+// Its actions run after the parent trips, and its OnBroken hook is called
+// after the child breaks the parent, so that they never detach the child
+// from the Group.
+func (g *Group) Child(i int) Barrier {
+	return g.children[i]
+}
+
+// Round returns the number of the completed rounds of the parent.
+func (g *Group) Round() uint64 {
+	return g.parent.Round()
+}
+
+// IsBroken reports whether the current round of the parent is broken.
+func (g *Group) IsBroken() bool {
+	return g.parent.IsBroken()
+}
+
+// Reset resets the parent and all the children.
+func (g *Group) Reset() {
+	g.parent.Reset()
+	for _, c := range g.children {
+		c.Reset()
+	}
+}
+
+// Wait is the shortcut of g.Child(i).Wait(ctx).
+func (g *Group) Wait(ctx context.Context, i int) error {
+	return g.children[i].Wait(ctx)
+}
+
+// parentBroken is the cause of a child round broken by the parent.
+type parentBroken struct {
+	error
+}
+
+func (e parentBroken) Unwrap() error {
+	return e.error
+}
+
+// causeOf returns the cause of err, if it is a *BrokenError, so that
+// the child breaking with it does not report "barrier is broken" twice.
+func causeOf(err error) error {
+	if e, ok := err.(*BrokenError); ok {
+		return e.Cause
+	}
+	return err
+}
+
+// member is a child barrier linked to its parent by its first action.
+// Setting its actions keeps the link, and its OnBroken hook is called
+// after the one breaking the parent.
+type member struct {
+	*barrier
+	link   func(context.Context) error // waits the parent
+	broken func(cause error)           // breaks the top
+}
+
+// newMember links b to parent, so that the last arrived goroutine of b
+// waits parent. A round of b broken by itself breaks top, whose OnBroken
+// hook should break the current rounds of all its members.
+func newMember(b, parent, top *barrier) *member {
+	m := &member{barrier: b}
+	m.link = m.withActing(func(ctx context.Context) error {
+		if err := parent.Wait(ctx); err != nil {
+			return parentBroken{causeOf(err)}
+		}
+		return nil
+	})
+	m.broken = func(cause error) {
+		if _, ok := cause.(parentBroken); ok {
+			// top may have started the next round.
+			return
+		}
+		top.breakCurrent(cause, cause)
+	}
+	m.setAction(m.link)
+	m.barrier.OnBroken(m.broken)
+	return m
+}
+
+func (m *member) SetAction(action func()) Barrier {
+	if action == nil {
+		return m.SetActionE(nil)
+	}
+	return m.SetActionE(func() error {
+		action()
+		return nil
+	})
+}
+
+func (m *member) SetActionE(action func() error) Barrier {
+	if action == nil {
+		return m.SetActionCtx(nil)
+	}
+	return m.SetActionCtx(func(context.Context) error {
+		return action()
+	})
+}
+
+func (m *member) SetActionCtx(action func(context.Context) error) Barrier {
+	m.setAction(m.link, m.withActing(action))
+	return m
+}
+
+func (m *member) AddAction(action func() error) Barrier {
+	m.barrier.AddAction(action)
+	return m
+}
+
+func (m *member) OnBroken(hook func(cause error)) Barrier {
+	if hook == nil {
+		m.barrier.OnBroken(m.broken)
+		return m
+	}
+	m.barrier.OnBroken(func(cause error) {
+		m.broken(cause)
+		hook(cause)
+	})
+	return m
+}
+
+func (m *member) OnRelease(hook func(RoundInfo)) Barrier {
+	m.barrier.OnRelease(hook)
+	return m
+}
+
+func (m *member) SetObserver(o Observer) Barrier {
+	m.barrier.SetObserver(o)
+	return m
+}
+
+func (m *member) SetRoundTimeout(d time.Duration) Barrier {
+	m.barrier.SetRoundTimeout(d)
+	return m
+}
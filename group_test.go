@@ -0,0 +1,136 @@
+package barrier
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGroup(t *testing.T) {
+	Convey("没有 team 时，NewGroup 会 panic", t, func() {
+		So(func() { NewGroup(nil) }, ShouldPanic)
+	})
+
+	Convey("假设 Group 有 2 个 team，分别有 2 个和 3 个参与者", t, func() {
+		teams := []int{2, 3}
+		var executed int32
+		g := NewGroup(teams, WithAction(func() error {
+			atomic.AddInt32(&executed, 1)
+			return nil
+		}))
+		// run 让每个 team 的参与者都 Wait rounds 次，返回所有的 error
+		run := func(rounds int, ctxOf func(team, i int) context.Context) chan error {
+			var wg sync.WaitGroup
+			errs := make(chan error, 5*rounds)
+			for team, size := range teams {
+				for i := 0; i < size; i++ {
+					wg.Add(1)
+					go func(team, i int) {
+						defer wg.Done()
+						for j := 0; j < rounds; j++ {
+							if err := g.Wait(ctxOf(team, i), team); err != nil {
+								errs <- err
+								return
+							}
+						}
+					}(team, i)
+				}
+			}
+			wg.Wait()
+			close(errs)
+			return errs
+		}
+		background := func(team, i int) context.Context {
+			return context.Background()
+		}
+
+		Convey("所有 team 都完成一轮后，Group 才完成一轮", func() {
+			rounds := 5
+			So(len(run(rounds, background)), ShouldEqual, 0)
+			So(g.Round(), ShouldEqual, rounds)
+			So(atomic.LoadInt32(&executed), ShouldEqual, rounds)
+			for i := range teams {
+				So(g.Child(i).Round(), ShouldEqual, rounds)
+			}
+		})
+
+		Convey("一个 team 的这一轮被打破时，所有的参与者都会返回错误", func() {
+			canceled, cancel := context.WithCancel(context.Background())
+			cancel()
+			errs := run(1, func(team, i int) context.Context {
+				if team == 0 && i == 0 {
+					return canceled
+				}
+				return context.Background()
+			})
+			So(len(errs), ShouldEqual, 5)
+			for err := range errs {
+				So(err, shouldBeBrokenBy, context.Canceled)
+			}
+
+			Convey("Reset 以后，Group 可以继续使用", func() {
+				g.Reset()
+				So(g.IsBroken(), ShouldBeFalse)
+				round := g.Round()
+				So(len(run(2, background)), ShouldEqual, 0)
+				So(g.Round(), ShouldEqual, round+2)
+			})
+		})
+	})
+
+	Convey("假设 Group 的 parent 设置了 WithOnBroken，有 2 个各 1 个参与者的 team", t, func() {
+		var causes []error
+		g := NewGroup([]int{1, 1}, WithOnBroken(func(cause error) {
+			causes = append(causes, cause)
+		}))
+		canceled, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		Convey("一个 team 被打破时，parent 的 hook 依然会被调用", func() {
+			err := g.Wait(canceled, 0)
+			So(err, shouldBeBrokenBy, context.Canceled)
+			So(len(causes), ShouldEqual, 1)
+
+			Convey("被 parent 打破的 team 只报告一次 barrier is broken", func() {
+				err := g.Wait(context.Background(), 1)
+				So(err, shouldBeBrokenBy, context.Canceled)
+				So(strings.Count(err.Error(), "barrier is broken"), ShouldEqual, 1)
+			})
+		})
+	})
+
+	Convey("假设 Group 有 2 个各 1 个参与者的 team，并设置了 team 的 action 和 hook", t, func() {
+		g := NewGroup([]int{1, 1})
+		var actions int32
+		var broken []error
+		g.Child(0).SetAction(func() {
+			atomic.AddInt32(&actions, 1)
+		}).OnBroken(func(cause error) {
+			broken = append(broken, cause)
+		})
+
+		Convey("team 依然要等其他 team 完成这一轮，action 在之后执行", func() {
+			errs := make(chan error)
+			go func() {
+				errs <- g.Wait(context.Background(), 0)
+			}()
+			So(g.Wait(context.Background(), 1), ShouldBeNil)
+			So(<-errs, ShouldBeNil)
+			So(atomic.LoadInt32(&actions), ShouldEqual, 1)
+			So(g.Round(), ShouldEqual, 1)
+		})
+
+		Convey("team 被打破时，它的 hook 会被调用，parent 也会被打破", func() {
+			cause := errors.New("team failed")
+			g.Child(0).(CauseBreaker).BreakWith(cause)
+			So(len(broken), ShouldEqual, 1)
+			So(errors.Is(broken[0], cause), ShouldBeTrue)
+			So(g.IsBroken(), ShouldBeTrue)
+		})
+	})
+}
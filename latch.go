@@ -0,0 +1,70 @@
+package barrier
+
+import (
+	"context"
+	"sync"
+)
+
+// Latch is the one-shot sibling of Barrier, known as CountDownLatch.
+// The goroutines calling Wait are blocked until the count reaches zero by
+// the calls of CountDown, then all of them are released, and the later
+// calls of Wait return at once. A Latch can not be reset.
+type Latch struct {
+	lock  sync.Mutex
+	count int
+	done  chan struct{}
+}
+
+// NewLatch initializes a new instance of the Latch with count.
+// It panics if count < 0. A Latch with zero count is open already.
+func NewLatch(count int) *Latch {
+	if count < 0 {
+		panic("barrier latch count is negative")
+	}
+	l := &Latch{
+		count: count,
+		done:  make(chan struct{}),
+	}
+	if count == 0 {
+		close(l.done)
+	}
+	return l
+}
+
+// CountDown decrements the count, and releases the waiting goroutines
+// once it reaches zero. It does nothing if the count is zero already.
+func (l *Latch) CountDown() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.count == 0 {
+		return
+	}
+	l.count--
+	if l.count == 0 {
+		close(l.done)
+	}
+}
+
+// Wait blocks until the count reaches zero.
+// It returns ctx.Err() if ctx is done before that, which does not
+// affect the count and the other waiting goroutines.
+func (l *Latch) Wait(ctx context.Context) error {
+	select {
+	case <-l.done:
+		return nil
+	default:
+	}
+	select {
+	case <-l.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Count returns the current count.
+func (l *Latch) Count() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.count
+}
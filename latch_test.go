@@ -0,0 +1,74 @@
+package barrier
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLatch(t *testing.T) {
+	Convey("count 为负数时，NewLatch 会 panic", t, func() {
+		So(func() { NewLatch(-1) }, ShouldPanic)
+	})
+
+	Convey("count 为 0 的 Latch 已经打开，Wait 立即返回", t, func() {
+		l := NewLatch(0)
+		So(l.Wait(context.TODO()), ShouldBeNil)
+		l.CountDown()
+		So(l.Count(), ShouldEqual, 0)
+	})
+
+	Convey("假设 Latch 的 count 为 3", t, func() {
+		count := 3
+		l := NewLatch(count)
+		waiters := 5
+		var wg sync.WaitGroup
+		errs := make(chan error, waiters)
+		wg.Add(waiters)
+		for i := 0; i < waiters; i++ {
+			go func() {
+				errs <- l.Wait(context.TODO())
+				wg.Done()
+			}()
+		}
+
+		Convey("count 没有归零时，所有的 Wait 都在等待", func() {
+			l.CountDown()
+			l.CountDown()
+			So(l.Count(), ShouldEqual, 1)
+			So(len(errs), ShouldEqual, 0)
+
+			Convey("被取消的 Wait 返回 ctx.Err()，不影响 count", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				So(l.Wait(ctx), ShouldEqual, context.Canceled)
+				So(l.Count(), ShouldEqual, 1)
+			})
+		})
+
+		Convey("count 归零后，所有的 Wait 都返回 nil", func() {
+			for i := 0; i < count+1; i++ {
+				l.CountDown()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				So(err, ShouldBeNil)
+			}
+			So(l.Count(), ShouldEqual, 0)
+
+			Convey("随后的 Wait 也立即返回", func() {
+				So(l.Wait(context.TODO()), ShouldBeNil)
+			})
+		})
+
+		Reset(func() {
+			for l.Count() > 0 {
+				l.CountDown()
+			}
+			wg.Wait()
+		})
+	})
+}
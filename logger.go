@@ -0,0 +1,81 @@
+//go:build go1.21
+
+package barrier
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// LogLevels are the levels of the records logged by WithLogger.
+type LogLevels struct {
+	Arrive   slog.Level // every arrival
+	Trip     slog.Level // every successful trip
+	Break    slog.Level // every break
+	LongWait slog.Level // every wait longer than LongWaitThreshold
+	// LongWaitThreshold is the threshold of the long waits.
+	// Zero means not logging the long waits.
+	LongWaitThreshold time.Duration
+}
+
+// DefaultLogLevels logs arrivals in debug, trips in info, and breaks in
+// warn level, but not long waits.
+var DefaultLogLevels = LogLevels{
+	Arrive:   slog.LevelDebug,
+	Trip:     slog.LevelInfo,
+	Break:    slog.LevelWarn,
+	LongWait: slog.LevelWarn,
+}
+
+// WithLogger logs the lifecycle of the rounds to logger at levels, with
+// the attributes round and participants.
+// It is an Observer combined with the one set before by WithObserver.
+func WithLogger(logger *slog.Logger, levels LogLevels) Option {
+	return func(b *barrier) {
+		b.SetObserver(Observers(b.observer, &logObserver{
+			b:      b,
+			logger: logger,
+			levels: levels,
+		}))
+	}
+}
+
+// logObserver logs the notifications of b.
+type logObserver struct {
+	b      *barrier
+	logger *slog.Logger
+	levels LogLevels
+}
+
+func (o *logObserver) log(level slog.Level, msg string, round uint64, attrs ...slog.Attr) {
+	ctx := context.Background()
+	if !o.logger.Enabled(ctx, level) {
+		return
+	}
+	attrs = append(attrs,
+		slog.Uint64("round", round),
+		slog.Int("participants", o.b.Participants()))
+	o.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+func (o *logObserver) OnArrive(count int) {
+	o.log(o.levels.Arrive, "barrier arrived", o.b.Round()+1, slog.Int("count", count))
+}
+
+func (o *logObserver) OnTrip(round uint64) {
+	o.log(o.levels.Trip, "barrier tripped", round)
+}
+
+func (o *logObserver) OnBreak(cause error) {
+	o.log(o.levels.Break, "barrier broken", o.b.Round()+1, slog.Any("cause", cause))
+}
+
+func (o *logObserver) OnWaited(d time.Duration) {
+	if o.levels.LongWaitThreshold <= 0 || d < o.levels.LongWaitThreshold {
+		return
+	}
+	o.log(o.levels.LongWait, "barrier waited long", o.b.Round(), slog.Duration("waited", d))
+}
+
+func (o *logObserver) OnAction(time.Duration) {}
@@ -0,0 +1,71 @@
+//go:build go1.21
+
+package barrier
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) lines() []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return strings.Split(strings.TrimSpace(b.buf.String()), "\n")
+}
+
+func TestWithLogger(t *testing.T) {
+	Convey("假设 Barrier 有 2 个参与者，用 debug 级别的 logger 记录", t, func() {
+		out := &syncBuffer{}
+		logger := slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		levels := DefaultLogLevels
+		levels.LongWaitThreshold = 10 * time.Millisecond
+		o := &recorder{}
+		b := New(2, WithObserver(o), WithLogger(logger, levels))
+
+		Convey("完成一轮，记录 2 次到达，1 次完成和 1 次长时间的等待", func() {
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- b.Wait(context.TODO())
+			}()
+			time.Sleep(20 * time.Millisecond)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(<-errCh, ShouldBeNil)
+			lines := out.lines()
+			So(len(lines), ShouldEqual, 4)
+			So(lines[0], ShouldContainSubstring, `level=DEBUG msg="barrier arrived" count=1 round=1 participants=2`)
+			So(lines[1], ShouldContainSubstring, `level=DEBUG msg="barrier arrived" count=2 round=1 participants=2`)
+			So(lines[2], ShouldContainSubstring, `level=INFO msg="barrier tripped" round=1 participants=2`)
+			So(lines[3], ShouldContainSubstring, `level=WARN msg="barrier waited long" waited=`)
+
+			Convey("之前设置的 Observer 也会收到通知", func() {
+				So(len(o.arrivals), ShouldEqual, 2)
+				So(o.trips, ShouldResemble, []uint64{1})
+			})
+		})
+
+		Convey("Break 会记录 warn 级别的日志和原因", func() {
+			b.Break()
+			lines := out.lines()
+			So(lines[len(lines)-1], ShouldContainSubstring, `level=WARN msg="barrier broken" cause="`+ErrBroken.Error()+`" round=1`)
+		})
+	})
+}
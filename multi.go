@@ -0,0 +1,62 @@
+package barrier
+
+import "context"
+
+// WaitAll waits on all barriers concurrently, and returns the first error.
+// Once a Wait fails, the others are canceled by the context derived from ctx,
+// so that their rounds are broken instead of blocking WaitAll.
+// It returns nil at once if bs is empty.
+func WaitAll(ctx context.Context, bs ...Barrier) error {
+	if len(bs) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make(chan error, len(bs))
+	for _, b := range bs {
+		go func(b Barrier) {
+			errs <- b.Wait(ctx)
+		}(b)
+	}
+	var res error
+	for range bs {
+		if err := <-errs; err != nil && res == nil {
+			res = err
+			cancel()
+		}
+	}
+	return res
+}
+
+// WaitAny waits on all barriers concurrently, and returns the index of the
+// first one whose Wait returns, with its error.
+// Then the waits on the others are canceled, and leave their rounds
+// quietly like LeaveQuietly, so that the rounds keep waiting for the
+// other participants. A round may have tripped before the cancellation,
+// or can not be left, see LeaveQuietly, then the wait counts in it still.
+// The barriers implemented outside this package are broken by the
+// cancellation as usual.
+// It returns -1 and nil at once if bs is empty.
+func WaitAny(ctx context.Context, bs ...Barrier) (int, error) {
+	if len(bs) == 0 {
+		return -1, nil
+	}
+	ctx, cancel := context.WithCancel(context.WithValue(ctx, leavingQuietly{}, true))
+	defer cancel()
+	type result struct {
+		index int
+		err   error
+	}
+	results := make(chan result, len(bs))
+	for i, b := range bs {
+		go func(i int, b Barrier) {
+			results <- result{i, b.Wait(ctx)}
+		}(i, b)
+	}
+	first := <-results
+	cancel()
+	for range bs[1:] {
+		<-results
+	}
+	return first.index, first.err
+}
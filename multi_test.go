@@ -0,0 +1,80 @@
+package barrier
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWaitAll(t *testing.T) {
+	Convey("没有 Barrier 的时候，WaitAll 立即返回 nil", t, func() {
+		So(WaitAll(context.TODO()), ShouldBeNil)
+	})
+
+	Convey("假设有 3 个 Barrier，每个都有 2 个参与者", t, func() {
+		bs := []Barrier{New(2), New(2), New(2)}
+
+		Convey("其他参与者都 Wait 了，WaitAll 返回 nil", func() {
+			for _, b := range bs {
+				goWait(b)
+			}
+			So(WaitAll(context.TODO(), bs...), ShouldBeNil)
+			for _, b := range bs {
+				So(b.Round(), ShouldEqual, 1)
+			}
+		})
+
+		Convey("有一个 Barrier 被 Break 了，WaitAll 会返回 ErrBroken，不会卡住", func() {
+			bs[1].Break()
+			So(WaitAll(context.TODO(), bs...), shouldBeBrokenBy, ErrBroken)
+			So(bs[0].IsBroken(), ShouldBeTrue)
+			So(bs[2].IsBroken(), ShouldBeTrue)
+		})
+	})
+}
+
+func TestWaitAny(t *testing.T) {
+	Convey("没有 Barrier 的时候，WaitAny 立即返回 -1 和 nil", t, func() {
+		i, err := WaitAny(context.TODO())
+		So(i, ShouldEqual, -1)
+		So(err, ShouldBeNil)
+	})
+
+	Convey("假设有 3 个 Barrier，每个都有 2 个参与者", t, func() {
+		bs := []Barrier{New(2), New(2), New(2)}
+
+		Convey("第 2 个 Barrier 的另一个参与者 Wait 了，WaitAny 返回 1 和 nil", func() {
+			goWait(bs[1])
+			i, err := WaitAny(context.TODO(), bs...)
+			So(i, ShouldEqual, 1)
+			So(err, ShouldBeNil)
+			So(bs[1].Round(), ShouldEqual, 1)
+
+			Convey("其他 Barrier 没有被打破，也没有留下参与者", func() {
+				for _, b := range []Barrier{bs[0], bs[2]} {
+					So(b.IsBroken(), ShouldBeFalse)
+					So(b.NumberWaiting(), ShouldEqual, 0)
+				}
+			})
+		})
+
+		Convey("有一个 Barrier 被 Break 了，WaitAny 返回它的 index 和 ErrBroken", func() {
+			bs[2].Break()
+			i, err := WaitAny(context.TODO(), bs...)
+			So(i, ShouldEqual, 2)
+			So(err, shouldBeBrokenBy, ErrBroken)
+			So(bs[0].IsBroken(), ShouldBeFalse)
+		})
+
+		Convey("ctx 被取消时，WaitAny 返回 ctx.Err()，不会打破任何 Barrier", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := WaitAny(ctx, bs...)
+			So(err, ShouldEqual, context.Canceled)
+			for _, b := range bs {
+				So(b.IsBroken(), ShouldBeFalse)
+			}
+		})
+	})
+}
@@ -0,0 +1,199 @@
+package barrier
+
+import (
+	"sync"
+	"time"
+)
+
+// Option configures a Barrier created by New.
+type Option func(*barrier)
+
+// NewWithOptions is New, it makes the options explicit at the call site.
+func NewWithOptions(participants int, opts ...Option) Barrier {
+	return New(participants, opts...)
+}
+
+// WithAction sets the action like SetActionE.
+func WithAction(action func() error) Option {
+	return func(b *barrier) {
+		b.SetActionE(action)
+	}
+}
+
+// WithOnBroken sets the hook like OnBroken.
+func WithOnBroken(hook func(cause error)) Option {
+	return func(b *barrier) {
+		b.OnBroken(hook)
+	}
+}
+
+// WithOnRelease adds the hook like OnRelease.
+func WithOnRelease(hook func(RoundInfo)) Option {
+	return func(b *barrier) {
+		b.OnRelease(hook)
+	}
+}
+
+// WithObserver sets the observer like SetObserver.
+func WithObserver(o Observer) Option {
+	return func(b *barrier) {
+		b.SetObserver(o)
+	}
+}
+
+// WithName names the barrier, which is shown by String.
+func WithName(name string) Option {
+	return func(b *barrier) {
+		b.name = name
+	}
+}
+
+// WithRoundTimeout sets the timeout of the rounds like SetRoundTimeout.
+func WithRoundTimeout(d time.Duration) Option {
+	return func(b *barrier) {
+		b.roundTimeout = d
+	}
+}
+
+// WithAutoRecover makes the barrier start a new round, if a broken round
+// has not been reset by its parties within timeout, because some of them
+// never arrive. The goroutines waiting in the broken round have returned
+// ErrBroken, and the late ones arrive in the new round.
+// timeout <= 0 means waiting for all the parties, which is the default.
+func WithAutoRecover(timeout time.Duration) Option {
+	return func(b *barrier) {
+		b.recoverAfter = timeout
+	}
+}
+
+// WithStickyBroken makes the barrier keep broken once a round is broken,
+// like java.util.concurrent.CyclicBarrier. All the later arrivals fail
+// fast with the error of the broken round, until Reset is called.
+// By default, the barrier starts a new round, after all the participants
+// of the broken round have arrived.
+func WithStickyBroken() Option {
+	return func(b *barrier) {
+		b.sticky = true
+	}
+}
+
+// WithoutOverflowPanic makes the goroutine arriving more than participants
+// in a round returns ErrTooManyParties instead of panic.
+func WithoutOverflowPanic() Option {
+	return func(b *barrier) {
+		b.panicOnOverflow = false
+	}
+}
+
+// CancelPolicy decides what happens to the round, when the context of
+// a waiting goroutine is done.
+type CancelPolicy int
+
+const (
+	// BreakRound breaks the round, so that all its participants return
+	// the error of the context. It is the default.
+	BreakRound CancelPolicy = iota
+	// LeaveQuietly withdraws the arrival of the goroutine, which returns
+	// the error of the context, and the round keeps waiting for the others.
+	// The round is broken still, if the goroutine has contributed a value
+	// to it, or it is completing.
+	LeaveQuietly
+)
+
+// WithCancelPolicy sets the CancelPolicy of the barrier.
+func WithCancelPolicy(policy CancelPolicy) Option {
+	return func(b *barrier) {
+		b.cancelPolicy = policy
+	}
+}
+
+// WaitStrategy decides how the waiting goroutines park until their round
+// is released.
+type WaitStrategy int
+
+const (
+	// ChannelBased parks the goroutines on a channel allocated per round,
+	// which is closed to release them. It is the default.
+	ChannelBased WaitStrategy = iota
+	// CondBased parks the goroutines on a sync.Cond, so that the rounds
+	// are allocation free. Only the goroutines waiting without a timeout
+	// and with a context never done, like context.Background, park on it,
+	// the others still park on a channel, which is allocated on demand.
+	CondBased
+)
+
+// WithWaitStrategy sets the WaitStrategy of the barrier.
+func WithWaitStrategy(strategy WaitStrategy) Option {
+	return func(b *barrier) {
+		b.parked = nil
+		if strategy == CondBased {
+			b.parked = sync.NewCond(&b.parking)
+		}
+	}
+}
+
+// WithSpin makes the waiting goroutines spin, yielding the processor at
+// most maxSpins times, before parking until their round is released.
+// It saves the cost of parking and waking, when the participants arrive
+// within microseconds of each other, at the cost of CPU time.
+// The context and the timeout of the goroutine are checked after spinning.
+func WithSpin(maxSpins int) Option {
+	return func(b *barrier) {
+		b.spins = maxSpins
+	}
+}
+
+// WithOverflowError is WithoutOverflowPanic, the extra arrival returns
+// ErrTooManyWaiters.
+func WithOverflowError() Option {
+	return WithoutOverflowPanic()
+}
+
+// WithOverflowQueue makes the goroutine arriving more than participants
+// in a round wait for the next round, and arrive it once the round
+// completes, instead of panic. It is useful when the arrivals are not
+// interleaved with the rounds exactly, like producers and consumers.
+func WithOverflowQueue() Option {
+	return func(b *barrier) {
+		b.queueOverflow = true
+	}
+}
+
+// WithStaggeredWakeup makes the barrier release its waiting goroutines
+// batch by batch, at most batch goroutines at a time, instead of waking
+// all of them at once. The next batch is woken after the goroutines of the
+// previous one have been scheduled. It smooths the load of the scheduler
+// when there are a lot of participants, at the cost of a longer fan-out.
+// batch <= 0 means releasing all of them at once, which is the default.
+func WithStaggeredWakeup(batch int) Option {
+	return func(b *barrier) {
+		b.batch = batch
+	}
+}
+
+// Implementation decides how the barrier created by New is implemented.
+type Implementation int
+
+const (
+	// RoundBased begins a new round for every cycle of the barrier, which
+	// supports all the methods and options. It is the default.
+	RoundBased Implementation = iota
+	// SenseReversing is the classic sense-reversing barrier. A generation
+	// counts the arrivals in a single word, and its waiting goroutines
+	// wait for the sense, the lowest bit of the generation, to reverse.
+	// Nothing is allocated per cycle, but the channels of the goroutines
+	// waiting with a timeout or a context which can be done.
+	// It supports the methods of Barrier, which do not need to know the
+	// arrivals, like Wait, WaitTimeout, Break, Reset and Close, and the
+	// others return ErrNotSupported. New panics with the options changing
+	// how a round completes, like WithRoundTimeout, WithStickyBroken,
+	// WithCancelPolicy, WithStaggeredWakeup, WithWatchdog and WithSharding.
+	SenseReversing
+)
+
+// WithImplementation sets the Implementation of the barrier.
+func WithImplementation(impl Implementation) Option {
+	return func(b *barrier) {
+		b.implementation = impl
+	}
+}
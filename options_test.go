@@ -0,0 +1,451 @@
+package barrier
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStaggeredWakeup(t *testing.T) {
+	Convey("如果 Barrier 分批唤醒等待的 goroutine", t, func() {
+		participants := 10
+		b := New(participants, WithStaggeredWakeup(3))
+
+		Convey("所有的参与者最终都会被放行", func() {
+			for r := 0; r < 3; r++ {
+				errs := make(chan error, participants)
+				var wg sync.WaitGroup
+				wg.Add(participants)
+				for i := 0; i < participants; i++ {
+					go func() {
+						errs <- b.Wait(context.TODO())
+						wg.Done()
+					}()
+				}
+				wg.Wait()
+				close(errs)
+				for err := range errs {
+					So(err, ShouldBeNil)
+				}
+				So(count(b), ShouldEqual, 0)
+			}
+			So(b.(FanoutReporter).LastReleaseFanoutDuration(), ShouldBeGreaterThan, 0)
+		})
+
+		Convey("batch 不少于参与者的时候，等同于一次性唤醒", func() {
+			b := New(3, WithStaggeredWakeup(5))
+			So(b.(*barrier).round.batches, ShouldBeNil)
+			goWait(b)
+			goWait(b)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+		})
+
+		Convey("前一批的 goroutine 被调度之后，才会唤醒后一批", func() {
+			r := b.(*barrier).newRound()
+			first, second := &r.batches[0], &r.batches[1]
+			So(first.block(), ShouldBeTrue)
+			released := make(chan struct{})
+			go func() {
+				b.(*barrier).release(r)
+				close(released)
+			}()
+			<-first.released
+			select {
+			case <-second.released:
+				t.Fatal("the second batch is released before the first one is scheduled")
+			case <-time.After(10 * time.Millisecond):
+			}
+			first.resume()
+			<-released
+			So(second.block(), ShouldBeFalse)
+		})
+	})
+}
+
+func TestNewWithOptions(t *testing.T) {
+	Convey("用 WithAction 和 WithOnBroken 新建一个 Barrier", t, func() {
+		errAction := errors.New("action failed")
+		var causes []error
+		b := NewWithOptions(2,
+			WithAction(func() error {
+				return errAction
+			}),
+			WithOnBroken(func(cause error) {
+				causes = append(causes, cause)
+			}),
+		)
+
+		Convey("两个选项都会生效", func() {
+			goWait(b)
+			So(b.Wait(context.TODO()), shouldBeBrokenBy, errAction)
+			So(causes, ShouldResemble, []error{errAction})
+		})
+	})
+
+	Convey("用 WithoutOverflowPanic 新建一个 Barrier", t, func() {
+		noSend := make(chan struct{})
+		b := NewWithOptions(1, WithoutOverflowPanic(), WithAction(func() error {
+			<-noSend
+			return nil
+		}))
+		goWait(b)
+		for b.NumberWaiting() < 1 {
+			runtime.Gosched()
+		}
+
+		Convey("参与者太多的时候，不会 panic", func() {
+			So(b.Wait(context.TODO()), ShouldEqual, ErrTooManyParties)
+		})
+	})
+	Convey("用 WithRoundTimeout 新建一个 Barrier，每一轮要在 10ms 内完成", t, func() {
+		var executed int32
+		b := New(2, WithRoundTimeout(10*time.Millisecond), WithAction(func() error {
+			atomic.AddInt32(&executed, 1)
+			return nil
+		}))
+
+		Convey("只有 1 个参与者到达，这一轮会超时被 break，不会执行 action", func() {
+			So(b.Wait(context.TODO()), shouldBeBrokenBy, ErrTimeout)
+			So(atomic.LoadInt32(&executed), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestAutoRecover(t *testing.T) {
+	Convey("如果 Barrier 有 2 个参与者，并且会自动恢复被 break 的轮次", t, func() {
+		timeout := 20 * time.Millisecond
+		b := New(2, WithAutoRecover(timeout))
+
+		Convey("只有 1 个参与者到达，并且超时 break 了这一轮", func() {
+			So(errors.Is(b.WaitTimeout(time.Millisecond), ErrTimeout), ShouldBeTrue)
+			So(b.IsBroken(), ShouldBeTrue)
+
+			Convey("超时以后，会开始新的一轮，迟到的参与者可以正常完成", func() {
+				time.Sleep(timeout * 2)
+				So(b.IsBroken(), ShouldBeFalse)
+				So(b.Round(), ShouldEqual, 1)
+				goWait(b)
+				So(b.Wait(context.TODO()), ShouldBeNil)
+				So(b.Stats().CompletedRounds, ShouldEqual, 1)
+			})
+
+			Convey("这一轮被正常重置以后，不会再自动重置", func() {
+				So(b.Wait(context.TODO()), ShouldNotBeNil)
+				So(b.Round(), ShouldEqual, 1)
+				time.Sleep(timeout * 2)
+				So(b.Round(), ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestStickyBroken(t *testing.T) {
+	Convey("如果 Barrier 有 2 个参与者，并且被 break 以后会一直保持 broken", t, func() {
+		b := New(2, WithStickyBroken())
+		goWait(b)
+		b.Break()
+
+		Convey("这一轮完成以后，Barrier 依然是 broken，Wait 会立即返回 ErrBroken", func() {
+			for b.Round() < 1 {
+				runtime.Gosched()
+			}
+			So(b.IsBroken(), ShouldBeTrue)
+			So(b.Wait(context.TODO()), shouldBeBrokenBy, ErrBroken)
+			completed, err := b.TryWait()
+			So(completed, ShouldBeFalse)
+			So(err, shouldBeBrokenBy, ErrBroken)
+			So(b.NumberWaiting(), ShouldEqual, 0)
+
+			Convey("Reset 以后，Barrier 又可以正常使用了", func() {
+				b.Reset()
+				So(b.IsBroken(), ShouldBeFalse)
+				goWait(b)
+				So(b.Wait(context.TODO()), ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("如果 Barrier 的一个参与者超时了，但其他参与者没有到达", t, func() {
+		b := New(2, WithStickyBroken())
+		err := b.WaitTimeout(time.Millisecond)
+		So(errors.Is(err, ErrTimeout), ShouldBeTrue)
+
+		Convey("后到的参与者会立即得到同样的 error", func() {
+			So(b.Wait(context.TODO()), ShouldEqual, err)
+			b.Reset()
+			So(b.IsDrained(), ShouldBeTrue)
+		})
+	})
+}
+
+func TestOverflowError(t *testing.T) {
+	noSend := make(chan struct{})
+	Convey("如果 Barrier 在参与者太多的时候返回 error", t, func() {
+		b := New(1, WithOverflowError()).SetAction(func() {
+			<-noSend
+		})
+		goWait(b)
+		for b.NumberWaiting() < 1 {
+			runtime.Gosched()
+		}
+
+		Convey("多出来的 Wait 会返回 ErrTooManyWaiters，而不会 panic", func() {
+			So(b.Wait(context.TODO()), ShouldEqual, ErrTooManyWaiters)
+			So(b.NumberWaiting(), ShouldEqual, 1)
+		})
+	})
+}
+
+func TestOverflowQueue(t *testing.T) {
+	Convey("如果 Barrier 有 2 个参与者，并且多出来的参与者会进入下一轮", t, func() {
+		participants := 2
+		b := New(participants, WithOverflowQueue())
+
+		Convey("6 个参与者同时 Wait，会完成 3 轮，不会 panic", func() {
+			n := participants * 3
+			errs := make(chan error, n)
+			var wg sync.WaitGroup
+			wg.Add(n)
+			for i := 0; i < n; i++ {
+				go func() {
+					errs <- b.Wait(context.TODO())
+					wg.Done()
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				So(err, ShouldBeNil)
+			}
+			So(b.Round(), ShouldEqual, 3)
+			So(b.IsDrained(), ShouldBeTrue)
+		})
+
+		Convey("Action 执行的时候到达的参与者，会进入下一轮", func() {
+			entered, leave := make(chan struct{}), make(chan struct{})
+			b.SetAction(func() {
+				if b.Round() == 0 {
+					close(entered)
+					<-leave
+				}
+			})
+			goWait(b)
+			goWait(b)
+			<-entered
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- b.Wait(context.TODO())
+			}()
+			close(leave)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(<-errCh, ShouldBeNil)
+			So(b.Round(), ShouldEqual, 2)
+		})
+	})
+}
+
+func TestCancelPolicy(t *testing.T) {
+	Convey("如果 Barrier 有 3 个参与者，context 被取消的参与者会悄悄离开", t, func() {
+		b := New(3, WithCancelPolicy(LeaveQuietly))
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- b.Wait(context.TODO())
+		}()
+		for b.NumberWaiting() < 1 {
+			runtime.Gosched()
+		}
+
+		Convey("等待中的参与者被取消以后，不会 break 这一轮", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			leftCh := make(chan error, 1)
+			go func() {
+				leftCh <- b.Wait(ctx)
+			}()
+			for b.NumberWaiting() < 2 {
+				runtime.Gosched()
+			}
+			cancel()
+			So(<-leftCh, ShouldEqual, context.Canceled)
+			So(b.IsBroken(), ShouldBeFalse)
+			So(b.NumberWaiting(), ShouldEqual, 1)
+
+			Convey("离开的参与者可以用同样的名字再次到达", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				leftCh := make(chan error, 1)
+				go func() {
+					leftCh <- b.WaitAs(ctx, "shard-7-writer")
+				}()
+				for b.NumberWaiting() < 2 {
+					runtime.Gosched()
+				}
+				cancel()
+				So(<-leftCh, ShouldEqual, context.Canceled)
+				goWait(b)
+				So(b.WaitAs(context.TODO(), "shard-7-writer"), ShouldBeNil)
+				So(<-errCh, ShouldBeNil)
+			})
+
+			Convey("其他的参与者依然可以完成这一轮", func() {
+				goWait(b)
+				So(b.Wait(context.TODO()), ShouldBeNil)
+				So(<-errCh, ShouldBeNil)
+				So(b.Stats().CompletedRounds, ShouldEqual, 1)
+			})
+		})
+
+		Convey("最后到达的参与者的 context 已经被取消了，它也会离开", func() {
+			goWait(b)
+			for b.NumberWaiting() < 2 {
+				runtime.Gosched()
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			So(b.Wait(ctx), ShouldEqual, context.Canceled)
+			So(b.IsBroken(), ShouldBeFalse)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(<-errCh, ShouldBeNil)
+		})
+	})
+}
+
+func TestWaitStrategy(t *testing.T) {
+	Convey("如果 Barrier 的参与者在 sync.Cond 上等待", t, func() {
+		participants := 4
+		b := New(participants, WithWaitStrategy(CondBased))
+
+		Convey("多轮以后，所有的参与者都会被放行，包括用可以取消的 context 等待的", func() {
+			for r := 0; r < 3; r++ {
+				errs := make(chan error, participants)
+				ctx, cancel := context.WithCancel(context.Background())
+				for i := 0; i < participants; i++ {
+					ctx := ctx
+					if i%2 == 0 {
+						ctx = context.Background()
+					}
+					go func() {
+						errs <- b.Wait(ctx)
+					}()
+				}
+				for i := 0; i < participants; i++ {
+					So(<-errs, ShouldBeNil)
+				}
+				cancel()
+			}
+			So(b.Round(), ShouldEqual, 3)
+		})
+
+		Convey("一轮接着一轮，不会分配内存", func() {
+			b := New(1, WithWaitStrategy(CondBased)).SetAction(func() {})
+			allocs := testing.AllocsPerRun(100, func() {
+				b.Wait(context.Background())
+			})
+			So(allocs, ShouldEqual, 0)
+		})
+
+		Convey("Break 以后，等待中的参与者都会返回 ErrBroken", func() {
+			errs := make(chan error, 2)
+			for i := 0; i < 2; i++ {
+				go func() {
+					errs <- b.Wait(context.Background())
+				}()
+			}
+			for b.NumberWaiting() < 2 {
+				runtime.Gosched()
+			}
+			b.Break()
+			So(<-errs, shouldBeBrokenBy, ErrBroken)
+			So(<-errs, shouldBeBrokenBy, ErrBroken)
+		})
+	})
+}
+
+func TestSpin(t *testing.T) {
+	Convey("如果 Barrier 的参与者在等待前会自旋", t, func() {
+		participants := 4
+		b := New(participants, WithSpin(100))
+
+		Convey("参与者到达得快，或者慢，都会被放行", func() {
+			for r := 0; r < 10; r++ {
+				errs := make(chan error, participants)
+				for i := 0; i < participants; i++ {
+					go func() {
+						errs <- b.Wait(context.TODO())
+					}()
+				}
+				if r%2 == 0 {
+					time.Sleep(time.Millisecond)
+				}
+				for i := 0; i < participants; i++ {
+					So(<-errs, ShouldBeNil)
+				}
+			}
+			So(b.Round(), ShouldEqual, 10)
+		})
+
+		Convey("Break 以后，自旋中的参与者会返回 ErrBroken", func() {
+			b := New(2, WithSpin(1<<30))
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- b.Wait(context.TODO())
+			}()
+			for b.NumberWaiting() < 1 {
+				runtime.Gosched()
+			}
+			b.Break()
+			So(<-errCh, shouldBeBrokenBy, ErrBroken)
+		})
+	})
+}
+
+func TestHeavyCycling(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"ChannelBased":        nil,
+		"CondBased":           {WithWaitStrategy(CondBased)},
+		"CondBased+Spin":      {WithWaitStrategy(CondBased), WithSpin(10)},
+		"StaggeredWakeup":     {WithStaggeredWakeup(3)},
+		"CondBased+Timeout":   {WithWaitStrategy(CondBased), WithRoundTimeout(time.Minute)},
+		"SenseReversing":      {WithImplementation(SenseReversing)},
+		"SenseReversing+Spin": {WithImplementation(SenseReversing), WithSpin(10)},
+	} {
+		Convey("如果 8 个参与者用 "+name+" 的 Barrier 连续等待 2000 轮", t, func() {
+			participants, rounds := 8, 2000
+			var executed int64
+			b := New(participants, opts...).SetAction(func() {
+				atomic.AddInt64(&executed, 1)
+			})
+
+			Convey("每一轮都会正常完成，Action 每一轮执行一次", func() {
+				var failed int64
+				var wg sync.WaitGroup
+				wg.Add(participants)
+				for i := 0; i < participants; i++ {
+					go func(i int) {
+						defer wg.Done()
+						for r := 0; r < rounds; r++ {
+							ctx, cancel := context.Background(), context.CancelFunc(func() {})
+							if i == 0 {
+								// 有的参与者用可以取消的 context 等待
+								ctx, cancel = context.WithCancel(ctx)
+							}
+							if b.Wait(ctx) != nil {
+								atomic.AddInt64(&failed, 1)
+							}
+							cancel()
+						}
+					}(i)
+				}
+				wg.Wait()
+				So(atomic.LoadInt64(&failed), ShouldEqual, 0)
+				So(atomic.LoadInt64(&executed), ShouldEqual, rounds)
+				So(b.Round(), ShouldEqual, rounds)
+				So(b.IsDrained(), ShouldBeTrue)
+			})
+		})
+	}
+}
@@ -0,0 +1,21 @@
+module github.com/aQuaYi/barrier/otelbarrier
+
+go 1.18
+
+require (
+	github.com/aQuaYi/barrier v0.0.0-20261017072745-c5729500169b
+	github.com/smartystreets/goconvey v0.0.0-20190731233626-505e41936337
+	go.opentelemetry.io/otel v1.11.1
+	go.opentelemetry.io/otel/sdk v1.11.1
+	go.opentelemetry.io/otel/trace v1.11.1
+)
+
+require (
+	github.com/go-logr/logr v1.2.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/gopherjs/gopherjs v0.0.0-20190915194858-d3ddacdb130f // indirect
+	github.com/jtolds/gls v4.20.0+incompatible // indirect
+	github.com/marusama/cyclicbarrier v0.0.0-20181027101648-08d457ab265c // indirect
+	github.com/smartystreets/assertions v1.0.1 // indirect
+	golang.org/x/sys v0.0.0-20220919091848-fb04ddd9f9c8 // indirect
+)
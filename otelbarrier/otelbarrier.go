@@ -0,0 +1,265 @@
+// Package otelbarrier traces a Barrier with OpenTelemetry.
+package otelbarrier
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aQuaYi/barrier"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Barrier is a barrier.Barrier traced by a tracer.
+// Every round has a span named "barrier.round", which begins with the
+// first arrival, and ends once the round returns.
+// Every Wait, and every other call waiting in the barrier, like WaitN,
+// BreakCtx and AwaitRelease, has a span named "barrier.wait", whose
+// duration is the time spent in the barrier, and which links to the span
+// of its round.
+// Every action set through Barrier has a span named "barrier.action",
+// which is a child of the span of its round.
+// The round of a Wait is told by the completed rounds at its arrival, so
+// a Wait arriving while the previous round is completing may be linked
+// to the previous round.
+type Barrier struct {
+	barrier.Barrier
+	tracer trace.Tracer
+	lock   sync.Mutex
+	rounds map[uint64]*round // by the number of completed rounds before it
+}
+
+// round is the span of a round.
+type round struct {
+	span    trace.Span
+	waiters int
+}
+
+// Wrap wraps b to be traced by tracer.
+func Wrap(b barrier.Barrier, tracer trace.Tracer) *Barrier {
+	return &Barrier{
+		Barrier: b,
+		tracer:  tracer,
+		rounds:  make(map[uint64]*round),
+	}
+}
+
+// Wait is barrier.Barrier.Wait traced by the span "barrier.wait".
+func (w *Barrier) Wait(ctx context.Context) error {
+	return w.wait(ctx, w.Barrier.Wait)
+}
+
+// WaitAs is barrier.Barrier.WaitAs traced by the span "barrier.wait".
+func (w *Barrier) WaitAs(ctx context.Context, name string) error {
+	return w.wait(ctx, func(ctx context.Context) error {
+		return w.Barrier.WaitAs(ctx, name)
+	})
+}
+
+// WaitExchange is barrier.Barrier.WaitExchange traced by the span "barrier.wait".
+func (w *Barrier) WaitExchange(ctx context.Context, mine interface{}) (all []interface{}, err error) {
+	err = w.wait(ctx, func(ctx context.Context) error {
+		all, err = w.Barrier.WaitExchange(ctx, mine)
+		return err
+	})
+	return all, err
+}
+
+// WaitAction is barrier.Barrier.WaitAction traced by the span "barrier.wait".
+func (w *Barrier) WaitAction(ctx context.Context, action func() error) error {
+	return w.wait(ctx, func(ctx context.Context) error {
+		return w.Barrier.WaitAction(ctx, action)
+	})
+}
+
+// WaitIndexed is barrier.Barrier.WaitIndexed traced by the span "barrier.wait".
+func (w *Barrier) WaitIndexed(ctx context.Context) (index int, err error) {
+	err = w.wait(ctx, func(ctx context.Context) error {
+		index, err = w.Barrier.WaitIndexed(ctx)
+		return err
+	})
+	return index, err
+}
+
+// WaitIndex is barrier.Barrier.WaitIndex traced by the span "barrier.wait".
+func (w *Barrier) WaitIndex(ctx context.Context) (index int, err error) {
+	err = w.wait(ctx, func(ctx context.Context) error {
+		index, err = w.Barrier.WaitIndex(ctx)
+		return err
+	})
+	return index, err
+}
+
+// WaitN is barrier.Barrier.WaitN traced by the span "barrier.wait".
+func (w *Barrier) WaitN(ctx context.Context, k int) error {
+	return w.wait(ctx, func(ctx context.Context) error {
+		return w.Barrier.WaitN(ctx, k)
+	})
+}
+
+// WaitWeighted is barrier.Barrier.WaitWeighted traced by the span "barrier.wait".
+func (w *Barrier) WaitWeighted(ctx context.Context, n int) error {
+	return w.wait(ctx, func(ctx context.Context) error {
+		return w.Barrier.WaitWeighted(ctx, n)
+	})
+}
+
+// WaitTimeout is barrier.Barrier.WaitTimeout traced by the span "barrier.wait".
+func (w *Barrier) WaitTimeout(d time.Duration) error {
+	return w.wait(context.Background(), func(context.Context) error {
+		return w.Barrier.WaitTimeout(d)
+	})
+}
+
+// BreakCtx is barrier.Barrier.BreakCtx traced by the span "barrier.wait".
+func (w *Barrier) BreakCtx(ctx context.Context) error {
+	return w.wait(ctx, w.Barrier.BreakCtx)
+}
+
+// AwaitRelease is barrier.Barrier.AwaitRelease traced by the span
+// "barrier.wait", which links to the round of the time it is called,
+// instead of the time of Arrive.
+func (w *Barrier) AwaitRelease(ctx context.Context, token int) error {
+	return w.wait(ctx, func(ctx context.Context) error {
+		return w.Barrier.AwaitRelease(ctx, token)
+	})
+}
+
+// wait runs f in the span "barrier.wait", which links to the span of the
+// round the caller arrives.
+func (w *Barrier) wait(ctx context.Context, f func(context.Context) error) error {
+	n, r := w.join()
+	ctx, span := w.tracer.Start(ctx, "barrier.wait",
+		trace.WithLinks(trace.Link{SpanContext: r.span.SpanContext()}),
+		trace.WithAttributes(attribute.Int64("barrier.round", int64(n+1))))
+	err := f(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	w.leave(n, r)
+	return err
+}
+
+// join returns the round which the caller arrives, and its number of
+// completed rounds before it.
+func (w *Barrier) join() (uint64, *round) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	n := w.Round()
+	r, ok := w.rounds[n]
+	if !ok {
+		_, span := w.tracer.Start(context.Background(), "barrier.round",
+			trace.WithNewRoot(),
+			trace.WithAttributes(
+				attribute.Int64("barrier.round", int64(n+1)),
+				attribute.Int("barrier.participants", w.Participants())))
+		r = &round{span: span}
+		w.rounds[n] = r
+	}
+	r.waiters++
+	return n, r
+}
+
+// leave ends the span of the round r numbered n, once the first of its
+// waiters returns, and forgets r once all of them return.
+func (w *Barrier) leave(n uint64, r *round) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	r.span.End()
+	r.waiters--
+	if r.waiters == 0 {
+		delete(w.rounds, n)
+	}
+}
+
+// trace runs action in the span "barrier.action", the child of the span
+// of the current round.
+func (w *Barrier) trace(ctx context.Context, action func(context.Context) error) error {
+	w.lock.Lock()
+	if r, ok := w.rounds[w.Round()]; ok {
+		ctx = trace.ContextWithSpan(ctx, r.span)
+	}
+	w.lock.Unlock()
+	ctx, span := w.tracer.Start(ctx, "barrier.action")
+	defer span.End()
+	err := action(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// SetAction is barrier.Barrier.SetAction with the action traced.
+func (w *Barrier) SetAction(action func()) barrier.Barrier {
+	if action == nil {
+		return w.SetActionCtx(nil)
+	}
+	return w.SetActionCtx(func(context.Context) error {
+		action()
+		return nil
+	})
+}
+
+// SetActionE is barrier.Barrier.SetActionE with the action traced.
+func (w *Barrier) SetActionE(action func() error) barrier.Barrier {
+	if action == nil {
+		return w.SetActionCtx(nil)
+	}
+	return w.SetActionCtx(func(context.Context) error {
+		return action()
+	})
+}
+
+// SetActionCtx is barrier.Barrier.SetActionCtx with the action traced.
+func (w *Barrier) SetActionCtx(action func(context.Context) error) barrier.Barrier {
+	if action == nil {
+		w.Barrier.SetActionCtx(nil)
+		return w
+	}
+	w.Barrier.SetActionCtx(func(ctx context.Context) error {
+		return w.trace(ctx, action)
+	})
+	return w
+}
+
+// AddAction is barrier.Barrier.AddAction with the action traced.
+func (w *Barrier) AddAction(action func() error) barrier.Barrier {
+	if action == nil {
+		return w
+	}
+	w.Barrier.AddAction(func() error {
+		return w.trace(context.Background(), func(context.Context) error {
+			return action()
+		})
+	})
+	return w
+}
+
+// SetRoundTimeout is barrier.Barrier.SetRoundTimeout returning w.
+func (w *Barrier) SetRoundTimeout(d time.Duration) barrier.Barrier {
+	w.Barrier.SetRoundTimeout(d)
+	return w
+}
+
+// OnBroken is barrier.Barrier.OnBroken returning w.
+func (w *Barrier) OnBroken(hook func(cause error)) barrier.Barrier {
+	w.Barrier.OnBroken(hook)
+	return w
+}
+
+// OnRelease is barrier.Barrier.OnRelease returning w.
+func (w *Barrier) OnRelease(hook func(barrier.RoundInfo)) barrier.Barrier {
+	w.Barrier.OnRelease(hook)
+	return w
+}
+
+// SetObserver is barrier.Barrier.SetObserver returning w.
+func (w *Barrier) SetObserver(o barrier.Observer) barrier.Barrier {
+	w.Barrier.SetObserver(o)
+	return w
+}
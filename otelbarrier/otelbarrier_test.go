@@ -0,0 +1,104 @@
+package otelbarrier
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aQuaYi/barrier"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestWrap(t *testing.T) {
+	Convey("假设用 tracer 包装了有 2 个参与者的 Barrier", t, func() {
+		recorder := tracetest.NewSpanRecorder()
+		tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+		b := Wrap(barrier.New(2), tracer)
+		errAction := errors.New("action failed")
+		fail := false
+		b.SetActionE(func() error {
+			if fail {
+				return errAction
+			}
+			return nil
+		})
+		spans := func(name string) (res []sdktrace.ReadOnlySpan) {
+			for _, s := range recorder.Ended() {
+				if s.Name() == name {
+					res = append(res, s)
+				}
+			}
+			return
+		}
+		wait := func() (err1, err2 error) {
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- b.Wait(context.TODO())
+			}()
+			err1 = b.Wait(context.TODO())
+			return err1, <-errCh
+		}
+
+		Convey("完成一轮，有 1 个 round span，2 个链接到它的 wait span，和 1 个 action 子 span", func() {
+			err1, err2 := wait()
+			So(err1, ShouldBeNil)
+			So(err2, ShouldBeNil)
+			rounds := spans("barrier.round")
+			So(len(rounds), ShouldEqual, 1)
+			round := rounds[0].SpanContext()
+			waits := spans("barrier.wait")
+			So(len(waits), ShouldEqual, 2)
+			for _, s := range waits {
+				So(len(s.Links()), ShouldEqual, 1)
+				So(s.Links()[0].SpanContext.SpanID(), ShouldEqual, round.SpanID())
+			}
+			actions := spans("barrier.action")
+			So(len(actions), ShouldEqual, 1)
+			So(actions[0].Parent().SpanID(), ShouldEqual, round.SpanID())
+
+			Convey("下一轮有一个新的 round span", func() {
+				wait()
+				rounds := spans("barrier.round")
+				So(len(rounds), ShouldEqual, 2)
+				So(rounds[1].SpanContext().SpanID(), ShouldNotEqual, round.SpanID())
+			})
+		})
+
+		Convey("action 失败时，action span 和 wait span 都记录了错误", func() {
+			fail = true
+			err1, err2 := wait()
+			So(errors.Is(err1, errAction), ShouldBeTrue)
+			So(errors.Is(err2, errAction), ShouldBeTrue)
+			So(spans("barrier.action")[0].Status().Code, ShouldEqual, codes.Error)
+			for _, s := range spans("barrier.wait") {
+				So(s.Status().Code, ShouldEqual, codes.Error)
+			}
+		})
+
+		Convey("WaitN、WaitTimeout 等变体也有链接到 round span 的 wait span", func() {
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- b.WaitN(context.TODO(), 2)
+			}()
+			So(b.WaitTimeout(time.Minute), ShouldBeNil)
+			So(<-errCh, ShouldBeNil)
+			round := spans("barrier.round")[0].SpanContext()
+			waits := spans("barrier.wait")
+			So(len(waits), ShouldEqual, 2)
+			for _, s := range waits {
+				So(s.Links()[0].SpanContext.SpanID(), ShouldEqual, round.SpanID())
+			}
+		})
+
+		Convey("链式调用返回的还是包装后的 Barrier", func() {
+			So(b.OnBroken(func(error) {}), ShouldEqual, b)
+			So(b.OnRelease(func(barrier.RoundInfo) {}), ShouldEqual, b)
+			So(b.SetObserver(nil), ShouldEqual, b)
+			So(b.SetRoundTimeout(0), ShouldEqual, b)
+		})
+	})
+}
@@ -0,0 +1,127 @@
+package barrier
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// ErrDeregistered will be returned by a Participant after it deregisters.
+var ErrDeregistered = errors.New("barrier participant is deregistered")
+
+// Participant is a party registered to a Barrier by Register.
+type Participant interface {
+	// Wait is Barrier.Wait.
+	Wait(ctx context.Context) error
+
+	// Arrive is TryArriver.TryArrive, but does not report the result of the
+	// round, even if it trips the round.
+	Arrive() error
+
+	// ArriveAndWait is Wait.
+	ArriveAndWait(ctx context.Context) error
+
+	// ArriveAndDeregister arrives like Arrive, and then deregisters like
+	// Deregister. It is for the party which has done its job forever.
+	ArriveAndDeregister() error
+
+	// Deregister removes the party from the barrier like Barrier.Deregister.
+	// The participant can not be used any more, the later calls return
+	// ErrDeregistered.
+	Deregister() error
+}
+
+// participant implements Participant interface
+type participant struct {
+	b            *barrier
+	name         string // set by RegisterAs
+	err          error  // why it is not registered, returned by all its calls
+	deregistered int32  // atomic
+}
+
+func (b *barrier) Register() Participant {
+	return b.RegisterAs("")
+}
+
+func (b *barrier) RegisterAs(name string) Participant {
+	return &participant{b: b, name: name, err: b.adjust(1)}
+}
+
+func (b *barrier) Deregister() error {
+	return b.adjust(-1)
+}
+
+// adjust changes the number of parties by delta.
+// It takes effect on the current round, only if nobody has arrived in it,
+// otherwise on the next round.
+func (b *barrier) adjust(delta int) error {
+	b.lockArrivals()
+	defer b.unlockArrivals()
+	if b.closed {
+		return ErrClosed
+	}
+	if b.participants+delta <= 0 {
+		return ErrNonPositiveParticipants
+	}
+	if b.participants+delta > maxParticipants {
+		return ErrParticipantsOutOfRange
+	}
+	b.participants += delta
+	if b.round.count() == 0 {
+		// nobody is in the current round, replace it with a resized one.
+		b.setRound(b.newRound())
+		b.syncBroken()
+	}
+	return nil
+}
+
+// check returns why the participant can not be used, if it is not
+// registered, or has deregistered.
+func (p *participant) check() error {
+	if p.err != nil {
+		return p.err
+	}
+	if atomic.LoadInt32(&p.deregistered) == 1 {
+		return ErrDeregistered
+	}
+	return nil
+}
+
+func (p *participant) Wait(ctx context.Context) error {
+	if err := p.check(); err != nil {
+		return err
+	}
+	if p.name != "" {
+		return p.b.WaitAs(ctx, p.name)
+	}
+	return p.b.Wait(ctx)
+}
+
+func (p *participant) Arrive() error {
+	if err := p.check(); err != nil {
+		return err
+	}
+	_, err := p.b.TryArrive()
+	return err
+}
+
+func (p *participant) ArriveAndWait(ctx context.Context) error {
+	return p.Wait(ctx)
+}
+
+func (p *participant) ArriveAndDeregister() error {
+	if err := p.Arrive(); err != nil {
+		return err
+	}
+	return p.Deregister()
+}
+
+func (p *participant) Deregister() error {
+	if p.err != nil {
+		return p.err
+	}
+	if !atomic.CompareAndSwapInt32(&p.deregistered, 0, 1) {
+		return ErrDeregistered
+	}
+	return p.b.Deregister()
+}
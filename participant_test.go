@@ -0,0 +1,131 @@
+package barrier
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegister(t *testing.T) {
+	Convey("假设 Barrier 有 1 个参与者", t, func() {
+		b := New(1)
+
+		Convey("Register 以后，有 2 个参与者，可以用 Participant 来 Wait", func() {
+			p := b.Register()
+			So(b.Participants(), ShouldEqual, 2)
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- p.Wait(context.TODO())
+			}()
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(<-errCh, ShouldBeNil)
+
+			Convey("Participant Deregister 以后，只剩 1 个参与者，它不能再使用了", func() {
+				So(p.Deregister(), ShouldBeNil)
+				So(b.Participants(), ShouldEqual, 1)
+				So(p.Wait(context.TODO()), ShouldEqual, ErrDeregistered)
+				So(p.Deregister(), ShouldEqual, ErrDeregistered)
+				So(b.Wait(context.TODO()), ShouldBeNil)
+			})
+		})
+
+		Convey("RegisterAs 的 Participant 会用它的名字 Wait", func() {
+			p := b.RegisterAs("shard-7-writer")
+			So(b.Participants(), ShouldEqual, 2)
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			err := p.Wait(ctx)
+			So(err.Error(), ShouldEqual, "barrier is broken by shard-7-writer: context canceled")
+		})
+
+		Convey("只剩下 1 个参与者的时候，不能 Deregister", func() {
+			So(b.Deregister(), ShouldEqual, ErrNonPositiveParticipants)
+		})
+
+		Convey("这一轮已经有人到达时，Register 从下一轮开始生效", func() {
+			b := New(2)
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- b.Wait(context.TODO())
+			}()
+			for b.NumberWaiting() < 1 {
+				runtime.Gosched()
+			}
+			b.Register()
+			So(b.Participants(), ShouldEqual, 3)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(<-errCh, ShouldBeNil)
+			goWait(b)
+			goWait(b)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(b.Round(), ShouldEqual, 2)
+		})
+
+		Convey("Close 以后，不能 Deregister", func() {
+			b.Register()
+			b.Close()
+			So(b.Deregister(), ShouldEqual, ErrClosed)
+		})
+
+		Convey("Close 以后 Register 的 Participant，所有的调用都返回 ErrClosed", func() {
+			b.Close()
+			p := b.Register()
+			So(b.Participants(), ShouldEqual, 1)
+			So(p.Wait(context.TODO()), ShouldEqual, ErrClosed)
+			So(p.Arrive(), ShouldEqual, ErrClosed)
+			So(p.ArriveAndWait(context.TODO()), ShouldEqual, ErrClosed)
+			So(p.ArriveAndDeregister(), ShouldEqual, ErrClosed)
+			So(p.Deregister(), ShouldEqual, ErrClosed)
+		})
+	})
+}
+
+func TestParticipantArrive(t *testing.T) {
+	Convey("假设 Barrier 有 1 个参与者，又注册了 2 个参与者", t, func() {
+		b := New(1)
+		p1, p2 := b.Register(), b.Register()
+
+		Convey("p1 Arrive 不会等待，其他参与者到达以后，这一轮完成", func() {
+			So(p1.Arrive(), ShouldBeNil)
+			So(b.NumberWaiting(), ShouldEqual, 1)
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- p2.ArriveAndWait(context.TODO())
+			}()
+			for b.NumberWaiting() < 2 {
+				runtime.Gosched()
+			}
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(<-errCh, ShouldBeNil)
+			So(b.Round(), ShouldEqual, 1)
+		})
+
+		Convey("最后到达的 Arrive 会完成这一轮", func() {
+			var executed bool
+			b.SetAction(func() {
+				executed = true
+			})
+			goWait(b)
+			go p2.Arrive()
+			for b.NumberWaiting() < 2 {
+				runtime.Gosched()
+			}
+			So(p1.Arrive(), ShouldBeNil)
+			So(executed, ShouldBeTrue)
+			So(b.Round(), ShouldEqual, 1)
+		})
+
+		Convey("p1 ArriveAndDeregister 以后，这一轮依然需要 3 个，之后只需要 2 个", func() {
+			So(p1.ArriveAndDeregister(), ShouldBeNil)
+			So(p1.Arrive(), ShouldEqual, ErrDeregistered)
+			So(b.Participants(), ShouldEqual, 2)
+			goWait(b)
+			So(p2.Wait(context.TODO()), ShouldBeNil)
+			goWait(b)
+			So(p2.Wait(context.TODO()), ShouldBeNil)
+			So(b.Round(), ShouldEqual, 2)
+		})
+	})
+}
@@ -0,0 +1,195 @@
+package barrier
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrTerminated will be returned by the methods of a terminated Phaser.
+var ErrTerminated = errors.New("barrier phaser is terminated")
+
+// Phaser is a reusable barrier with numbered phases, like the one of Java.
+// The registered parties arrive every phase, and the phase advances once
+// all of them have arrived. The number of the parties may vary from phase
+// to phase by Register and ArriveAndDeregister.
+type Phaser struct {
+	lock       sync.Mutex
+	phase      int
+	registered int
+	arrived    int
+	advanced   chan struct{} // closed when the current phase advances
+	terminated bool
+	onAdvance  func(phase, registered int) bool
+}
+
+// NewPhaser initializes a new instance of the Phaser with parties
+// registered, at phase 0.
+// It panics if parties < 0.
+func NewPhaser(parties int) *Phaser {
+	if parties < 0 {
+		panic("barrier phaser parties is negative")
+	}
+	return &Phaser{
+		registered: parties,
+		advanced:   make(chan struct{}),
+		onAdvance: func(phase, registered int) bool {
+			return registered == 0
+		},
+	}
+}
+
+// OnAdvance sets the hook called on every advance with the number of the
+// completing phase and the registered parties of the next one.
+// The Phaser terminates if it returns true.
+// The default one terminates once no party is registered.
+// It is called with the lock of p held, so it must not call the methods
+// of p.
+func (p *Phaser) OnAdvance(fn func(phase, registered int) bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.onAdvance = fn
+}
+
+// Register adds a new party, which arrives from the current phase.
+// It returns the current phase.
+func (p *Phaser) Register() (int, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.terminated {
+		return p.phase, ErrTerminated
+	}
+	p.registered++
+	return p.phase, nil
+}
+
+// Arrive arrives the current phase without waiting others,
+// and returns the arrived phase.
+func (p *Phaser) Arrive() (int, error) {
+	phase, _, err := p.arrive(0)
+	return phase, err
+}
+
+// ArriveAndDeregister arrives the current phase without waiting others,
+// and deregisters the caller from the next phases.
+// It returns the arrived phase.
+func (p *Phaser) ArriveAndDeregister() (int, error) {
+	phase, _, err := p.arrive(1)
+	return phase, err
+}
+
+// ArriveAndAwaitAdvance arrives the current phase, and waits all the
+// registered parties to arrive it. It returns the next phase.
+func (p *Phaser) ArriveAndAwaitAdvance(ctx context.Context) (int, error) {
+	phase, advanced, err := p.arrive(0)
+	if err != nil {
+		return phase, err
+	}
+	return p.await(ctx, phase, advanced)
+}
+
+// AwaitAdvance waits the phase to advance, and returns the next phase.
+// It returns the current phase at once, if phase is not the current one.
+func (p *Phaser) AwaitAdvance(ctx context.Context, phase int) (int, error) {
+	p.lock.Lock()
+	current, advanced, terminated := p.phase, p.advanced, p.terminated
+	p.lock.Unlock()
+	if terminated {
+		return current, ErrTerminated
+	}
+	if phase != current {
+		return current, nil
+	}
+	return p.await(ctx, phase, advanced)
+}
+
+// await waits advanced of phase to be closed.
+func (p *Phaser) await(ctx context.Context, phase int, advanced chan struct{}) (int, error) {
+	select {
+	case <-advanced:
+	case <-ctx.Done():
+		return phase, ctx.Err()
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.terminated && p.phase == phase {
+		return phase, ErrTerminated
+	}
+	return phase + 1, nil
+}
+
+// arrive arrives the current phase, and deregisters the caller, if
+// deregister is 1. It returns the phase arrived, and the channel closed
+// on its advance.
+func (p *Phaser) arrive(deregister int) (int, chan struct{}, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	phase, advanced := p.phase, p.advanced
+	if p.terminated {
+		return phase, advanced, ErrTerminated
+	}
+	if p.arrived >= p.registered {
+		return phase, advanced, ErrTooManyParties
+	}
+	p.arrived++
+	if p.arrived == p.registered {
+		p.advance(p.registered - deregister)
+	}
+	p.registered -= deregister
+	return phase, advanced, nil
+}
+
+// advance completes the current phase, whose next phase has registered
+// parties. It should be called with p.lock held.
+func (p *Phaser) advance(registered int) {
+	if p.onAdvance(p.phase, registered) {
+		p.terminated = true
+		close(p.advanced)
+		return
+	}
+	p.phase++
+	p.arrived = 0
+	close(p.advanced)
+	p.advanced = make(chan struct{})
+}
+
+// ForceTermination terminates p, and releases the waiting parties with
+// ErrTerminated.
+func (p *Phaser) ForceTermination() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.terminated {
+		return
+	}
+	p.terminated = true
+	close(p.advanced)
+}
+
+// Phase returns the current phase.
+func (p *Phaser) Phase() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.phase
+}
+
+// RegisteredParties returns the number of the registered parties.
+func (p *Phaser) RegisteredParties() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.registered
+}
+
+// ArrivedParties returns the number of the parties arrived the current
+// phase.
+func (p *Phaser) ArrivedParties() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.arrived
+}
+
+// IsTerminated reports whether p is terminated.
+func (p *Phaser) IsTerminated() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.terminated
+}
@@ -0,0 +1,135 @@
+package barrier
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPhaser(t *testing.T) {
+	Convey("parties 为负数时，NewPhaser 会 panic", t, func() {
+		So(func() { NewPhaser(-1) }, ShouldPanic)
+	})
+
+	Convey("假设 Phaser 有 3 个参与者", t, func() {
+		parties := 3
+		p := NewPhaser(parties)
+		So(p.Phase(), ShouldEqual, 0)
+		So(p.RegisteredParties(), ShouldEqual, parties)
+
+		Convey("所有参与者到达后，phase 递增，可以重复多次", func() {
+			phases := 10
+			var wg sync.WaitGroup
+			wg.Add(parties)
+			for i := 0; i < parties; i++ {
+				go func() {
+					defer wg.Done()
+					for j := 0; j < phases; j++ {
+						next, err := p.ArriveAndAwaitAdvance(context.TODO())
+						if err != nil || next != j+1 {
+							panic(err)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			So(p.Phase(), ShouldEqual, phases)
+			So(p.ArrivedParties(), ShouldEqual, 0)
+		})
+
+		Convey("AwaitAdvance 等待指定的 phase 完成", func() {
+			next := make(chan int, 1)
+			go func() {
+				phase, _ := p.AwaitAdvance(context.TODO(), 0)
+				next <- phase
+			}()
+			for i := 0; i < parties; i++ {
+				phase, err := p.Arrive()
+				So(err, ShouldBeNil)
+				So(phase, ShouldEqual, 0)
+			}
+			So(<-next, ShouldEqual, 1)
+
+			Convey("phase 不是当前的 phase 时，立即返回当前的 phase", func() {
+				phase, err := p.AwaitAdvance(context.TODO(), 0)
+				So(err, ShouldBeNil)
+				So(phase, ShouldEqual, 1)
+			})
+		})
+
+		Convey("Register 的参与者从当前 phase 开始到达", func() {
+			for i := 0; i < parties-1; i++ {
+				p.Arrive()
+			}
+			phase, err := p.Register()
+			So(err, ShouldBeNil)
+			So(phase, ShouldEqual, 0)
+			p.Arrive()
+			So(p.Phase(), ShouldEqual, 0)
+			p.Arrive()
+			So(p.Phase(), ShouldEqual, 1)
+			So(p.RegisteredParties(), ShouldEqual, parties+1)
+		})
+
+		Convey("没有注册的参与者时，到达会返回 ErrTooManyParties", func() {
+			_, err := NewPhaser(0).Arrive()
+			So(err, ShouldEqual, ErrTooManyParties)
+		})
+
+		Convey("被取消的等待返回 ctx.Err()，不影响其他参与者", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := p.ArriveAndAwaitAdvance(ctx)
+			So(err, ShouldEqual, context.Canceled)
+			So(p.ArrivedParties(), ShouldEqual, 1)
+		})
+
+		Convey("所有参与者注销后，Phaser 终止", func() {
+			for i := 0; i < parties; i++ {
+				_, err := p.ArriveAndDeregister()
+				So(err, ShouldBeNil)
+			}
+			So(p.IsTerminated(), ShouldBeTrue)
+			_, err := p.Arrive()
+			So(err, ShouldEqual, ErrTerminated)
+			_, err = p.Register()
+			So(err, ShouldEqual, ErrTerminated)
+		})
+
+		Convey("OnAdvance 返回 true 时，Phaser 终止，等待的参与者返回 ErrTerminated", func() {
+			var advanced []int
+			p.OnAdvance(func(phase, registered int) bool {
+				advanced = append(advanced, phase)
+				return phase == 1
+			})
+			errs := make(chan error, parties)
+			for i := 0; i < parties; i++ {
+				go func() {
+					var err error
+					for err == nil {
+						_, err = p.ArriveAndAwaitAdvance(context.TODO())
+					}
+					errs <- err
+				}()
+			}
+			for i := 0; i < parties; i++ {
+				So(<-errs, ShouldEqual, ErrTerminated)
+			}
+			So(advanced, ShouldResemble, []int{0, 1})
+			So(p.Phase(), ShouldEqual, 1)
+		})
+
+		Convey("ForceTermination 释放等待的参与者", func() {
+			errCh := make(chan error, 1)
+			go func() {
+				_, err := p.ArriveAndAwaitAdvance(context.TODO())
+				errCh <- err
+			}()
+			p.ForceTermination()
+			p.ForceTermination()
+			So(<-errCh, ShouldEqual, ErrTerminated)
+		})
+	})
+}
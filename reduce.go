@@ -0,0 +1,47 @@
+package barrier
+
+import "context"
+
+// Reduce is a Barrier, every participant of which contributes a value,
+// and receives the reduced value of all the contributions of the round.
+// Participants calling Wait or Break of the embedded Barrier contribute
+// the zero value of T.
+type Reduce[T any] struct {
+	Barrier
+	b *barrier
+}
+
+// NewReduce initializes a new instance of the Reduce.
+// The contributions of a round are reduced by fn in arrival order,
+// fn(fn(v1, v2), v3)..., by the last arrived goroutine, before the actions
+// set by SetAction and the others.
+func NewReduce[T any](participants int, fn func(a, b T) T, opts ...Option) *Reduce[T] {
+	opts = append(opts, func(b *barrier) {
+		b.collector = func(values []interface{}) (interface{}, error) {
+			all := unbox[T](values)
+			acc := all[0]
+			for _, v := range all[1:] {
+				acc = fn(acc, v)
+			}
+			return box[T]{acc}, nil
+		}
+	})
+	b := New(participants, opts...).(*barrier)
+	return &Reduce[T]{
+		Barrier: b,
+		b:       b,
+	}
+}
+
+// Wait contributes v to the round, waits like Barrier.Wait, and returns
+// the reduced value of the round.
+// It returns the zero value of T, if the round is broken.
+func (r *Reduce[T]) Wait(ctx context.Context, v T) (T, error) {
+	var zero T
+	out, _, err := r.b.wait(ctx, box[T]{v}, 0, 0)
+	if err != nil {
+		return zero, err
+	}
+	res, _ := out.result.(box[T])
+	return res.v, nil
+}
@@ -0,0 +1,57 @@
+package barrier
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReduce(t *testing.T) {
+	Convey("假设 Reduce 有 4 个参与者，求和", t, func() {
+		participants := 4
+		r := NewReduce(participants, func(a, b int) int {
+			return a + b
+		})
+		waitAll := func(base int) []int {
+			var wg sync.WaitGroup
+			sums := make(chan int, participants)
+			wg.Add(participants)
+			for i := 1; i <= participants; i++ {
+				go func(v int) {
+					sum, err := r.Wait(context.TODO(), v)
+					if err == nil {
+						sums <- sum
+					}
+					wg.Done()
+				}(base + i)
+			}
+			wg.Wait()
+			close(sums)
+			var res []int
+			for sum := range sums {
+				res = append(res, sum)
+			}
+			return res
+		}
+
+		Convey("每一轮所有的参与者都得到这一轮的和", func() {
+			So(waitAll(0), ShouldResemble, []int{10, 10, 10, 10})
+			So(waitAll(10), ShouldResemble, []int{50, 50, 50, 50})
+		})
+	})
+
+	Convey("假设 Reduce 有 2 个参与者，求最小值，其中 1 个 Break 了", t, func() {
+		r := NewReduce(2, func(a, b int) int {
+			if a < b {
+				return a
+			}
+			return b
+		})
+		go r.Break()
+		min, err := r.Wait(context.TODO(), 1)
+		So(err, shouldBeBrokenBy, ErrBroken)
+		So(min, ShouldEqual, 0)
+	})
+}
@@ -0,0 +1,78 @@
+package barrier
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrPartiesMismatch will be returned by Registry.Get, if the barrier of
+// the name has different participants.
+var ErrPartiesMismatch = errors.New("barrier participants mismatch the registered one")
+
+// Registry holds barriers by name, so that the goroutines can rendezvous
+// at a barrier identified by a string.
+// The zero value is ready to use.
+type Registry struct {
+	lock     sync.Mutex
+	barriers map[string]Barrier
+}
+
+// DefaultRegistry is the process-wide Registry.
+var DefaultRegistry = &Registry{}
+
+// NewRegistry initializes a new instance of the Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Get returns the barrier of name, which is created with participants
+// and opts, if it does not exist.
+// It returns ErrPartiesMismatch if the barrier exists with different
+// participants, and opts are ignored then.
+func (reg *Registry) Get(name string, participants int, opts ...Option) (Barrier, error) {
+	reg.lock.Lock()
+	defer reg.lock.Unlock()
+	if b, ok := reg.barriers[name]; ok {
+		if b.Participants() != participants {
+			return nil, ErrPartiesMismatch
+		}
+		return b, nil
+	}
+	if reg.barriers == nil {
+		reg.barriers = make(map[string]Barrier)
+	}
+	b := New(participants, opts...)
+	reg.barriers[name] = b
+	return b, nil
+}
+
+// Lookup returns the barrier of name, and whether it exists.
+func (reg *Registry) Lookup(name string) (Barrier, bool) {
+	reg.lock.Lock()
+	defer reg.lock.Unlock()
+	b, ok := reg.barriers[name]
+	return b, ok
+}
+
+// Remove removes the barrier of name, and closes it, so that its waiting
+// goroutines return ErrClosed. It does nothing if name does not exist.
+func (reg *Registry) Remove(name string) {
+	reg.lock.Lock()
+	b, ok := reg.barriers[name]
+	delete(reg.barriers, name)
+	reg.lock.Unlock()
+	if ok {
+		b.Close()
+	}
+}
+
+// Names returns the names of the barriers in the registry.
+func (reg *Registry) Names() []string {
+	reg.lock.Lock()
+	defer reg.lock.Unlock()
+	names := make([]string, 0, len(reg.barriers))
+	for name := range reg.barriers {
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,62 @@
+package barrier
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegistry(t *testing.T) {
+	Convey("假设有一个 Registry", t, func() {
+		reg := NewRegistry()
+
+		Convey("同一个名字得到同一个 Barrier", func() {
+			b1, err := reg.Get("checkpoint-1", 2)
+			So(err, ShouldBeNil)
+			b2, err := reg.Get("checkpoint-1", 2)
+			So(err, ShouldBeNil)
+			So(b2, ShouldEqual, b1)
+			goWait(b1)
+			So(b2.Wait(context.TODO()), ShouldBeNil)
+			So(b1.Round(), ShouldEqual, 1)
+		})
+
+		Convey("参与者数量不一致时，返回 ErrPartiesMismatch", func() {
+			reg.Get("checkpoint-1", 2)
+			b, err := reg.Get("checkpoint-1", 3)
+			So(b, ShouldBeNil)
+			So(err, ShouldEqual, ErrPartiesMismatch)
+		})
+
+		Convey("Lookup 和 Names 可以查看已有的 Barrier", func() {
+			_, ok := reg.Lookup("a")
+			So(ok, ShouldBeFalse)
+			reg.Get("a", 1)
+			reg.Get("b", 1)
+			_, ok = reg.Lookup("a")
+			So(ok, ShouldBeTrue)
+			names := reg.Names()
+			sort.Strings(names)
+			So(names, ShouldResemble, []string{"a", "b"})
+		})
+
+		Convey("Remove 会关闭 Barrier，随后 Get 会创建一个新的", func() {
+			b1, _ := reg.Get("a", 2)
+			reg.Remove("a")
+			reg.Remove("a")
+			So(b1.Wait(context.TODO()), ShouldEqual, ErrClosed)
+			b2, err := reg.Get("a", 3)
+			So(err, ShouldBeNil)
+			So(b2, ShouldNotEqual, b1)
+		})
+	})
+
+	Convey("DefaultRegistry 可以直接使用", t, func() {
+		b, err := DefaultRegistry.Get("registry-test", 1)
+		So(err, ShouldBeNil)
+		So(b.Wait(context.TODO()), ShouldBeNil)
+		DefaultRegistry.Remove("registry-test")
+	})
+}
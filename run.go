@@ -0,0 +1,139 @@
+package barrier
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// RunRounds launches parties goroutines, each of them runs work and then
+// waits on b, for cycles times.
+// If work returns an error, the goroutine breaks the round instead of
+// waiting, so that the others do not block for it.
+// It returns the first error from work or Wait, and the others are
+// canceled by the context derived from ctx then.
+func RunRounds(ctx context.Context, b Barrier, parties, cycles int, work func(cycle int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		once sync.Once
+		res  error
+		wg   sync.WaitGroup
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			res = err
+			cancel()
+		})
+	}
+	wg.Add(parties)
+	for i := 0; i < parties; i++ {
+		go func() {
+			defer wg.Done()
+			for cycle := 0; cycle < cycles; cycle++ {
+				if err := work(cycle); err != nil {
+					fail(err)
+					b.Break()
+					return
+				}
+				if err := b.Wait(ctx); err != nil {
+					fail(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return res
+}
+
+// RunRound runs fns concurrently as the parties of a fresh Barrier, and
+// waits all of them to finish, which is known as fork-join.
+// Once a function returns an error, the round is broken, and the others
+// are canceled by the context derived from ctx.
+// It returns the errors of the functions joined, or nil if all of them
+// succeed.
+func RunRound(ctx context.Context, fns ...func(ctx context.Context) error) error {
+	return RunRoundAction(ctx, nil, fns...)
+}
+
+// RunRoundAction is RunRound, and runs action after all fns succeed,
+// like SetActionE. nil action means no action.
+// If action returns an error, it returns a *BrokenError wrapping the error.
+func RunRoundAction(ctx context.Context, action func() error, fns ...func(ctx context.Context) error) error {
+	if len(fns) == 0 {
+		if action == nil {
+			return nil
+		}
+		return New(1, WithAction(action)).Wait(ctx)
+	}
+	var opts []Option
+	if action != nil {
+		opts = append(opts, WithAction(action))
+	}
+	b := New(len(fns), opts...).(*barrier)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		lock   sync.Mutex
+		failed joinedErrors // returned by fns
+		broken error        // returned by Wait
+		wg     sync.WaitGroup
+	)
+	wg.Add(len(fns))
+	for _, fn := range fns {
+		go func(fn func(ctx context.Context) error) {
+			defer wg.Done()
+			if err := fn(ctx); err != nil {
+				lock.Lock()
+				failed = append(failed, err)
+				lock.Unlock()
+				cancel()
+				b.BreakWith(err)
+				return
+			}
+			if err := b.Wait(ctx); err != nil {
+				lock.Lock()
+				if broken == nil {
+					broken = err
+				}
+				lock.Unlock()
+			}
+		}(fn)
+	}
+	wg.Wait()
+	if len(failed) > 0 {
+		return failed
+	}
+	return broken
+}
+
+// joinedErrors is the errors of RunRound.
+type joinedErrors []error
+
+func (errs joinedErrors) Error() string {
+	if len(errs) == 1 {
+		return errs[0].Error()
+	}
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Is reports whether any of errs matches target.
+func (errs joinedErrors) Is(target error) bool {
+	for _, err := range errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unwrap returns errs, for errors.As since Go 1.20.
+func (errs joinedErrors) Unwrap() []error {
+	return errs
+}
@@ -0,0 +1,95 @@
+package barrier
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRunRounds(t *testing.T) {
+	Convey("假设 Barrier 有 3 个参与者，要运行 5 轮", t, func() {
+		parties, cycles := 3, 5
+		b := New(parties)
+
+		Convey("work 都成功时，返回 nil，并且完成了 5 轮", func() {
+			var done int32
+			err := RunRounds(context.TODO(), b, parties, cycles, func(cycle int) error {
+				atomic.AddInt32(&done, 1)
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(atomic.LoadInt32(&done), ShouldEqual, parties*cycles)
+			So(b.Round(), ShouldEqual, cycles)
+		})
+
+		Convey("第 3 轮有一个 work 失败时，返回它的 error，不会卡住", func() {
+			errWork := errors.New("work failed")
+			var failed int32
+			err := RunRounds(context.TODO(), b, parties, cycles, func(cycle int) error {
+				if cycle == 2 && atomic.CompareAndSwapInt32(&failed, 0, 1) {
+					return errWork
+				}
+				return nil
+			})
+			So(err, ShouldEqual, errWork)
+			So(b.Stats().CompletedRounds, ShouldEqual, 2)
+		})
+	})
+}
+
+func TestRunRound(t *testing.T) {
+	Convey("没有函数时，RunRound 返回 nil，RunRoundAction 只运行 action", t, func() {
+		So(RunRound(context.TODO()), ShouldBeNil)
+		var executed int32
+		So(RunRoundAction(context.TODO(), func() error {
+			atomic.AddInt32(&executed, 1)
+			return nil
+		}), ShouldBeNil)
+		So(atomic.LoadInt32(&executed), ShouldEqual, 1)
+	})
+
+	Convey("假设有 3 个函数", t, func() {
+		var done int32
+		ok := func(ctx context.Context) error {
+			atomic.AddInt32(&done, 1)
+			return nil
+		}
+
+		Convey("都成功时，返回 nil，并且在所有函数完成后才运行 action", func() {
+			var seen int32
+			err := RunRoundAction(context.TODO(), func() error {
+				seen = atomic.LoadInt32(&done)
+				return nil
+			}, ok, ok, ok)
+			So(err, ShouldBeNil)
+			So(seen, ShouldEqual, 3)
+		})
+
+		Convey("有函数失败时，其他函数被取消，返回所有失败的 error", func() {
+			errA, errB := errors.New("a failed"), errors.New("b failed")
+			blocked := func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			err := RunRound(context.TODO(),
+				func(ctx context.Context) error { return errA },
+				blocked,
+				func(ctx context.Context) error { return errB },
+				ok)
+			So(errors.Is(err, errA), ShouldBeTrue)
+			So(errors.Is(err, errB), ShouldBeTrue)
+			So(errors.Is(err, context.Canceled), ShouldBeTrue)
+		})
+
+		Convey("action 失败时，返回包装了它的 BrokenError", func() {
+			errAction := errors.New("action failed")
+			err := RunRoundAction(context.TODO(), func() error {
+				return errAction
+			}, ok, ok, ok)
+			So(err, shouldBeBrokenBy, errAction)
+		})
+	})
+}
@@ -0,0 +1,58 @@
+package barrier
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrScatterLength will be wrapped in the error of the round, if the leader
+// of Scatter does not return a value for every participant.
+var ErrScatterLength = errors.New("barrier scatter length does not match participants")
+
+// Scatter is a Barrier, the leader of which, the last arrived goroutine,
+// supplies a slice of values for every round, and each participant of the
+// round receives its own element by its arrival order.
+// To broadcast the same value to all participants, use Typed instead.
+type Scatter[T any] struct {
+	Barrier
+	b *barrier
+}
+
+// NewScatter initializes a new instance of the Scatter.
+// lead is called by the leader of every round, before the actions set by
+// SetAction and the others. It should return exactly participants values,
+// otherwise the round is broken with ErrScatterLength. If it returns an
+// error, the round is broken with it like SetActionE.
+func NewScatter[T any](participants int, lead func() ([]T, error), opts ...Option) *Scatter[T] {
+	opts = append(opts, func(b *barrier) {
+		b.collector = func(values []interface{}) (interface{}, error) {
+			all, err := lead()
+			if err != nil {
+				return nil, err
+			}
+			if len(all) != len(values) {
+				return nil, ErrScatterLength
+			}
+			return all, nil
+		}
+	})
+	b := New(participants, opts...).(*barrier)
+	return &Scatter[T]{
+		Barrier: b,
+		b:       b,
+	}
+}
+
+// Wait is Barrier.Wait, and returns the element of the caller, which is
+// the index-th element of the slice of the leader, where index is its
+// arrival order starting from 0.
+// It returns the zero value of T, if the round is broken.
+func (s *Scatter[T]) Wait(ctx context.Context) (T, error) {
+	var zero T
+	out, count, err := s.b.wait(ctx, nil, 0, 0)
+	if err != nil {
+		return zero, err
+	}
+	all, _ := out.result.([]T)
+	return all[count-1], nil
+}
@@ -0,0 +1,59 @@
+package barrier
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestScatter(t *testing.T) {
+	Convey("假设 Scatter 有 3 个参与者，leader 给每个参与者分配一个任务", t, func() {
+		participants := 3
+		tasks := []string{"x", "y", "z"}
+		s := NewScatter(participants, func() ([]string, error) {
+			return tasks, nil
+		})
+
+		Convey("每个参与者都得到了不同的任务", func() {
+			var wg sync.WaitGroup
+			got := make(chan string, participants)
+			wg.Add(participants)
+			for i := 0; i < participants; i++ {
+				go func() {
+					task, err := s.Wait(context.TODO())
+					if err == nil {
+						got <- task
+					}
+					wg.Done()
+				}()
+			}
+			wg.Wait()
+			close(got)
+			var all []string
+			for task := range got {
+				all = append(all, task)
+			}
+			sort.Strings(all)
+			So(all, ShouldResemble, tasks)
+		})
+
+		Convey("leader 分配的任务数量不对时，这一轮被 break", func() {
+			tasks = tasks[:2]
+			errs := make(chan error, participants-1)
+			for i := 0; i < participants-1; i++ {
+				go func() {
+					_, err := s.Wait(context.TODO())
+					errs <- err
+				}()
+			}
+			task, err := s.Wait(context.TODO())
+			So(task, ShouldBeEmpty)
+			So(err, shouldBeBrokenBy, ErrScatterLength)
+			So(<-errs, shouldBeBrokenBy, ErrScatterLength)
+			So(<-errs, shouldBeBrokenBy, ErrScatterLength)
+		})
+	})
+}
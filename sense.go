@@ -0,0 +1,820 @@
+package barrier
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var _ Barrier = (*senseBarrier)(nil)
+
+// The layout of senseBarrier.state. count is the number of goroutines
+// arrived the current generation, broken and closed are the flags of it,
+// and the lowest bit of generation is the sense, which reverses once the
+// generation completes.
+const (
+	senseCountMask   = 1<<32 - 1
+	senseBroken      = 1 << 32
+	senseClosed      = 1 << 33
+	senseGenShift    = 34
+	senseGenerations = 1<<(64-senseGenShift) - 1
+)
+
+// senseBarrier is the Barrier of SenseReversing.
+// The arrivals are counted by a CAS of state, and the last arrival of a
+// generation runs the action and reverses the sense. The waiting goroutines
+// spin and park on b.parked, or on the channel of their sense, until the
+// sense reverses, or the generation is broken.
+// Like the classic one, it assumes the same goroutines arrive every
+// generation, so that a goroutine waits for the sense it arrived with at
+// most, and the errors of the generations are kept by their sense.
+// That is, no goroutine lags two generations behind: the error of gen is
+// overwritten once gen+2 is released, which may happen before a waiting
+// goroutine of gen returns, only if the arrivals of gen+1 and gen+2 are
+// made by the others, like TryArrive or more goroutines than the parties.
+// The lagging goroutine can not tell the result of its generation, so it
+// panics, or returns ErrTooManyParties WithoutOverflowPanic.
+//
+// b holds the options, the actions, the hooks and the statistics, guarded
+// by b.lock, but never has a round. The options of WithAction, WithOnBroken,
+// WithOnRelease, WithObserver, WithName, WithSpin and WithoutOverflowPanic
+// take effect. The options changing how a round completes, which are
+// listed by unsupportedOption, make New panic, and the others are ignored. The observer is not notified of the
+// arrivals and the waits, and is notified of a trip after its release.
+type senseBarrier struct {
+	state   uint64 // generation, closed, broken and count, atomic
+	b       *barrier
+	parties int
+	action  func(context.Context) error // chain of b.actions, guarded by b.lock
+	done    [2]chan struct{}            // closed to wake the waiting goroutines of the sense, allocated on demand, guarded by b.parking
+	errs    [2]error                    // returned by the goroutines of the sense, guarded by b.parking
+	gens    [2]uint64                   // generation of errs, guarded by b.parking
+	timeout int32                       // 1 if SetRoundTimeout has set a timeout, which is not supported, atomic
+}
+
+// newSenseBarrier returns the SenseReversing barrier configured by b.
+// It panics if b is configured by an option not supported by it.
+func newSenseBarrier(b *barrier) *senseBarrier {
+	if opt := b.unsupportedOption(); opt != "" {
+		panic(senseUnsupported + opt)
+	}
+	if b.parked == nil {
+		b.parked = sync.NewCond(&b.parking)
+	}
+	return &senseBarrier{
+		b:       b,
+		parties: b.participants,
+		action:  chain(b.actions),
+	}
+}
+
+// unsupportedOption returns the name of the option configuring b, which
+// SenseReversing does not support, or "" if there is none.
+func (b *barrier) unsupportedOption() string {
+	switch {
+	case b.sticky:
+		return "WithStickyBroken"
+	case b.roundTimeout > 0:
+		return "WithRoundTimeout"
+	case b.cancelPolicy != BreakRound:
+		return "WithCancelPolicy"
+	case b.batch > 0:
+		return "WithStaggeredWakeup"
+	case b.watchdog > 0:
+		return "WithWatchdog"
+	case b.shards != nil:
+		return "WithSharding"
+	case b.recoverAfter > 0:
+		return "WithAutoRecover"
+	case b.queueOverflow:
+		return "WithOverflowQueue"
+	case b.stallAfter > 0:
+		return "WithStallWarning"
+	}
+	return ""
+}
+
+// generation returns the generation of state.
+func generation(state uint64) uint64 {
+	return state >> senseGenShift
+}
+
+// isReleased reports whether the goroutines arrived gen can return, because
+// the sense has reversed, or gen is broken.
+func (s *senseBarrier) isReleased(gen uint64) bool {
+	state := atomic.LoadUint64(&s.state)
+	return generation(state) != gen || state&senseBroken != 0
+}
+
+// arrive counts an arrival of the current generation, and returns the
+// state after it.
+func (s *senseBarrier) arrive(ctx context.Context) (uint64, error) {
+	if s.b.isActing(ctx) {
+		// the generation is waiting for the action, it would never return.
+		return 0, ErrReentrantWait
+	}
+	if atomic.LoadInt32(&s.timeout) != 0 {
+		return 0, ErrNotSupported
+	}
+	for {
+		state := atomic.LoadUint64(&s.state)
+		if state&senseClosed != 0 {
+			return 0, ErrClosed
+		}
+		// the last arrival has not reversed the sense yet.
+		if int(state&senseCountMask) >= s.parties {
+			if s.b.panicOnOverflow {
+				panic(tooMuchWaiting)
+			}
+			return 0, ErrTooManyParties
+		}
+		if atomic.CompareAndSwapUint64(&s.state, state, state+1) {
+			return state + 1, nil
+		}
+	}
+}
+
+func (s *senseBarrier) Wait(ctx context.Context) error {
+	_, err := s.wait(ctx, 0)
+	return err
+}
+
+func (s *senseBarrier) WaitTimeout(d time.Duration) error {
+	_, err := s.wait(context.Background(), d)
+	return err
+}
+
+func (s *senseBarrier) WaitIndexed(ctx context.Context) (int, error) {
+	return s.wait(ctx, 0)
+}
+
+func (s *senseBarrier) WaitIndex(ctx context.Context) (int, error) {
+	index, err := s.wait(ctx, 0)
+	return s.parties - index, err
+}
+
+func (s *senseBarrier) WaitChan(ctx context.Context) <-chan RoundResult {
+	ch := make(chan RoundResult, 1)
+	go func() {
+		ch <- RoundResult{Err: s.Wait(ctx)}
+	}()
+	return ch
+}
+
+// TryArrive arrives without waiting, so that the generation does not wait
+// for the caller, but its last arrival.
+func (s *senseBarrier) TryArrive() (bool, error) {
+	state, err := s.arrive(context.Background())
+	if err != nil {
+		return false, err
+	}
+	if int(state&senseCountMask) == s.parties {
+		return true, s.lastArrived(context.Background(), state)
+	}
+	return false, nil
+}
+
+// wait arrives, and waits the other parties no more than timeout, if
+// timeout > 0. It returns the 1-based arrival index of the caller.
+func (s *senseBarrier) wait(ctx context.Context, timeout time.Duration) (int, error) {
+	state, err := s.arrive(ctx)
+	if err != nil {
+		return 0, err
+	}
+	index := int(state & senseCountMask)
+	if index == s.parties {
+		return index, s.lastArrived(ctx, state)
+	}
+	return index, s.await(ctx, generation(state), timeout)
+}
+
+// await waits until the goroutine arrived gen can return, and returns the
+// error of gen.
+func (s *senseBarrier) await(ctx context.Context, gen uint64, timeout time.Duration) error {
+	for i := 0; i < s.b.spins && !s.isReleased(gen); i++ {
+		runtime.Gosched()
+	}
+	b := s.b
+	if timeout <= 0 && ctx.Done() == nil {
+		b.parking.Lock()
+		for !s.isReleased(gen) {
+			b.parked.Wait()
+		}
+		ok, err := s.result(gen)
+		b.parking.Unlock()
+		if !ok {
+			return s.lagged()
+		}
+		return err
+	}
+	done := s.doneOf(gen)
+	if done == nil {
+		return s.errOf(gen)
+	}
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// gen may have tripped already, it is released soon.
+		s.breakGen(gen, ctx.Err(), false)
+		<-done
+	case <-expired:
+		s.breakGen(gen, ErrTimeout, false)
+		<-done
+	}
+	return s.errOf(gen)
+}
+
+// doneOf returns the channel closed once gen is released, or nil if it has
+// been released.
+func (s *senseBarrier) doneOf(gen uint64) <-chan struct{} {
+	s.b.parking.Lock()
+	defer s.b.parking.Unlock()
+	if s.isReleased(gen) {
+		return nil
+	}
+	if s.done[gen&1] == nil {
+		s.done[gen&1] = make(chan struct{})
+	}
+	return s.done[gen&1]
+}
+
+// errOf returns the error of gen, after gen is released.
+func (s *senseBarrier) errOf(gen uint64) error {
+	s.b.parking.Lock()
+	ok, err := s.result(gen)
+	s.b.parking.Unlock()
+	if !ok {
+		return s.lagged()
+	}
+	return err
+}
+
+// result returns the error of gen, which has been released. It is not ok,
+// if the error has been overwritten by gen+2.
+// It should be called with b.parking held.
+func (s *senseBarrier) result(gen uint64) (bool, error) {
+	if s.gens[gen&1] != gen {
+		return false, nil
+	}
+	return true, s.errs[gen&1]
+}
+
+// lagged is called by the goroutine lagging two generations behind.
+func (s *senseBarrier) lagged() error {
+	if s.b.panicOnOverflow {
+		panic(senseLagged)
+	}
+	return ErrTooManyParties
+}
+
+// setErr sets the error of gen.
+// It should be called with b.parking held.
+func (s *senseBarrier) setErr(gen uint64, err error) {
+	s.errs[gen&1], s.gens[gen&1] = err, gen
+}
+
+// wake wakes the goroutines waiting for gen.
+// It should be called with b.parking held.
+func (s *senseBarrier) wake(gen uint64) {
+	if done := s.done[gen&1]; done != nil {
+		close(done)
+		s.done[gen&1] = nil
+	}
+	s.b.parked.Broadcast()
+}
+
+// lastArrived completes the generation of state, which is arrived by all
+// the parties, and returns its error.
+func (s *senseBarrier) lastArrived(ctx context.Context, state uint64) error {
+	gen := generation(state)
+	if state&senseBroken == 0 && ctx.Err() != nil {
+		s.breakGen(gen, ctx.Err(), true)
+	} else if state&senseBroken == 0 {
+		return s.trip(ctx, gen)
+	}
+	b := s.b
+	b.lock.Lock()
+	b.parking.Lock()
+	err := s.errs[gen&1]
+	s.reverse(gen, true)
+	b.parking.Unlock()
+	b.lock.Unlock()
+	return err
+}
+
+// trip runs the action of gen, and releases gen.
+func (s *senseBarrier) trip(ctx context.Context, gen uint64) error {
+	b := s.b
+	b.lock.RLock()
+	action := s.action
+	timer, _ := b.observer.(DurationObserver)
+	b.lock.RUnlock()
+	var isPanic bool
+	var err error
+	if action != nil {
+		start := time.Now()
+		isPanic, err = doAction(ctx, action)
+		if timer != nil {
+			timer.OnAction(time.Since(start))
+		}
+	}
+	b.lock.Lock()
+	b.parking.Lock()
+	// the others of gen return ErrBroken for the panic, which is returned
+	// by the caller.
+	switch {
+	case isPanic:
+		s.setErr(gen, b.brokenError(nil))
+	case err != nil:
+		s.setErr(gen, b.brokenError(err))
+	default:
+		s.setErr(gen, nil)
+	}
+	if err != nil {
+		b.emit(EventBroken, b.rounds+1, s.parties, err)
+	}
+	s.reverse(gen, err != nil)
+	round, res := b.rounds, s.errs[gen&1]
+	if isPanic {
+		res = err
+	}
+	hooks, onBroken, observer := b.onRelease, b.onBroken, b.observer
+	b.parking.Unlock()
+	b.lock.Unlock()
+	if err != nil {
+		if onBroken != nil {
+			onBroken(err)
+		}
+		if observer != nil {
+			observer.OnBreak(err)
+		}
+		return res
+	}
+	if observer != nil {
+		observer.OnTrip(round)
+	}
+	if len(hooks) > 0 {
+		info := RoundInfo{
+			Round:    round,
+			Parties:  s.parties,
+			Released: time.Now(),
+		}
+		go func() {
+			for _, hook := range hooks {
+				hook(info)
+			}
+		}()
+	}
+	return nil
+}
+
+// reverse reverses the sense of gen, which is arrived by all the parties,
+// and wakes its waiting goroutines.
+// It should be called with b.lock and b.parking held, so that nobody but
+// the last arrival of gen changes the state.
+func (s *senseBarrier) reverse(gen uint64, isBroken bool) {
+	start := time.Now()
+	state := atomic.LoadUint64(&s.state)
+	atomic.StoreUint64(&s.state, (gen+1)&senseGenerations<<senseGenShift|state&senseClosed)
+	s.complete(s.parties, isBroken)
+	s.wake(gen)
+	atomic.StoreInt64(&s.b.fanout, int64(time.Since(start)))
+}
+
+// complete counts a completed generation arrived by parties.
+// It should be called with b.lock held.
+func (s *senseBarrier) complete(parties int, isBroken bool) {
+	b := s.b
+	now := time.Now()
+	b.rounds++
+	b.stats.PartiesServed += uint64(parties)
+	if len(b.subscribers) > 0 {
+		b.send(RoundEvent{
+			Kind:    EventTripped,
+			Round:   b.rounds,
+			Broken:  isBroken,
+			Parties: parties,
+			When:    now,
+		})
+	}
+	if isBroken {
+		b.stats.BrokenRounds++
+		return
+	}
+	b.stats.CompletedRounds++
+	b.stats.LastCompletion = now
+}
+
+// breakGen breaks gen with err, unless it has been broken, or tripped.
+// last means the caller is the last arrival of gen, which breaks it
+// instead of tripping it.
+// It reports whether gen is broken by the caller.
+func (s *senseBarrier) breakGen(gen uint64, err error, last bool) bool {
+	b := s.b
+	b.lock.Lock()
+	b.parking.Lock()
+	var isBroken bool
+	var count int
+	for {
+		state := atomic.LoadUint64(&s.state)
+		count = int(state & senseCountMask)
+		if generation(state) != gen || state&senseBroken != 0 || count >= s.parties && !last {
+			break
+		}
+		s.setErr(gen, b.brokenError(err))
+		if atomic.CompareAndSwapUint64(&s.state, state, state|senseBroken) {
+			isBroken = true
+			break
+		}
+	}
+	if isBroken {
+		b.emit(EventBroken, b.rounds+1, count, err)
+		s.wake(gen)
+	}
+	onBroken, observer := b.onBroken, b.observer
+	b.parking.Unlock()
+	b.lock.Unlock()
+	if isBroken {
+		if onBroken != nil {
+			onBroken(err)
+		}
+		if observer != nil {
+			observer.OnBreak(err)
+		}
+	}
+	return isBroken
+}
+
+func (s *senseBarrier) Break() {
+	s.BreakCtx(context.Background())
+}
+
+func (s *senseBarrier) BreakWith(cause error) {
+	s.breakCtx(context.Background(), cause)
+}
+
+// BreakCtx arrives, and breaks the current generation. The goroutines of
+// it return ErrBroken, and it completes once all the parties have arrived.
+func (s *senseBarrier) BreakCtx(ctx context.Context) error {
+	return s.breakCtx(ctx, nil)
+}
+
+// breakCtx arrives, and breaks the current generation with cause.
+// nil cause means ErrBroken.
+func (s *senseBarrier) breakCtx(ctx context.Context, cause error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	state, err := s.arrive(ctx)
+	if err != nil {
+		return err
+	}
+	if cause == nil {
+		cause = ErrBroken
+	}
+	last := int(state&senseCountMask) == s.parties
+	s.breakGen(generation(state), cause, last)
+	if last {
+		s.lastArrived(ctx, state|senseBroken)
+	}
+	return nil
+}
+
+func (s *senseBarrier) TryWait() (bool, error) {
+	for {
+		state := atomic.LoadUint64(&s.state)
+		if state&senseClosed != 0 {
+			return false, ErrClosed
+		}
+		if int(state&senseCountMask)+1 != s.parties {
+			return false, nil
+		}
+		if atomic.CompareAndSwapUint64(&s.state, state, state+1) {
+			return true, s.lastArrived(context.Background(), state+1)
+		}
+	}
+}
+
+// Reset breaks the current generation, unless it is tripping, and reverses
+// the sense at once, so that the goroutines of the generation return
+// ErrBroken, and the later arrivals arrive the next generation.
+func (s *senseBarrier) Reset() {
+	b := s.b
+	b.lock.Lock()
+	b.parking.Lock()
+	var state uint64
+	for {
+		state = atomic.LoadUint64(&s.state)
+		count := int(state & senseCountMask)
+		// the generation arrived by all the parties will be reversed by its
+		// last arrival soon.
+		if state&senseClosed != 0 || count >= s.parties {
+			b.parking.Unlock()
+			b.lock.Unlock()
+			return
+		}
+		if state&senseBroken == 0 {
+			s.setErr(generation(state), b.brokenError(ErrBroken))
+		}
+		next := (generation(state) + 1) & senseGenerations << senseGenShift
+		if atomic.CompareAndSwapUint64(&s.state, state, next) {
+			break
+		}
+	}
+	gen, count := generation(state), int(state&senseCountMask)
+	broke := state&senseBroken == 0
+	if broke {
+		b.emit(EventBroken, b.rounds+1, count, ErrBroken)
+	}
+	s.complete(count, true)
+	b.emit(EventReset, b.rounds, count, nil)
+	s.wake(gen)
+	onBroken, observer := b.onBroken, b.observer
+	b.parking.Unlock()
+	b.lock.Unlock()
+	if broke {
+		if onBroken != nil {
+			onBroken(ErrBroken)
+		}
+		if observer != nil {
+			observer.OnBreak(ErrBroken)
+		}
+	}
+}
+
+// Close breaks the current generation with ErrClosed, unless it is
+// tripping, and the later arrivals return ErrClosed.
+func (s *senseBarrier) Close() error {
+	b := s.b
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		return nil
+	}
+	b.closed = true
+	var state uint64
+	for {
+		state = atomic.LoadUint64(&s.state)
+		if atomic.CompareAndSwapUint64(&s.state, state, state|senseClosed) {
+			break
+		}
+	}
+	b.emit(EventClosed, b.rounds+1, int(state&senseCountMask), nil)
+	// the consumers of the events would block forever.
+	for _, sub := range b.subscribers {
+		close(sub.ch)
+	}
+	b.subscribers = nil
+	b.lock.Unlock()
+	s.breakGen(generation(state), ErrClosed, false)
+	return nil
+}
+
+func (s *senseBarrier) IsBroken() bool {
+	return atomic.LoadUint64(&s.state)&(senseBroken|senseClosed) != 0
+}
+
+func (s *senseBarrier) NumberWaiting() int {
+	count := int(atomic.LoadUint64(&s.state) & senseCountMask)
+	if count > s.parties {
+		return s.parties
+	}
+	return count
+}
+
+func (s *senseBarrier) IsDrained() bool {
+	return atomic.LoadUint64(&s.state)&(senseCountMask|senseBroken) == 0
+}
+
+func (s *senseBarrier) Round() uint64 {
+	return s.b.Round()
+}
+
+func (s *senseBarrier) RoundNumber() uint64 {
+	return s.Round()
+}
+
+func (s *senseBarrier) Stats() Stats {
+	return s.b.Stats()
+}
+
+func (s *senseBarrier) Snapshot() State {
+	b := s.b
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	state := atomic.LoadUint64(&s.state)
+	res := State{
+		Participants: s.parties,
+		Waiting:      s.NumberWaiting(),
+		Round:        b.rounds,
+		Broken:       state&(senseBroken|senseClosed) != 0,
+		Closed:       b.closed,
+		CreatedAt:    b.createdAt,
+		LastTripAt:   b.stats.LastCompletion,
+	}
+	if state&senseBroken != 0 {
+		b.parking.Lock()
+		res.BrokenCause = s.errs[generation(state)&1]
+		b.parking.Unlock()
+	}
+	if res.BrokenCause == nil && b.closed {
+		res.BrokenCause = ErrClosed
+	}
+	return res
+}
+
+// DebugDump writes the generation of s, whose arrivals are only counted.
+func (s *senseBarrier) DebugDump(w io.Writer) {
+	state := atomic.LoadUint64(&s.state)
+	arrived := s.NumberWaiting()
+	fmt.Fprintf(w, "round %d: %d of %d parties arrived, %d missing, broken: %t\n",
+		s.Round(), arrived, s.parties, s.parties-arrived, state&(senseBroken|senseClosed) != 0)
+}
+
+// LastReleaseFanoutDuration returns how long the latest reversal took to
+// wake the waiting goroutines.
+func (s *senseBarrier) LastReleaseFanoutDuration() time.Duration {
+	return s.b.LastReleaseFanoutDuration()
+}
+
+func (s *senseBarrier) String() string {
+	var name string
+	if s.b.name != "" {
+		name = "name:" + s.b.name + " "
+	}
+	return fmt.Sprintf("Barrier{%sparticipants:%d waiting:%d broken:%t round:%d}",
+		name, s.parties, s.NumberWaiting(), s.IsBroken(), s.Round())
+}
+
+func (s *senseBarrier) Participants() int {
+	return s.parties
+}
+
+func (s *senseBarrier) Parties() int {
+	return s.parties
+}
+
+// refreshAction applies the actions of b from the next trip.
+func (s *senseBarrier) refreshAction() {
+	s.b.lock.Lock()
+	s.action = chain(s.b.actions)
+	s.b.lock.Unlock()
+}
+
+func (s *senseBarrier) SetAction(action func()) Barrier {
+	s.b.SetAction(action)
+	s.refreshAction()
+	return s
+}
+
+func (s *senseBarrier) SetActionE(action func() error) Barrier {
+	s.b.SetActionE(action)
+	s.refreshAction()
+	return s
+}
+
+func (s *senseBarrier) SetActionCtx(action func(context.Context) error) Barrier {
+	s.b.SetActionCtx(action)
+	s.refreshAction()
+	return s
+}
+
+func (s *senseBarrier) AddAction(action func() error) Barrier {
+	s.b.AddAction(action)
+	s.refreshAction()
+	return s
+}
+
+func (s *senseBarrier) OnBroken(hook func(cause error)) Barrier {
+	s.b.OnBroken(hook)
+	return s
+}
+
+func (s *senseBarrier) OnRelease(hook func(RoundInfo)) Barrier {
+	s.b.OnRelease(hook)
+	return s
+}
+
+// SetObserver sets the observer, which is notified of the trips and the
+// breaks, but not the arrivals.
+func (s *senseBarrier) SetObserver(o Observer) Barrier {
+	s.b.SetObserver(o)
+	return s
+}
+
+func (s *senseBarrier) Events() <-chan RoundEvent {
+	return s.b.Events()
+}
+
+func (s *senseBarrier) Subscribe(buffer int, policy DropPolicy, kinds EventKind) <-chan RoundEvent {
+	return s.b.Subscribe(buffer, policy, kinds)
+}
+
+func (s *senseBarrier) Unsubscribe(events <-chan RoundEvent) {
+	s.b.Unsubscribe(events)
+}
+
+// SetRoundTimeout records ErrNotSupported if d > 0, because the generations
+// have no timer. The later arrivals return it without arriving, until the
+// timeout is removed by SetRoundTimeout(0). Use WaitTimeout instead.
+func (s *senseBarrier) SetRoundTimeout(d time.Duration) Barrier {
+	var timeout int32
+	if d > 0 {
+		timeout = 1
+	}
+	atomic.StoreInt32(&s.timeout, timeout)
+	return s
+}
+
+// Resize returns ErrNotSupported, because the parties are fixed by New.
+func (s *senseBarrier) Resize(int) error {
+	return ErrNotSupported
+}
+
+// Register returns a Participant, whose calls return ErrNotSupported,
+// because the parties are fixed by New.
+func (s *senseBarrier) Register() Participant {
+	return s.RegisterAs("")
+}
+
+func (s *senseBarrier) RegisterAs(string) Participant {
+	return unsupported{}
+}
+
+// Deregister returns ErrNotSupported like Register.
+func (s *senseBarrier) Deregister() error {
+	return ErrNotSupported
+}
+
+// WaitAs returns ErrNotSupported, because the arrivals are only counted.
+func (s *senseBarrier) WaitAs(context.Context, string) error {
+	return ErrNotSupported
+}
+
+// WaitExchange returns ErrNotSupported, because the generations keep
+// nothing but their errors.
+func (s *senseBarrier) WaitExchange(context.Context, interface{}) ([]interface{}, error) {
+	return nil, ErrNotSupported
+}
+
+// WaitAction returns ErrNotSupported, because the action of a generation
+// is not known until it trips.
+func (s *senseBarrier) WaitAction(context.Context, func() error) error {
+	return ErrNotSupported
+}
+
+// WaitN returns ErrNotSupported, because every generation waits all the
+// parties.
+func (s *senseBarrier) WaitN(context.Context, int) error {
+	return ErrNotSupported
+}
+
+// WaitWeighted returns ErrNotSupported like WaitN.
+func (s *senseBarrier) WaitWeighted(context.Context, int) error {
+	return ErrNotSupported
+}
+
+// Arrive returns ErrNotSupported, because the goroutines waiting for a
+// generation are not known.
+func (s *senseBarrier) Arrive() (int, error) {
+	return 0, ErrNotSupported
+}
+
+// AwaitRelease returns ErrNotSupported like Arrive.
+func (s *senseBarrier) AwaitRelease(context.Context, int) error {
+	return ErrNotSupported
+}
+
+// unsupported is the Participant of a barrier whose parties are fixed,
+// all its calls return ErrNotSupported.
+type unsupported struct{}
+
+func (unsupported) Wait(context.Context) error {
+	return ErrNotSupported
+}
+
+func (unsupported) Arrive() error {
+	return ErrNotSupported
+}
+
+func (unsupported) ArriveAndWait(context.Context) error {
+	return ErrNotSupported
+}
+
+func (unsupported) ArriveAndDeregister() error {
+	return ErrNotSupported
+}
+
+func (unsupported) Deregister() error {
+	return ErrNotSupported
+}
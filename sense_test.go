@@ -0,0 +1,249 @@
+package barrier
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSenseReversing(t *testing.T) {
+	Convey("假设 Barrier 是 SenseReversing 实现的", t, func() {
+		parties := 8
+		var actions, incomplete int32
+		var arrived int32
+		b := New(parties, WithImplementation(SenseReversing), WithAction(func() error {
+			// 所有的参与者都到达后，才执行 action
+			if atomic.SwapInt32(&arrived, 0) != int32(parties) {
+				atomic.AddInt32(&incomplete, 1)
+			}
+			atomic.AddInt32(&actions, 1)
+			return nil
+		}))
+		wait := func() error {
+			atomic.AddInt32(&arrived, 1)
+			return b.Wait(context.TODO())
+		}
+
+		Convey("一轮接着一轮，每一轮都等所有的参与者到达", func() {
+			rounds := 2000
+			So(waitAll(parties, rounds, wait), ShouldBeEmpty)
+			So(atomic.LoadInt32(&incomplete), ShouldEqual, 0)
+			So(atomic.LoadInt32(&actions), ShouldEqual, rounds)
+			So(b.Round(), ShouldEqual, rounds)
+			So(b.Stats().CompletedRounds, ShouldEqual, rounds)
+			So(b.Stats().PartiesServed, ShouldEqual, parties*rounds)
+			So(b.IsDrained(), ShouldBeTrue)
+		})
+
+		Convey("有的参与者用可以取消的 context，或者超时等待，每一轮也都能完成", func() {
+			rounds := 500
+			var n int32
+			So(waitAll(parties, rounds, func() error {
+				atomic.AddInt32(&arrived, 1)
+				switch atomic.AddInt32(&n, 1) % 3 {
+				case 0:
+					ctx, cancel := context.WithCancel(context.Background())
+					defer cancel()
+					return b.Wait(ctx)
+				case 1:
+					return b.WaitTimeout(time.Minute)
+				}
+				return b.Wait(context.TODO())
+			}), ShouldBeEmpty)
+			So(atomic.LoadInt32(&incomplete), ShouldEqual, 0)
+			So(b.Round(), ShouldEqual, rounds)
+		})
+
+		Convey("每个参与者的 WaitIndexed 都不一样", func() {
+			indices := make(chan int, parties)
+			So(waitAll(parties, 1, func() error {
+				atomic.AddInt32(&arrived, 1)
+				index, err := b.WaitIndexed(context.TODO())
+				indices <- index
+				return err
+			}), ShouldBeEmpty)
+			close(indices)
+			var all []int
+			for index := range indices {
+				all = append(all, index)
+			}
+			sort.Ints(all)
+			for i := range all {
+				So(all[i], ShouldEqual, i+1)
+			}
+		})
+
+		Convey("一个参与者的 context 被取消后，这一轮的参与者都返回错误", func() {
+			errs := make(chan error, parties)
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				errs <- b.Wait(ctx)
+			}()
+			for i := 2; i < parties; i++ {
+				go func() {
+					errs <- b.Wait(context.TODO())
+				}()
+			}
+			for b.NumberWaiting() < parties-1 {
+				runtime.Gosched()
+			}
+			cancel()
+			for i := 1; i < parties; i++ {
+				So(<-errs, shouldBeBrokenBy, context.Canceled)
+			}
+			So(b.IsBroken(), ShouldBeTrue)
+
+			Convey("最后到达的参与者完成这一轮，下一轮可以正常完成", func() {
+				So(b.Wait(context.TODO()), shouldBeBrokenBy, context.Canceled)
+				So(b.IsBroken(), ShouldBeFalse)
+				So(b.Stats().BrokenRounds, ShouldEqual, 1)
+				So(waitAll(parties, 3, wait), ShouldBeEmpty)
+				So(b.Round(), ShouldEqual, 4)
+			})
+		})
+
+		Convey("超时以后，这一轮被打破", func() {
+			goWait(b)
+			So(b.WaitTimeout(time.Millisecond), shouldBeBrokenBy, ErrTimeout)
+		})
+
+		Convey("Break 以后，等待中的参与者都返回 ErrBroken", func() {
+			errs := make(chan error, 2)
+			for i := 0; i < 2; i++ {
+				go func() {
+					errs <- b.Wait(context.TODO())
+				}()
+			}
+			for b.NumberWaiting() < 2 {
+				runtime.Gosched()
+			}
+			b.Break()
+			So(<-errs, shouldBeBrokenBy, ErrBroken)
+			So(<-errs, shouldBeBrokenBy, ErrBroken)
+		})
+
+		Convey("Reset 以后，等待中的参与者返回 ErrBroken，下一轮可以正常完成", func() {
+			errs := make(chan error, 1)
+			go func() {
+				errs <- b.Wait(context.TODO())
+			}()
+			for b.NumberWaiting() < 1 {
+				runtime.Gosched()
+			}
+			b.Reset()
+			So(<-errs, shouldBeBrokenBy, ErrBroken)
+			So(b.IsDrained(), ShouldBeTrue)
+			atomic.StoreInt32(&arrived, 0)
+			So(waitAll(parties, 2, wait), ShouldBeEmpty)
+			So(b.Stats().CompletedRounds, ShouldEqual, 2)
+			So(b.Stats().BrokenRounds, ShouldEqual, 1)
+		})
+
+		Convey("Close 以后，等待中的和以后到达的参与者都返回 ErrClosed", func() {
+			goWait(b)
+			errs := make(chan error, 1)
+			go func() {
+				errs <- b.Wait(context.TODO())
+			}()
+			for b.NumberWaiting() < 2 {
+				runtime.Gosched()
+			}
+			So(b.Close(), ShouldBeNil)
+			So(<-errs, ShouldEqual, ErrClosed)
+			So(b.Wait(context.TODO()), ShouldEqual, ErrClosed)
+			So(b.IsBroken(), ShouldBeTrue)
+		})
+
+		Convey("需要知道到达者的方法返回 ErrNotSupported", func() {
+			So(b.WaitAs(context.TODO(), "a"), ShouldEqual, ErrNotSupported)
+			_, err := b.WaitExchange(context.TODO(), 1)
+			So(err, ShouldEqual, ErrNotSupported)
+			_, err = b.Arrive()
+			So(err, ShouldEqual, ErrNotSupported)
+			So(b.Resize(parties+1), ShouldEqual, ErrNotSupported)
+			So(b.Register().Wait(context.TODO()), ShouldEqual, ErrNotSupported)
+			So(b.NumberWaiting(), ShouldEqual, 0)
+		})
+
+		Convey("SetRoundTimeout 以后，到达的参与者返回 ErrNotSupported，直到超时被取消", func() {
+			So(b.SetRoundTimeout(time.Second), ShouldEqual, b)
+			So(b.Wait(context.TODO()), ShouldEqual, ErrNotSupported)
+			So(b.NumberWaiting(), ShouldEqual, 0)
+			b.SetRoundTimeout(0)
+			So(waitAll(parties, 1, wait), ShouldBeEmpty)
+		})
+	})
+
+	Convey("SenseReversing 的 action panic 时，最后到达的参与者返回 panic", t, func() {
+		b := New(2, WithImplementation(SenseReversing)).SetAction(func() {
+			panic("boom")
+		})
+		errs := make(chan error, 1)
+		go func() {
+			errs <- b.Wait(context.TODO())
+		}()
+		for b.NumberWaiting() < 1 {
+			runtime.Gosched()
+		}
+		So(errors.Is(b.Wait(context.TODO()), ErrActionPanic), ShouldBeTrue)
+		So(<-errs, shouldBeBrokenBy, ErrBroken)
+		So(b.Stats().BrokenRounds, ShouldEqual, 1)
+	})
+
+	Convey("SenseReversing 不支持的选项，New 时会 panic", t, func() {
+		for name, opt := range map[string]Option{
+			"WithStickyBroken":    WithStickyBroken(),
+			"WithRoundTimeout":    WithRoundTimeout(time.Second),
+			"WithCancelPolicy":    WithCancelPolicy(LeaveQuietly),
+			"WithStaggeredWakeup": WithStaggeredWakeup(2),
+			"WithWatchdog":        WithWatchdog(time.Second, func(Diagnostics) {}),
+			"WithSharding":        WithSharding(),
+			"WithAutoRecover":     WithAutoRecover(time.Second),
+			"WithOverflowQueue":   WithOverflowQueue(),
+			"WithStallWarning":    WithStallWarning(time.Second, func(Stall) {}),
+		} {
+			So(func() {
+				New(4, WithImplementation(SenseReversing), opt)
+			}, ShouldPanicWith, senseUnsupported+name)
+		}
+	})
+
+	Convey("落后两代的参与者拿不到自己那一代的结果，会 panic 或者返回 ErrTooManyParties", t, func() {
+		for _, panicOnOverflow := range []bool{true, false} {
+			opts := []Option{WithImplementation(SenseReversing)}
+			if !panicOnOverflow {
+				opts = append(opts, WithoutOverflowPanic())
+			}
+			s := New(2, opts...).(*senseBarrier)
+			// 其他的参与者用 TryArrive 完成了第 0、1、2 代
+			for i := 0; i < 6; i++ {
+				s.TryArrive()
+			}
+			So(s.Round(), ShouldEqual, 3)
+			So(s.errOf(1), ShouldBeNil)
+			if panicOnOverflow {
+				So(func() { s.errOf(0) }, ShouldPanicWith, senseLagged)
+			} else {
+				So(s.errOf(0), ShouldEqual, ErrTooManyParties)
+			}
+		}
+	})
+
+	Convey("SenseReversing 的 Barrier 一轮接着一轮，不会分配内存", t, func() {
+		b := New(1, WithImplementation(SenseReversing)).SetAction(func() {})
+		allocs := testing.AllocsPerRun(100, func() {
+			b.Wait(context.Background())
+		})
+		So(allocs, ShouldEqual, 0)
+	})
+}
+
+func Benchmark_Cycle_64_SenseReversing(b *testing.B) {
+	cycle(b, 64, New(64, WithImplementation(SenseReversing)).Wait)
+}
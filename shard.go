@@ -0,0 +1,50 @@
+package barrier
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotShardable will be returned by WaitShard, if the Barrier is not
+// created by New.
+var ErrNotShardable = errors.New("barrier is not created by barrier.New")
+
+// WaitShard is Wait for scatter-gather.
+// Every participant contributes a value by contribute() when it arrives.
+// After all participants have arrived, every participant receives its own
+// view of all contributions by distribute(all, myIndex).
+// all is in arrival order, and myIndex is the arrival index of the caller,
+// starting from 0.
+// If the round is broken, distribute is not called, and WaitShard returns
+// the zero value of T with the error of Wait.
+// A participant of the round calling Wait or Break contributes the zero value.
+func WaitShard[T any](ctx context.Context, b Barrier, contribute func() T, distribute func(all []T, myIndex int) T) (T, error) {
+	var zero T
+	bp, ok := b.(*barrier)
+	if !ok {
+		return zero, ErrNotShardable
+	}
+	out, count, err := bp.wait(ctx, box[T]{contribute()}, 0, 0)
+	if err != nil {
+		return zero, err
+	}
+	// every participant has its own copy, distribute can not disturb others.
+	return distribute(unbox[T](out.values), count-1), nil
+}
+
+// box wraps a contribution, so that even a nil one is not missing.
+type box[T any] struct {
+	v T
+}
+
+// unbox converts the contributions of a round to []T,
+// with the zero value for the missing ones.
+func unbox[T any](values []interface{}) []T {
+	all := make([]T, len(values))
+	for i, v := range values {
+		if b, ok := v.(box[T]); ok {
+			all[i] = b.v
+		}
+	}
+	return all
+}
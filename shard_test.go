@@ -0,0 +1,67 @@
+package barrier
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWaitShard(t *testing.T) {
+	Convey("假设有 4 个参与者，每个参与者贡献自己的编号", t, func() {
+		participants := 4
+		b := New(participants)
+		sum := func(all []int, myIndex int) int {
+			res := 0
+			for _, v := range all {
+				res += v
+			}
+			return res*10 + myIndex
+		}
+
+		Convey("每个参与者都会得到属于自己的那一份", func() {
+			results := make([]int, participants)
+			var wg sync.WaitGroup
+			wg.Add(participants)
+			for i := 0; i < participants; i++ {
+				go func(id int) {
+					res, err := WaitShard(context.TODO(), b, func() int { return id + 1 }, sum)
+					if err == nil {
+						results[id] = res
+					}
+					wg.Done()
+				}(i)
+			}
+			wg.Wait()
+
+			indexes := make(map[int]bool, participants)
+			for _, res := range results {
+				So(res/10, ShouldEqual, 1+2+3+4)
+				indexes[res%10] = true
+			}
+			So(len(indexes), ShouldEqual, participants)
+		})
+
+		Convey("如果有参与者 Break 了", func() {
+			errCh := make(chan error, 1)
+			go func() {
+				_, err := WaitShard(context.TODO(), b, func() int { return 1 }, sum)
+				errCh <- err
+			}()
+			goWait(b)
+			goWait(b)
+			b.Break()
+			Convey("WaitShard 会返回 ErrBroken", func() {
+				So(<-errCh, shouldBeBrokenBy, ErrBroken)
+			})
+		})
+
+		Convey("如果 Barrier 不是由 New 创建的", func() {
+			_, err := WaitShard(context.TODO(), Barrier(nil), func() int { return 1 }, sum)
+			Convey("WaitShard 会返回 ErrNotShardable", func() {
+				So(err, ShouldEqual, ErrNotShardable)
+			})
+		})
+	})
+}
@@ -0,0 +1,169 @@
+package barrier
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// WithSharding splits the arrival counter of the barrier into GOMAXPROCS
+// shards, each on its own cache line, so that the arrivals on different
+// processors do not contend on a single cache line. The shards take all
+// the slots of a round but the last one, and the last arrival combines
+// them into the round, and trips it.
+// Only the arrivals of Wait and the variants without contribution, name
+// or quorum are sharded, while the barrier has no observer, subscriber,
+// timer or quorum. The other arrivals take the lock.
+// The index returned by WaitIndexed is the slot of the arrival, which is
+// unique in the round, but not the order of arrival.
+func WithSharding() Option {
+	return func(b *barrier) {
+		b.shards = make([]shard, runtime.GOMAXPROCS(0))
+		for i := range b.shards {
+			b.shards[i].state = shardFrozen
+		}
+		b.stripes.New = func() interface{} {
+			stripe := atomic.AddUint32(&b.stripe, 1)
+			return &stripe
+		}
+	}
+}
+
+// The layout of shard.state. count is the number of the arrivals taken the
+// slots of the shard, frozen stops them, epoch changes every time the shard
+// is thawed, and generation is the one of the round laid out in the shard.
+const (
+	shardFrozen = 1 << countBits
+	epochShift  = countBits + 1
+	epochMask   = 1<<(generationShift-epochShift) - 1
+)
+
+// cacheLine is the size of the cache line, which the shards are padded to.
+const cacheLine = 64
+
+// shard is a part of the slots of the current round, which the arrivals
+// on the processors striped to it take.
+type shard struct {
+	state   uint64 // generation, epoch, frozen and count, atomic
+	size    int32  // slots of the shard, atomic
+	base    int32  // slots of the round before the shard, atomic
+	drained int32  // count combined into the round already, atomic
+	_       [cacheLine - 20]byte
+}
+
+// pending returns the count of s, which is not combined into the round of
+// generation yet.
+func (s *shard) pending(generation uint64) int {
+	state := atomic.LoadUint64(&s.state)
+	if state>>generationShift != generation {
+		return 0
+	}
+	return int(int32(state&countMask) - atomic.LoadInt32(&s.drained))
+}
+
+// arriveShard takes a slot of the round of generation, starting from the
+// shard striped to the processor, and returns the 1-based slot.
+// It is not ok, if the shards are frozen, full, or laid out for another
+// round, and the arrival should take the lock instead.
+func (b *barrier) arriveShard(generation uint64) (slot int, ok bool) {
+	p := b.stripes.Get().(*uint32)
+	stripe := *p
+	b.stripes.Put(p)
+	for i := uint32(0); i < uint32(len(b.shards)); i++ {
+		s := &b.shards[(stripe+i)%uint32(len(b.shards))]
+		for {
+			state := atomic.LoadUint64(&s.state)
+			if state&shardFrozen != 0 || state>>generationShift != generation {
+				return 0, false
+			}
+			count := int32(state & countMask)
+			size, base := atomic.LoadInt32(&s.size), atomic.LoadInt32(&s.base)
+			if count >= size {
+				// full, try the next one.
+				break
+			}
+			if atomic.CompareAndSwapUint64(&s.state, state, state+1) {
+				return int(base + count + 1), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// freezeShards stops the arrivals of the shards, and combines their counts
+// into r, so that the count of r is exact with the lock held.
+// It should be called with b.lock held.
+func (b *barrier) freezeShards(r *round) {
+	var drained int
+	for i := range b.shards {
+		s := &b.shards[i]
+		for {
+			state := atomic.LoadUint64(&s.state)
+			if state&shardFrozen != 0 || atomic.CompareAndSwapUint64(&s.state, state, state|shardFrozen) {
+				count := int32(state & countMask)
+				drained += int(count - atomic.LoadInt32(&s.drained))
+				atomic.StoreInt32(&s.drained, count)
+				break
+			}
+		}
+	}
+	r.add(drained, drained)
+}
+
+// thawShards allows the arrivals of the shards. The slots of r, but the
+// arrived ones and the last one, are laid out in the shards, the first time
+// r is thawed.
+// It should be called with b.lock held.
+func (b *barrier) thawShards(r *round) {
+	if !r.sharded {
+		r.sharded = true
+		generation := atomic.LoadUint64(&r.state) >> generationShift
+		base := r.count()
+		slots := r.parties - 1 - base
+		if slots < 0 {
+			slots = 0
+		}
+		for i := range b.shards {
+			size := slots / len(b.shards)
+			if i < slots%len(b.shards) {
+				size++
+			}
+			s := &b.shards[i]
+			atomic.StoreInt32(&s.size, int32(size))
+			atomic.StoreInt32(&s.base, int32(base))
+			atomic.StoreInt32(&s.drained, 0)
+			state := atomic.LoadUint64(&s.state)
+			atomic.StoreUint64(&s.state, generation<<generationShift|state&(epochMask<<epochShift)|shardFrozen)
+			base += size
+		}
+	}
+	for i := range b.shards {
+		s := &b.shards[i]
+		state := atomic.LoadUint64(&s.state)
+		epoch := (state>>epochShift + 1) & epochMask
+		atomic.StoreUint64(&s.state, state&^(shardFrozen|epochMask<<epochShift)|epoch<<epochShift)
+	}
+}
+
+// steal takes a free slot of the shards for an arrival with the lock, so
+// that the shards do not take more arrivals than the slots of r.
+// It returns 0 if there is no free slot, because some arrivals have left.
+// It should be called with b.lock held.
+func (b *barrier) steal(r *round) int {
+	for i := len(b.shards) - 1; i >= 0; i-- {
+		s := &b.shards[i]
+		size := atomic.LoadInt32(&s.size)
+		if int32(atomic.LoadUint64(&s.state)&countMask) < size {
+			atomic.StoreInt32(&s.size, size-1)
+			r.stolen = i
+			return int(atomic.LoadInt32(&s.base) + size)
+		}
+	}
+	return 0
+}
+
+// unsteal gives the latest slot taken by steal back to its shard.
+// It should be called with b.lock held.
+func (b *barrier) unsteal(r *round) {
+	s := &b.shards[r.stolen]
+	atomic.StoreInt32(&s.size, atomic.LoadInt32(&s.size)+1)
+}
@@ -0,0 +1,165 @@
+package barrier
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// waitAll lets every one of parties goroutines call wait rounds times, and
+// returns all the errors.
+func waitAll(parties, rounds int, wait func() error) []error {
+	var wg sync.WaitGroup
+	errs := make(chan error, parties*rounds)
+	for p := 0; p < parties; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				if err := wait(); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	var all []error
+	for err := range errs {
+		all = append(all, err)
+	}
+	return all
+}
+
+func TestWithSharding(t *testing.T) {
+	// more shards than the processors of the machine, if it has few.
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	Convey("假设 Barrier 的到达计数被分片了", t, func() {
+		parties := 16
+		var actions int32
+		b := New(parties, WithSharding(), WithAction(func() error {
+			atomic.AddInt32(&actions, 1)
+			return nil
+		}))
+
+		Convey("所有的参与者都到达后，才完成一轮", func() {
+			rounds := 200
+			So(waitAll(parties, rounds, func() error {
+				return b.Wait(context.TODO())
+			}), ShouldBeEmpty)
+			So(b.Round(), ShouldEqual, rounds)
+			So(atomic.LoadInt32(&actions), ShouldEqual, rounds)
+			So(b.Stats().PartiesServed, ShouldEqual, parties*rounds)
+		})
+
+		Convey("分片里的到达也算在 NumberWaiting 里", func() {
+			for i := 1; i < parties; i++ {
+				goWait(b)
+			}
+			for b.NumberWaiting() < parties-1 {
+				runtime.Gosched()
+			}
+			bb := b.(*barrier)
+			bb.lock.RLock()
+			So(bb.round.sharded, ShouldBeTrue)
+			bb.lock.RUnlock()
+			So(b.Resize(parties+1), ShouldEqual, ErrRoundInProgress)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			So(b.Round(), ShouldEqual, 1)
+		})
+
+		Convey("每个参与者的 WaitIndexed 都不一样，最后到达的是 participants", func() {
+			indices := make(chan int, parties)
+			var lastIndex int32
+			So(waitAll(parties, 1, func() error {
+				index, err := b.WaitIndexed(context.TODO())
+				indices <- index
+				return err
+			}), ShouldBeEmpty)
+			close(indices)
+			var all []int
+			for index := range indices {
+				all = append(all, index)
+				if index == parties {
+					atomic.AddInt32(&lastIndex, 1)
+				}
+			}
+			sort.Ints(all)
+			for i := range all {
+				So(all[i], ShouldEqual, i+1)
+			}
+			So(atomic.LoadInt32(&lastIndex), ShouldEqual, 1)
+		})
+
+		Convey("和加锁的到达混在一起时，每一轮都能完成", func() {
+			rounds := 100
+			stop := make(chan struct{})
+			toggled := make(chan struct{})
+			go func() {
+				defer close(toggled)
+				o := &recorder{}
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					b.SetObserver(o)
+					b.SetObserver(nil)
+					runtime.Gosched()
+				}
+			}()
+			var n int32
+			errs := waitAll(parties, rounds, func() error {
+				// some of the arrivals take the lock.
+				if atomic.AddInt32(&n, 1)%5 == 0 {
+					token, err := b.Arrive()
+					if err != nil {
+						return err
+					}
+					return b.AwaitRelease(context.TODO(), token)
+				}
+				return b.Wait(context.TODO())
+			})
+			close(stop)
+			<-toggled
+			So(errs, ShouldBeEmpty)
+			So(b.Round(), ShouldEqual, rounds)
+			So(b.Stats().PartiesServed, ShouldEqual, parties*rounds)
+		})
+	})
+
+	Convey("分片的 Scatter，每个参与者都拿到不同的元素", t, func() {
+		parties := 8
+		s := NewScatter(parties, func() ([]int, error) {
+			all := make([]int, parties)
+			for i := range all {
+				all[i] = i
+			}
+			return all, nil
+		}, WithSharding())
+		got := make(chan int, parties)
+		So(waitAll(parties, 1, func() error {
+			v, err := s.Wait(context.TODO())
+			got <- v
+			return err
+		}), ShouldBeEmpty)
+		close(got)
+		seen := make(map[int]bool)
+		for v := range got {
+			seen[v] = true
+		}
+		So(len(seen), ShouldEqual, parties)
+	})
+}
+
+func Benchmark_Cycle_64_Sharding(b *testing.B) {
+	cycle(b, 64, New(64, WithSharding()).Wait)
+}
@@ -0,0 +1,73 @@
+package barrier
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// State is a snapshot of the state of a Barrier.
+type State struct {
+	Participants int
+	Waiting      int    // goroutines arrived in the current round
+	Round        uint64 // completed rounds
+	Broken       bool
+	BrokenCause  error     // why the barrier is broken, nil if not broken
+	Closed       bool      // Close has been called
+	CreatedAt    time.Time // when the barrier was created
+	LastTripAt   time.Time // when the latest round completed successfully, zero if none
+}
+
+// Snapshotter is implemented by the Barrier returned by New, whose state
+// can be dumped at once. Check it by a type assertion, like FanoutReporter.
+type Snapshotter interface {
+	// Snapshot returns a snapshot of the state of the barrier.
+	Snapshot() State
+}
+
+// MarshalJSON implements json.Marshaler. BrokenCause is marshaled as its
+// message, and the zero LastTripAt is omitted.
+func (s State) MarshalJSON() ([]byte, error) {
+	var v struct {
+		Participants int        `json:"participants"`
+		Waiting      int        `json:"waiting"`
+		Round        uint64     `json:"round"`
+		Broken       bool       `json:"broken"`
+		BrokenCause  string     `json:"broken_cause,omitempty"`
+		Closed       bool       `json:"closed"`
+		CreatedAt    time.Time  `json:"created_at"`
+		LastTripAt   *time.Time `json:"last_trip_at,omitempty"`
+	}
+	v.Participants = s.Participants
+	v.Waiting = s.Waiting
+	v.Round = s.Round
+	v.Broken = s.Broken
+	if s.BrokenCause != nil {
+		v.BrokenCause = s.BrokenCause.Error()
+	}
+	v.Closed = s.Closed
+	v.CreatedAt = s.CreatedAt
+	if !s.LastTripAt.IsZero() {
+		v.LastTripAt = &s.LastTripAt
+	}
+	return json.Marshal(v)
+}
+
+func (b *barrier) Snapshot() State {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	s := State{
+		Participants: b.participants,
+		Waiting:      b.round.count(),
+		Round:        b.rounds,
+		Closed:       b.closed,
+		CreatedAt:    b.createdAt,
+		LastTripAt:   b.stats.LastCompletion,
+	}
+	switch {
+	case b.round.isBroken:
+		s.Broken, s.BrokenCause = true, b.round.cause()
+	case b.stuck != nil:
+		s.Broken, s.BrokenCause = true, b.stuck
+	}
+	return s
+}
@@ -0,0 +1,62 @@
+package barrier
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSnapshot(t *testing.T) {
+	Convey("假设 Barrier 有 2 个参与者", t, func() {
+		before := time.Now()
+		b := New(2)
+
+		Convey("新建的 Barrier 的快照", func() {
+			s := b.(Snapshotter).Snapshot()
+			So(s.Participants, ShouldEqual, 2)
+			So(s.Waiting, ShouldEqual, 0)
+			So(s.Round, ShouldEqual, 0)
+			So(s.Broken, ShouldBeFalse)
+			So(s.BrokenCause, ShouldBeNil)
+			So(s.CreatedAt, ShouldHappenOnOrAfter, before)
+			So(s.LastTripAt.IsZero(), ShouldBeTrue)
+
+			Convey("转换成 JSON 时，省略了没有的原因和完成时间", func() {
+				data, err := json.Marshal(s)
+				So(err, ShouldBeNil)
+				var m map[string]interface{}
+				So(json.Unmarshal(data, &m), ShouldBeNil)
+				So(m["participants"], ShouldEqual, 2)
+				So(m["broken"], ShouldEqual, false)
+				So(m, ShouldNotContainKey, "broken_cause")
+				So(m, ShouldNotContainKey, "last_trip_at")
+				So(m, ShouldContainKey, "created_at")
+			})
+		})
+
+		Convey("完成一轮以后，又被 Break 的快照", func() {
+			goWait(b)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			b.Break()
+			s := b.(Snapshotter).Snapshot()
+			So(s.Round, ShouldEqual, 1)
+			So(s.Waiting, ShouldEqual, 1)
+			So(s.Broken, ShouldBeTrue)
+			So(s.BrokenCause, shouldBeBrokenBy, ErrBroken)
+			So(s.LastTripAt.IsZero(), ShouldBeFalse)
+
+			Convey("转换成 JSON 时，原因是它的信息", func() {
+				data, err := json.Marshal(s)
+				So(err, ShouldBeNil)
+				var m map[string]interface{}
+				So(json.Unmarshal(data, &m), ShouldBeNil)
+				So(m["broken_cause"], ShouldEqual, s.BrokenCause.Error())
+				So(m["round"], ShouldEqual, 1)
+				So(m, ShouldContainKey, "last_trip_at")
+			})
+		})
+	})
+}
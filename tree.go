@@ -0,0 +1,397 @@
+package barrier
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var _ Barrier = (*Tree)(nil)
+
+// Tree is a combining tree of barriers, for a large number of parties.
+// The parties are split into leaves of fanout parties. The last arrived
+// party of a leaf arrives its parent, whose parties are fanout leaves,
+// and so on up to the root. So the arrivals only contend on the counter of
+// their leaf, and the release fans out down the tree, instead of waking all
+// the parties waiting on a single barrier at once.
+// Once a round of a node is broken, the current rounds of all the nodes
+// are broken.
+//
+// Tree is a Barrier, whose arrivals are spread over the leaves, fanout
+// arrivals a leaf every round. The methods, which can not be combined
+// across the leaves, like WaitExchange and Register, return ErrNotSupported.
+type Tree struct {
+	arrivals uint64 // arrivals spread over the leaves, atomic
+	root     *barrier
+	nodes    []*member // below the root, from the top down to the leaves
+	leaves   []Barrier
+	parties  int
+	fanout   int
+	lock     sync.RWMutex
+	observer Observer // notified of the arrivals by the Tree
+}
+
+// NewTree initializes a new instance of the Tree for parties, whose
+// nodes have fanout parties at most.
+// opts are applied to the root, whose actions run after all the parties
+// arrive.
+// It panics if parties is not positive, or fanout is less than 2.
+func NewTree(parties, fanout int, opts ...Option) *Tree {
+	if parties <= 0 {
+		panic(nonPositiveParticipants)
+	}
+	if fanout < 2 {
+		panic("fanout is less than 2")
+	}
+	t := &Tree{
+		parties: parties,
+		fanout:  fanout,
+	}
+	// sizes of the nodes, from the leaves up to the root.
+	levels := [][]int{t.split(parties)}
+	for level := levels[0]; len(level) > 1; {
+		level = t.split(len(level))
+		levels = append(levels, level)
+	}
+	// more arrivals than a leaf has, which belong to the next round,
+	// wait for it.
+	overflow := WithOverflowQueue()
+	if len(levels) == 1 {
+		t.root = New(parties, append(opts[:len(opts):len(opts)], overflow)...).(*barrier)
+		t.leaves = []Barrier{t.root}
+	} else {
+		t.root = New(levels[len(levels)-1][0], opts...).(*barrier)
+	}
+	parents := []*barrier{t.root}
+	for l := len(levels) - 2; l >= 0; l-- {
+		var children []*barrier
+		var nodeOpts []Option
+		if l == 0 {
+			nodeOpts = []Option{overflow}
+		}
+		for i, size := range levels[l] {
+			b := New(size, nodeOpts...).(*barrier)
+			m := newMember(b, parents[i/fanout], t.root)
+			t.nodes = append(t.nodes, m)
+			if l == 0 {
+				t.leaves = append(t.leaves, m)
+			}
+			children = append(children, b)
+		}
+		parents = children
+	}
+	t.OnBroken(t.root.onBroken)
+	return t
+}
+
+// split returns the sizes of the nodes for parties, fanout parties a node.
+func (t *Tree) split(parties int) []int {
+	var sizes []int
+	for ; parties > t.fanout; parties -= t.fanout {
+		sizes = append(sizes, t.fanout)
+	}
+	return append(sizes, parties)
+}
+
+// breakAll breaks the current rounds of all the nodes below the root,
+// after the root is broken with cause.
+func (t *Tree) breakAll(cause error) {
+	err := causeOf(cause)
+	for _, n := range t.nodes {
+		n.breakCurrent(err, parentBroken{err})
+	}
+}
+
+// arrive arrives the leaf of the next arrival by arrive.
+func (t *Tree) arrive(ctx context.Context, arrive func(leaf Barrier) error) error {
+	if t.root.isActing(ctx) {
+		// the leaves do not know the action of the root.
+		return ErrReentrantWait
+	}
+	n := int((atomic.AddUint64(&t.arrivals, 1) - 1) % uint64(t.parties))
+	t.lock.RLock()
+	o := t.observer
+	t.lock.RUnlock()
+	if o != nil {
+		o.OnArrive(n + 1)
+	}
+	return arrive(t.leaves[n/t.fanout])
+}
+
+// Leaf returns the barrier of the leaf of the id-th party, in [0, parties).
+// Its actions run after the root trips, and its OnBroken hook is called
+// after it breaks the Tree, so that they never detach the leaf from the Tree.
+// The parties waiting the leaves should not wait the Tree as well, which
+// spreads its arrivals over the leaves in turn.
+func (t *Tree) Leaf(id int) Barrier {
+	return t.leaves[id/t.fanout]
+}
+
+func (t *Tree) Wait(ctx context.Context) error {
+	return t.arrive(ctx, func(leaf Barrier) error {
+		return leaf.Wait(ctx)
+	})
+}
+
+// WaitAs is Wait for the participant named name, but the duplicate
+// arrivals of name are only found in the same leaf.
+func (t *Tree) WaitAs(ctx context.Context, name string) error {
+	return t.arrive(ctx, func(leaf Barrier) error {
+		return leaf.WaitAs(ctx, name)
+	})
+}
+
+// WaitTimeout is Wait with a timeout, which breaks the round if the other
+// parties of the leaf do not arrive within d.
+func (t *Tree) WaitTimeout(d time.Duration) error {
+	return t.arrive(context.Background(), func(leaf Barrier) error {
+		return leaf.WaitTimeout(d)
+	})
+}
+
+func (t *Tree) Break() {
+	t.BreakCtx(context.Background())
+}
+
+func (t *Tree) BreakCtx(ctx context.Context) error {
+	return t.arrive(ctx, func(leaf Barrier) error {
+		return leaf.BreakCtx(ctx)
+	})
+}
+
+// WaitExchange returns ErrNotSupported, because the contributions are
+// only collected by the leaves.
+func (t *Tree) WaitExchange(context.Context, interface{}) ([]interface{}, error) {
+	return nil, ErrNotSupported
+}
+
+// WaitAction returns ErrNotSupported, because the last arrived party of
+// the Tree is not known by the leaves.
+func (t *Tree) WaitAction(context.Context, func() error) error {
+	return ErrNotSupported
+}
+
+// WaitIndexed returns ErrNotSupported, because the arrivals are only
+// indexed by the leaves.
+func (t *Tree) WaitIndexed(context.Context) (int, error) {
+	return 0, ErrNotSupported
+}
+
+// WaitIndex returns ErrNotSupported like WaitIndexed.
+func (t *Tree) WaitIndex(context.Context) (int, error) {
+	return 0, ErrNotSupported
+}
+
+// WaitN returns ErrNotSupported, because every leaf waits all its parties.
+func (t *Tree) WaitN(context.Context, int) error {
+	return ErrNotSupported
+}
+
+// WaitWeighted returns ErrNotSupported, because the weight may not fit
+// in a leaf.
+func (t *Tree) WaitWeighted(context.Context, int) error {
+	return ErrNotSupported
+}
+
+// Arrive returns ErrNotSupported, because the tokens are only known by
+// the leaves.
+func (t *Tree) Arrive() (int, error) {
+	return 0, ErrNotSupported
+}
+
+// AwaitRelease returns ErrNotSupported like Arrive.
+func (t *Tree) AwaitRelease(context.Context, int) error {
+	return ErrNotSupported
+}
+
+// TryWait returns ErrNotSupported, because the last arrived party of the
+// Tree is not known by the leaves.
+func (t *Tree) TryWait() (bool, error) {
+	return false, ErrNotSupported
+}
+
+// IsBroken reports whether the current round of the root is broken.
+func (t *Tree) IsBroken() bool {
+	return t.root.IsBroken()
+}
+
+// SetAction sets the action of the root, and so do the other methods
+// setting actions.
+func (t *Tree) SetAction(action func()) Barrier {
+	t.root.SetAction(action)
+	return t
+}
+
+func (t *Tree) SetActionE(action func() error) Barrier {
+	t.root.SetActionE(action)
+	return t
+}
+
+func (t *Tree) SetActionCtx(action func(context.Context) error) Barrier {
+	t.root.SetActionCtx(action)
+	return t
+}
+
+func (t *Tree) AddAction(action func() error) Barrier {
+	t.root.AddAction(action)
+	return t
+}
+
+// Reset resets all the nodes, from the root down to the leaves.
+func (t *Tree) Reset() {
+	t.root.Reset()
+	for _, n := range t.nodes {
+		n.Reset()
+	}
+	atomic.StoreUint64(&t.arrivals, 0)
+}
+
+// NumberWaiting returns the number of parties arrived in the current
+// rounds of the leaves.
+func (t *Tree) NumberWaiting() int {
+	var waiting int
+	for _, leaf := range t.leaves {
+		waiting += leaf.NumberWaiting()
+	}
+	return waiting
+}
+
+func (t *Tree) IsDrained() bool {
+	for _, n := range t.nodes {
+		if !n.IsDrained() {
+			return false
+		}
+	}
+	return t.root.IsDrained()
+}
+
+// Close closes all the nodes, from the root down to the leaves.
+func (t *Tree) Close() error {
+	t.root.Close()
+	for _, n := range t.nodes {
+		n.Close()
+	}
+	return nil
+}
+
+// Round returns the number of the completed rounds of the root.
+func (t *Tree) Round() uint64 {
+	return t.root.Round()
+}
+
+func (t *Tree) RoundNumber() uint64 {
+	return t.Round()
+}
+
+// Stats returns the statistics of the root, but PartiesServed counts the
+// arrivals of the leaves.
+func (t *Tree) Stats() Stats {
+	stats := t.root.Stats()
+	stats.PartiesServed = 0
+	for _, leaf := range t.leaves {
+		stats.PartiesServed += leaf.Stats().PartiesServed
+	}
+	return stats
+}
+
+func (t *Tree) String() string {
+	return fmt.Sprintf("Tree{participants:%d fanout:%d waiting:%d broken:%t round:%d}",
+		t.parties, t.fanout, t.NumberWaiting(), t.IsBroken(), t.Round())
+}
+
+func (t *Tree) Participants() int {
+	return t.parties
+}
+
+func (t *Tree) Parties() int {
+	return t.parties
+}
+
+// Resize returns ErrNotSupported, because the nodes are fixed by NewTree.
+func (t *Tree) Resize(int) error {
+	return ErrNotSupported
+}
+
+// SetRoundTimeout sets the round timeout of all the nodes, so that a node
+// breaks the Tree, if its parties do not arrive within d after its first
+// arrival.
+func (t *Tree) SetRoundTimeout(d time.Duration) Barrier {
+	t.root.SetRoundTimeout(d)
+	for _, n := range t.nodes {
+		n.SetRoundTimeout(d)
+	}
+	return t
+}
+
+// Register returns a Participant, whose calls return ErrNotSupported,
+// because the nodes are fixed by NewTree.
+func (t *Tree) Register() Participant {
+	return t.RegisterAs("")
+}
+
+func (t *Tree) RegisterAs(name string) Participant {
+	return &participant{b: t.root, name: name, err: ErrNotSupported}
+}
+
+// Deregister returns ErrNotSupported like Register.
+func (t *Tree) Deregister() error {
+	return ErrNotSupported
+}
+
+// OnBroken sets the hook of the root, which is called after the root
+// breaks the current rounds of all the nodes.
+func (t *Tree) OnBroken(hook func(cause error)) Barrier {
+	if hook == nil {
+		t.root.OnBroken(t.breakAll)
+		return t
+	}
+	t.root.OnBroken(func(cause error) {
+		t.breakAll(cause)
+		hook(cause)
+	})
+	return t
+}
+
+// OnRelease adds a hook of the root, whose info reports the parties of
+// the Tree.
+func (t *Tree) OnRelease(hook func(RoundInfo)) Barrier {
+	if hook == nil {
+		return t
+	}
+	t.root.OnRelease(func(info RoundInfo) {
+		info.Parties = t.parties
+		hook(info)
+	})
+	return t
+}
+
+// SetObserver sets the observer, which is notified of the arrivals by the
+// Tree, and of the trips and breaks by the root.
+// The durations of a DurationObserver are not reported.
+func (t *Tree) SetObserver(o Observer) Barrier {
+	t.lock.Lock()
+	t.observer = o
+	t.lock.Unlock()
+	if o == nil {
+		t.root.SetObserver(nil)
+	} else {
+		t.root.SetObserver(rootObserver{o})
+	}
+	return t
+}
+
+// rootObserver is the observer of the root, whose arrivals are the nodes
+// below it, so they are not reported.
+type rootObserver struct {
+	Observer
+}
+
+func (rootObserver) OnArrive(int) {}
+
+// Events returns the events of the root, whose Parties are the nodes
+// arrived at the root.
+func (t *Tree) Events() <-chan RoundEvent {
+	return t.root.Events()
+}
@@ -0,0 +1,184 @@
+package barrier
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTree(t *testing.T) {
+	Convey("参数不对的时候，NewTree 会 panic", t, func() {
+		So(func() { NewTree(0, 2) }, ShouldPanic)
+		So(func() { NewTree(10, 1) }, ShouldPanic)
+	})
+
+	Convey("假设 Tree 有 10 个参与者，每个节点最多 3 个参与者", t, func() {
+		parties := 10
+		var executed int32
+		tree := NewTree(parties, 3, WithAction(func() error {
+			atomic.AddInt32(&executed, 1)
+			return nil
+		}))
+		// run 让每个参与者都 Wait rounds 次，返回所有的 error
+		run := func(rounds int, ctxOf func(id int) context.Context) chan error {
+			var wg sync.WaitGroup
+			errs := make(chan error, parties*rounds)
+			for id := 0; id < parties; id++ {
+				wg.Add(1)
+				go func(id int) {
+					defer wg.Done()
+					for j := 0; j < rounds; j++ {
+						if err := tree.Wait(ctxOf(id)); err != nil {
+							errs <- err
+							return
+						}
+					}
+				}(id)
+			}
+			wg.Wait()
+			close(errs)
+			return errs
+		}
+		background := func(id int) context.Context {
+			return context.Background()
+		}
+
+		Convey("所有的参与者都到达后，Tree 才完成一轮", func() {
+			rounds := 5
+			So(len(run(rounds, background)), ShouldEqual, 0)
+			So(tree.Round(), ShouldEqual, rounds)
+			So(atomic.LoadInt32(&executed), ShouldEqual, rounds)
+			So(tree.Leaf(9).Participants(), ShouldEqual, 1)
+			So(tree.Leaf(9).Round(), ShouldEqual, rounds)
+		})
+
+		Convey("一个叶子的这一轮被打破时，所有的参与者都会返回错误", func() {
+			canceled, cancel := context.WithCancel(context.Background())
+			cancel()
+			errs := run(1, func(id int) context.Context {
+				if id == 4 {
+					return canceled
+				}
+				return context.Background()
+			})
+			So(len(errs), ShouldEqual, parties)
+			for err := range errs {
+				So(err, shouldBeBrokenBy, context.Canceled)
+			}
+
+			Convey("Reset 以后，Tree 可以继续使用", func() {
+				tree.Reset()
+				So(tree.IsBroken(), ShouldBeFalse)
+				round := tree.Round()
+				So(len(run(2, background)), ShouldEqual, 0)
+				So(tree.Round(), ShouldEqual, round+2)
+			})
+		})
+
+		Convey("Tree 可以当作 Barrier 使用", func() {
+			var b Barrier = tree
+			So(b.Participants(), ShouldEqual, parties)
+			errs := make(chan error, parties-1)
+			for i := 1; i < parties; i++ {
+				go func() {
+					errs <- b.Wait(context.TODO())
+				}()
+			}
+			for b.NumberWaiting() < parties-1 {
+				runtime.Gosched()
+			}
+			So(b.String(), ShouldEqual, "Tree{participants:10 fanout:3 waiting:9 broken:false round:0}")
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			for i := 1; i < parties; i++ {
+				So(<-errs, ShouldBeNil)
+			}
+			So(b.Round(), ShouldEqual, 1)
+			So(b.Stats().PartiesServed, ShouldEqual, parties)
+			So(b.IsDrained(), ShouldBeTrue)
+		})
+
+		Convey("不能跨叶子完成的方法返回 ErrNotSupported", func() {
+			_, err := tree.WaitExchange(context.TODO(), 1)
+			So(err, ShouldEqual, ErrNotSupported)
+			_, err = tree.Arrive()
+			So(err, ShouldEqual, ErrNotSupported)
+			So(tree.Resize(20), ShouldEqual, ErrNotSupported)
+			So(tree.Register().Wait(context.TODO()), ShouldEqual, ErrNotSupported)
+			So(tree.NumberWaiting(), ShouldEqual, 0)
+		})
+
+		Convey("OnBroken 的 hook 在所有节点都被打破后调用", func() {
+			causes := make(chan error, 1)
+			tree.OnBroken(func(cause error) {
+				causes <- cause
+			})
+			goWait(tree)
+			tree.Break()
+			So(<-causes, ShouldEqual, ErrBroken)
+			for id := 0; id < parties; id++ {
+				So(tree.Leaf(id).IsBroken(), ShouldBeTrue)
+			}
+		})
+
+		Convey("设置叶子的 action 不会让叶子脱离 Tree", func() {
+			var leafActions int32
+			tree.Leaf(0).SetAction(func() {
+				atomic.AddInt32(&leafActions, 1)
+			})
+			So(len(run(2, background)), ShouldEqual, 0)
+			So(tree.Round(), ShouldEqual, 2)
+			So(atomic.LoadInt32(&leafActions), ShouldEqual, 2)
+		})
+
+		Convey("在根的 action 里等待 Tree 会返回 ErrReentrantWait", func() {
+			var err error
+			tree.SetActionCtx(func(ctx context.Context) error {
+				err = tree.Wait(ctx)
+				return nil
+			})
+			So(len(run(1, background)), ShouldEqual, 0)
+			So(err, ShouldEqual, ErrReentrantWait)
+		})
+	})
+
+	Convey("参与者不多于 fanout 的时候，Tree 只有一个节点", t, func() {
+		tree := NewTree(2, 4)
+		So(tree.Leaf(0), ShouldEqual, tree.Leaf(1))
+		goWait(tree.Leaf(0))
+		So(tree.Wait(context.TODO()), ShouldBeNil)
+		So(tree.Round(), ShouldEqual, 1)
+	})
+}
+
+// cycleTree is cycle, but every party waits its leaf of the tree.
+func cycleTree(b *testing.B, parties, fanout int) {
+	tree := NewTree(parties, fanout)
+	var wg sync.WaitGroup
+	wg.Add(parties - 1)
+	for id := 1; id < parties; id++ {
+		go func() {
+			for n := 0; n < b.N; n++ {
+				tree.Wait(context.TODO())
+			}
+			wg.Done()
+		}()
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		tree.Wait(context.TODO())
+	}
+	wg.Wait()
+}
+
+func Benchmark_Cycle_1024(b *testing.B) {
+	cycle(b, 1024, New(1024).Wait)
+}
+
+func Benchmark_Cycle_1024_Tree_16(b *testing.B) {
+	cycleTree(b, 1024, 16)
+}
@@ -0,0 +1,40 @@
+package barrier
+
+import "context"
+
+// Typed is a Barrier, whose action computes a value of T for every round,
+// and every participant of the round receives the value from Wait.
+type Typed[T any] struct {
+	Barrier
+	b *barrier
+}
+
+// NewTyped initializes a new instance of the Typed.
+// action is called by the last arrived goroutine, before the actions set by
+// SetAction and the others. If it returns an error, the round is broken
+// with it like SetActionE.
+func NewTyped[T any](participants int, action func() (T, error), opts ...Option) *Typed[T] {
+	opts = append(opts, func(b *barrier) {
+		b.collector = func([]interface{}) (interface{}, error) {
+			v, err := action()
+			return box[T]{v}, err
+		}
+	})
+	b := New(participants, opts...).(*barrier)
+	return &Typed[T]{
+		Barrier: b,
+		b:       b,
+	}
+}
+
+// Wait is Barrier.Wait, and returns the value computed by the action of
+// the round. It returns the zero value of T, if the round is broken.
+func (t *Typed[T]) Wait(ctx context.Context) (T, error) {
+	var zero T
+	out, _, err := t.b.wait(ctx, nil, 0, 0)
+	if err != nil {
+		return zero, err
+	}
+	v, _ := out.result.(box[T])
+	return v.v, nil
+}
@@ -0,0 +1,59 @@
+package barrier
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTyped(t *testing.T) {
+	Convey("假设 Typed 有 3 个参与者，每一轮的 action 返回这是第几轮", t, func() {
+		participants := 3
+		errAction := errors.New("action failed")
+		rounds := 0
+		b := NewTyped(participants, func() (int, error) {
+			rounds++
+			if rounds == 3 {
+				return 0, errAction
+			}
+			return rounds, nil
+		})
+		waitAll := func() ([]int, []error) {
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			var values []int
+			var errs []error
+			wg.Add(participants)
+			for i := 0; i < participants; i++ {
+				go func() {
+					v, err := b.Wait(context.TODO())
+					mu.Lock()
+					values, errs = append(values, v), append(errs, err)
+					mu.Unlock()
+					wg.Done()
+				}()
+			}
+			wg.Wait()
+			return values, errs
+		}
+
+		Convey("每一轮所有的参与者都会得到这一轮 action 的返回值", func() {
+			for r := 1; r <= 2; r++ {
+				values, errs := waitAll()
+				So(values, ShouldResemble, []int{r, r, r})
+				So(errs, ShouldResemble, []error{nil, nil, nil})
+			}
+
+			Convey("action 返回 error 的时候，参与者得到零值和这个 error", func() {
+				values, errs := waitAll()
+				So(values, ShouldResemble, []int{0, 0, 0})
+				for _, err := range errs {
+					So(err, shouldBeBrokenBy, errAction)
+				}
+			})
+		})
+	})
+}
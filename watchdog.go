@@ -0,0 +1,124 @@
+package barrier
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// Diagnostics describes a round broken by the watchdog of WithWatchdog.
+type Diagnostics struct {
+	Round   uint64          // number of the completed rounds before it
+	Parties int             // participants of the round
+	Arrived int             // goroutines arrived in the round
+	Waited  []time.Duration // how long the arrived goroutines have waited, in arrival order
+	Names   []string        // names of the arrived goroutines set by WaitAs, in arrival order
+	Stacks  []byte          // stacks of all goroutines, when the round is broken
+}
+
+// WithWatchdog makes every round break with an error wrapping ErrTimeout,
+// if not all participants arrive within threshold after the first arrival,
+// and then calls report with the diagnostics of the round.
+// It helps to find out the participant never arriving.
+func WithWatchdog(threshold time.Duration, report func(Diagnostics)) Option {
+	return func(b *barrier) {
+		b.watchdog = threshold
+		b.report = report
+	}
+}
+
+// watch breaks r if it is stuck, and reports it.
+func (b *barrier) watch(r *round, id uint64) {
+	b.lockArrivals()
+	if !b.isStuck(r, id) {
+		b.unlockArrivals()
+		return
+	}
+	now := time.Now()
+	d := Diagnostics{
+		Round:   id,
+		Parties: r.parties,
+		Arrived: r.count(),
+	}
+	if d.Arrived > d.Parties {
+		d.Arrived = d.Parties
+	}
+	for _, at := range r.arrivals[:d.Arrived] {
+		d.Waited = append(d.Waited, now.Sub(at))
+	}
+	if r.names != nil {
+		d.Names = append(d.Names, r.names[:d.Arrived]...)
+	}
+	err := fmt.Errorf("watchdog: %w", ErrTimeout)
+	broadcast := b.breakWith(r, err, nil)
+	report := b.report
+	b.unlockArrivals()
+	if report != nil {
+		// before the waiting goroutines leave.
+		d.Stacks = stacks()
+	}
+	broadcast()
+	if report != nil {
+		report(d)
+	}
+}
+
+// stacks returns the stacks of all goroutines.
+func stacks() []byte {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
+
+// Stall describes a round reported by WithStallWarning.
+type Stall struct {
+	Round   uint64        // number of the completed rounds before it
+	Parties int           // participants of the round
+	Arrived int           // goroutines waiting in the round
+	Missing int           // participants not arrived yet
+	Waited  time.Duration // how long the first arrival has waited
+	Names   []string      // names of the arrived goroutines set by WaitAs, in arrival order
+}
+
+// WithStallWarning calls warn, if not all participants arrive within threshold
+// after the first arrival of a round. Unlike WithWatchdog, the round is not broken,
+// so it is cheap enough to catch misconfigured participants in staging.
+// warn is called once per round, in its own goroutine.
+func WithStallWarning(threshold time.Duration, warn func(Stall)) Option {
+	return func(b *barrier) {
+		b.stallAfter = threshold
+		b.warn = warn
+	}
+}
+
+// checkStall calls b.warn, if r is stuck since start.
+func (b *barrier) checkStall(r *round, id uint64, start time.Time) {
+	b.lock.RLock()
+	if !b.isStuck(r, id) || r.isBroken {
+		b.lock.RUnlock()
+		return
+	}
+	s := Stall{
+		Round:   id,
+		Parties: r.parties,
+		Arrived: r.count(),
+		Waited:  time.Since(start),
+	}
+	if s.Arrived > s.Parties {
+		s.Arrived = s.Parties
+	}
+	if r.names != nil {
+		s.Names = append(s.Names, r.names[:s.Arrived]...)
+	}
+	warn := b.warn
+	b.lock.RUnlock()
+	s.Missing = s.Parties - s.Arrived
+	if warn != nil {
+		warn(s)
+	}
+}
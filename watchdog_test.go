@@ -0,0 +1,86 @@
+package barrier
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWatchdog(t *testing.T) {
+	Convey("假设 Barrier 有 3 个参与者，并且有看门狗", t, func() {
+		threshold := 10 * time.Millisecond
+		reports := make(chan Diagnostics, 1)
+		b := New(3, WithWatchdog(threshold, func(d Diagnostics) {
+			reports <- d
+		}))
+
+		Convey("只有 2 个参与者到达，这一轮会被 break，并且报告诊断信息", func() {
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- b.Wait(context.TODO())
+			}()
+			err := b.Wait(context.TODO())
+			So(errors.Is(err, ErrTimeout), ShouldBeTrue)
+			So(<-errCh, ShouldEqual, err)
+			d := <-reports
+			So(d.Round, ShouldEqual, 0)
+			So(d.Parties, ShouldEqual, 3)
+			So(d.Arrived, ShouldEqual, 2)
+			So(d.Waited, ShouldHaveLength, 2)
+			So(d.Waited[0], ShouldBeGreaterThanOrEqualTo, threshold)
+			So(bytes.Contains(d.Stacks, []byte("TestWatchdog")), ShouldBeTrue)
+		})
+
+		Convey("参与者都按时到达，不会报告", func() {
+			goWait(b)
+			goWait(b)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			time.Sleep(threshold * 2)
+			So(reports, ShouldBeEmpty)
+			So(b.IsBroken(), ShouldBeFalse)
+		})
+	})
+}
+
+func TestStallWarning(t *testing.T) {
+	Convey("假设 Barrier 有 3 个参与者，并且会警告停滞的轮次", t, func() {
+		threshold := 10 * time.Millisecond
+		stalls := make(chan Stall, 1)
+		b := New(3, WithStallWarning(threshold, func(s Stall) {
+			stalls <- s
+		}))
+
+		Convey("只有 1 个参与者到达，会收到警告，但是这一轮不会被 break", func() {
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- b.WaitAs(context.TODO(), "shard-7-writer")
+			}()
+			s := <-stalls
+			So(s.Round, ShouldEqual, 0)
+			So(s.Parties, ShouldEqual, 3)
+			So(s.Arrived, ShouldEqual, 1)
+			So(s.Missing, ShouldEqual, 2)
+			So(s.Names, ShouldResemble, []string{"shard-7-writer"})
+			So(s.Waited, ShouldBeGreaterThanOrEqualTo, threshold)
+			So(b.IsBroken(), ShouldBeFalse)
+
+			Convey("迟到的参与者依然可以完成这一轮", func() {
+				goWait(b)
+				So(b.Wait(context.TODO()), ShouldBeNil)
+				So(<-errCh, ShouldBeNil)
+			})
+		})
+
+		Convey("参与者都按时到达，不会警告", func() {
+			goWait(b)
+			goWait(b)
+			So(b.Wait(context.TODO()), ShouldBeNil)
+			time.Sleep(threshold * 2)
+			So(stalls, ShouldBeEmpty)
+		})
+	})
+}